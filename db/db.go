@@ -1,6 +1,7 @@
 package db
 
 import (
+	"errors"
 	"fmt"
 	"log"
 
@@ -9,7 +10,15 @@ import (
 	"gorm.io/gorm/logger"
 )
 
-func NewDatabase(url string) (*gorm.DB, error) {
+// ErrEmbeddingDimensionChange is returned when a fragment table holding
+// embeddings is configured with a different embedding dimension
+var ErrEmbeddingDimensionChange = errors.New("embedding dimension of fragment table changed")
+
+// NewDatabase connects to the database and migrates the schema. Fragment
+// tables keep their recorded embedding config unless a config for them is
+// given, new tables use DefaultFragmentTableConfig. engine.New passes the
+// config of its LLM client's embedding provider.
+func NewDatabase(url string, tableConfigs ...FragmentTableConfig) (*gorm.DB, error) {
 	db, err := gorm.Open(postgres.Open(url), &gorm.Config{
 		Logger: logger.Default.LogMode(logger.Silent),
 	})
@@ -30,27 +39,149 @@ func NewDatabase(url string) (*gorm.DB, error) {
 	log.Println("pgvector extension version: ", version)
 
 	// Auto-migrate the schema
-	if err := db.AutoMigrate(&Actor{}, &Session{}); err != nil {
+	if err := db.AutoMigrate(&Actor{}, &Session{}, &Usage{}, &EmbeddingCache{}, &FragmentTableConfig{}); err != nil {
 		return nil, fmt.Errorf("failed to migrate schemas: %w", err)
 	}
 
 	// Create fragment tables
-	if err := CreateFragmentTables(db); err != nil {
+	if err := CreateFragmentTables(db, tableConfigs...); err != nil {
 		return nil, fmt.Errorf("failed to create fragment tables: %w", err)
 	}
 
 	return db, nil
 }
 
-func CreateFragmentTables(db *gorm.DB) error {
+// CreateFragmentTables creates the fragment tables and migrates them to their
+// embedding config. Tables without a config keep the one recorded for them,
+// or use DefaultFragmentTableConfig if none is.
+//
+// When the embedding dimension of a table changes and it already holds
+// embeddings, ErrEmbeddingDimensionChange is returned rather than dropping
+// them. Run MigrateEmbeddingDimensions and stores.FragmentStore.Reembed to
+// move such a table to the new dimension.
+func CreateFragmentTables(db *gorm.DB, configs ...FragmentTableConfig) error {
+	overrides := make(map[FragmentTable]FragmentTableConfig, len(configs))
+	for _, config := range configs {
+		if config.EmbeddingModel == "" || config.EmbeddingDimensions <= 0 {
+			return fmt.Errorf("fragment table %s needs an embedding model and dimension", config.Table)
+		}
+		overrides[config.Table] = config
+	}
+
 	for _, table := range fragmentTables {
-		// Create table
-		if !db.Migrator().HasTable(string(table)) {
-			// Create table only if it doesn't exist
-			if err := db.Table(string(table)).Migrator().CreateTable(&Fragment{}); err != nil {
-				return fmt.Errorf("failed to create %s table: %w", table, err)
+		config, ok := overrides[table]
+		if !ok {
+			recorded, err := recordedFragmentTableConfig(db, table)
+			if err != nil {
+				return err
 			}
+			config = recorded
+		}
+		if err := migrateFragmentTable(db, config); err != nil {
+			return fmt.Errorf("failed to migrate %s table: %w", table, err)
+		}
+	}
+	return nil
+}
+
+// migrateFragmentTable creates a fragment table or migrates its embedding column
+func migrateFragmentTable(db *gorm.DB, config FragmentTableConfig) error {
+	table := string(config.Table)
+	migrator := db.Table(table).Migrator()
+
+	if !migrator.HasTable(table) {
+		// Create table only if it doesn't exist
+		if err := migrator.CreateTable(&Fragment{}); err != nil {
+			return fmt.Errorf("failed to create table: %w", err)
+		}
+		if err := alterEmbeddingDimensions(db, table, config.EmbeddingDimensions); err != nil {
+			return err
+		}
+		return db.Save(&config).Error
+	}
+
+	// Tables created before the embedding model was recorded only hold legacy embeddings
+	if !migrator.HasColumn(&Fragment{}, "EmbeddingModel") {
+		if err := migrator.AddColumn(&Fragment{}, "EmbeddingModel"); err != nil {
+			return fmt.Errorf("failed to add embedding model column: %w", err)
+		}
+		err := db.Exec(fmt.Sprintf("UPDATE %s SET embedding_model = ? WHERE embedding IS NOT NULL", table), LegacyEmbeddingModel).Error
+		if err != nil {
+			return fmt.Errorf("failed to backfill embedding models: %w", err)
+		}
+	}
+
+	current, err := recordedFragmentTableConfig(db, config.Table)
+	if err != nil {
+		return err
+	}
+
+	if current.EmbeddingDimensions != config.EmbeddingDimensions {
+		var stored int64
+		if err := db.Table(table).Where("embedding IS NOT NULL").Count(&stored).Error; err != nil {
+			return fmt.Errorf("failed to count embeddings: %w", err)
 		}
+		if stored > 0 {
+			return fmt.Errorf("%w: %s holds %d embeddings of %d dimensions, the config asks for %d; run db.MigrateEmbeddingDimensions and re-embed the fragments",
+				ErrEmbeddingDimensionChange, table, stored, current.EmbeddingDimensions, config.EmbeddingDimensions)
+		}
+		log.Printf("Migrating empty %s embeddings from %d to %d dimensions", table, current.EmbeddingDimensions, config.EmbeddingDimensions)
+		if err := alterEmbeddingDimensions(db, table, config.EmbeddingDimensions); err != nil {
+			return err
+		}
+	}
+	if current.EmbeddingModel != config.EmbeddingModel {
+		log.Printf("Embedding model of %s changed from %s to %s, re-embed its fragments to search them", table, current.EmbeddingModel, config.EmbeddingModel)
+	}
+
+	return db.Save(&config).Error
+}
+
+// recordedFragmentTableConfig returns the embedding config recorded for a
+// table, or its DefaultFragmentTableConfig if none is
+func recordedFragmentTableConfig(db *gorm.DB, table FragmentTable) (FragmentTableConfig, error) {
+	config := DefaultFragmentTableConfig(table)
+	err := db.Where("fragment_table = ?", table).First(&config).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return config, fmt.Errorf("failed to load %s table config: %w", table, err)
+	}
+	return config, nil
+}
+
+// MigrateEmbeddingDimensions moves a fragment table to the embedding model and
+// dimension of config. Vectors cannot be converted between dimensions, so all
+// stored embeddings are cleared; it returns how many. Fragments keep their
+// text, so stores.FragmentStore.Reembed regenerates their embeddings.
+func MigrateEmbeddingDimensions(db *gorm.DB, config FragmentTableConfig) (int64, error) {
+	if config.EmbeddingModel == "" || config.EmbeddingDimensions <= 0 {
+		return 0, fmt.Errorf("fragment table %s needs an embedding model and dimension", config.Table)
+	}
+	table := string(config.Table)
+
+	var cleared int64
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Table(table).Where("embedding IS NOT NULL").Count(&cleared).Error; err != nil {
+			return fmt.Errorf("failed to count embeddings: %w", err)
+		}
+		if err := alterEmbeddingDimensions(tx, table, config.EmbeddingDimensions); err != nil {
+			return err
+		}
+		return tx.Save(&config).Error
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	log.Printf("Migrated %s to %d-dimensional %s embeddings, cleared %d embeddings to re-embed", table, config.EmbeddingDimensions, config.EmbeddingModel, cleared)
+	return cleared, nil
+}
+
+// alterEmbeddingDimensions changes the dimension of a table's embedding column.
+// Vectors cannot be converted between dimensions, so they are cleared.
+func alterEmbeddingDimensions(db *gorm.DB, table string, dimensions int) error {
+	err := db.Exec(fmt.Sprintf("ALTER TABLE %s ALTER COLUMN embedding TYPE vector(%d) USING NULL::vector(%d)", table, dimensions, dimensions)).Error
+	if err != nil {
+		return fmt.Errorf("failed to set embedding dimension: %w", err)
 	}
 	return nil
 }
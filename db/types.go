@@ -29,6 +29,48 @@ var fragmentTables = []FragmentTable{
 	FragmentTableTwitter,
 }
 
+// LegacyEmbeddingModel is the model embeddings were generated with before
+// fragment tables recorded their embedding model
+const LegacyEmbeddingModel = "text-embedding-ada-002"
+
+// FragmentTableConfig records the embedding model and dimension of a fragment table
+type FragmentTableConfig struct {
+	Table               FragmentTable `gorm:"column:fragment_table;type:varchar(64);primaryKey"`
+	EmbeddingModel      string        `gorm:"type:varchar(255);not null"`
+	EmbeddingDimensions int           `gorm:"not null"`
+
+	UpdatedAt time.Time
+}
+
+// TableName places fragment table configs in the fragment_table_configs table
+func (FragmentTableConfig) TableName() string {
+	return "fragment_table_configs"
+}
+
+// DefaultFragmentTableConfig returns the embedding config of a new table
+// unless NewDatabase is given another one
+func DefaultFragmentTableConfig(table FragmentTable) FragmentTableConfig {
+	return FragmentTableConfig{
+		Table:               table,
+		EmbeddingModel:      LegacyEmbeddingModel,
+		EmbeddingDimensions: 1536,
+	}
+}
+
+// FragmentTableConfigs returns a config with the given embedding model and
+// dimension for every fragment table
+func FragmentTableConfigs(model string, dimensions int) []FragmentTableConfig {
+	configs := make([]FragmentTableConfig, len(fragmentTables))
+	for i, table := range fragmentTables {
+		configs[i] = FragmentTableConfig{
+			Table:               table,
+			EmbeddingModel:      model,
+			EmbeddingDimensions: dimensions,
+		}
+	}
+	return configs
+}
+
 // Metadata represents a JSON object stored in the database
 type Metadata map[string]interface{}
 
@@ -38,7 +80,9 @@ type Fragment struct {
 	SessionID id.ID           `gorm:"type:uuid;not null;index"`
 	Content   string          `gorm:"type:text;not null"`
 	Metadata  Metadata        `gorm:"type:jsonb;not null;default:'{}'::jsonb"`
-	Embedding pgvector.Vector `gorm:"type:vector(1536)"`
+	Embedding pgvector.Vector `gorm:"type:vector"` // Dimension is set per table, see FragmentTableConfig
+
+	EmbeddingModel string `gorm:"type:varchar(255);index"` // Model the embedding was generated with
 
 	Actor   *Actor   `gorm:"foreignKey:ActorID"`
 	Session *Session `gorm:"foreignKey:SessionID"`
@@ -67,6 +111,42 @@ type Session struct {
 	DeletedAt gorm.DeletedAt `gorm:"index"`
 }
 
+// Usage records the token usage and cost of a single LLM call
+type Usage struct {
+	ID        id.ID  `gorm:"type:uuid;primaryKey"`
+	SessionID *id.ID `gorm:"type:uuid;index"`
+	ActorID   *id.ID `gorm:"type:uuid;index"`
+	Manager   string `gorm:"type:varchar(255);index"`
+	Operation string `gorm:"type:varchar(64);not null"`
+	Provider  string `gorm:"type:varchar(64);not null"`
+	Model     string `gorm:"type:varchar(255);not null;index"`
+
+	PromptTokens     int     `gorm:"not null;default:0"`
+	CompletionTokens int     `gorm:"not null;default:0"`
+	ReasoningTokens  int     `gorm:"not null;default:0"`
+	TotalTokens      int     `gorm:"not null;default:0"`
+	Cost             float64 `gorm:"type:double precision;not null;default:0"`
+
+	CreatedAt time.Time `gorm:"index"`
+}
+
+// TableName places usage records in the llm_usage table
+func (Usage) TableName() string {
+	return "llm_usage"
+}
+
+// EmbeddingCache persists embeddings keyed by a hash of provider, model and text
+type EmbeddingCache struct {
+	Key       string          `gorm:"type:char(64);primaryKey"`
+	Embedding pgvector.Vector `gorm:"type:vector;not null"`
+	CreatedAt time.Time
+}
+
+// TableName places cached embeddings in the llm_embedding_cache table
+func (EmbeddingCache) TableName() string {
+	return "llm_embedding_cache"
+}
+
 // Value implements the driver.Valuer interface
 func (m Metadata) Value() (driver.Value, error) {
 	if m == nil {
@@ -1,6 +1,7 @@
 package engine
 
 import (
+	"context"
 	"fmt"
 	"time"
 
@@ -24,6 +25,10 @@ func New(opts ...options.Option[Engine]) (*Engine, error) {
 		return nil, fmt.Errorf("failed to create core: %w", err)
 	}
 
+	if err := e.alignFragmentTables(); err != nil {
+		return nil, fmt.Errorf("failed to configure fragment tables: %w", err)
+	}
+
 	if err := e.UpsertActor(e.ID, e.Name, true); err != nil {
 		return nil, fmt.Errorf("failed to upsert actor: %w", err)
 	}
@@ -31,6 +36,17 @@ func New(opts ...options.Option[Engine]) (*Engine, error) {
 	return e, nil
 }
 
+// alignFragmentTables configures the fragment tables for the embedding model
+// and dimension of the engine's LLM client, so fragments it embeds can be
+// stored and searched whatever the embedding provider
+func (e *Engine) alignFragmentTables() error {
+	dimensions, err := e.llmClient.EmbeddingDimensions(e.ctx)
+	if err != nil {
+		return err
+	}
+	return db.CreateFragmentTables(e.db, db.FragmentTableConfigs(e.llmClient.EmbeddingModel(), dimensions)...)
+}
+
 // Process handles the processing of a new input through the runtime pipeline:
 // 1. Retrieves actor and session information
 // 2. Creates a copy of the input fragment
@@ -71,8 +87,16 @@ func (e *Engine) PostProcess(response *db.Fragment, currentState *state.State) e
 // 3. Builds response fragment with metadata
 // Returns the response fragment and any error encountered.
 func (e *Engine) GenerateResponse(messages []llm.Message, sessionID id.ID, tools ...toolkit.Tool) (*db.Fragment, error) {
+	return e.GenerateResponseContext(e.ctx, messages, sessionID, tools...)
+}
+
+// GenerateResponseContext works like GenerateResponse but is bound to ctx,
+// e.g. the turn's state.Context(), instead of the engine's context
+func (e *Engine) GenerateResponseContext(ctx context.Context, messages []llm.Message, sessionID id.ID, tools ...toolkit.Tool) (*db.Fragment, error) {
+	llmClient := e.llmClient.WithScope(llm.UsageScope{SessionID: sessionID, ActorID: e.ID})
+
 	// Generate completion
-	response, err := e.llmClient.GenerateCompletion(llm.CompletionRequest{
+	response, err := llmClient.GenerateCompletionContext(ctx, llm.CompletionRequest{
 		Messages:    messages,
 		ModelType:   llm.ModelTypeDefault,
 		Temperature: 0.7,
@@ -82,22 +106,89 @@ func (e *Engine) GenerateResponse(messages []llm.Message, sessionID id.ID, tools
 		return nil, fmt.Errorf("failed to generate completion: %v", err)
 	}
 
+	return e.newResponseFragment(ctx, llmClient, response, sessionID)
+}
+
+// GenerateResponseStream creates a new response using the LLM, streaming the
+// completion as it is generated:
+// 1. Streams completion events from provided messages to the handler
+// 2. Creates embedding for the final response
+// 3. Builds response fragment with metadata
+// Returns the response fragment once the stream completes.
+func (e *Engine) GenerateResponseStream(messages []llm.Message, sessionID id.ID, handler func(llm.StreamEvent), tools ...toolkit.Tool) (*db.Fragment, error) {
+	return e.GenerateResponseStreamContext(e.ctx, messages, sessionID, handler, tools...)
+}
+
+// GenerateResponseStreamContext works like GenerateResponseStream but is
+// bound to ctx instead of the engine's context. Cancelling ctx ends the stream.
+func (e *Engine) GenerateResponseStreamContext(ctx context.Context, messages []llm.Message, sessionID id.ID, handler func(llm.StreamEvent), tools ...toolkit.Tool) (*db.Fragment, error) {
+	llmClient := e.llmClient.WithScope(llm.UsageScope{SessionID: sessionID, ActorID: e.ID})
+
+	events, err := llmClient.GenerateCompletionStreamContext(ctx, llm.CompletionRequest{
+		Messages:    messages,
+		ModelType:   llm.ModelTypeDefault,
+		Temperature: 0.7,
+		Tools:       tools,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate completion: %v", err)
+	}
+
+	var response *llm.Message
+	for event := range events {
+		if handler != nil {
+			handler(event)
+		}
+
+		switch event.Type {
+		case llm.StreamEventError:
+			return nil, fmt.Errorf("failed to generate completion: %v", event.Err)
+		case llm.StreamEventDone:
+			response = event.Message
+		}
+	}
+	if response == nil {
+		return nil, fmt.Errorf("failed to generate completion: stream ended without a response")
+	}
+
+	return e.newResponseFragment(ctx, llmClient, *response, sessionID)
+}
+
+// newResponseFragment embeds the response content and wraps it in a fragment
+// attributed to the engine's actor. The completion's token usage, its
+// reasoning if enabled and any model cascade are kept in the fragment metadata.
+func (e *Engine) newResponseFragment(ctx context.Context, llmClient *llm.LLMClient, response llm.Message, sessionID id.ID) (*db.Fragment, error) {
 	// Generate embedding for the response
-	embedding, err := e.llmClient.EmbedText(response.Content)
+	embedding, err := llmClient.EmbedTextContext(ctx, response.Content)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create embedding for response: %v", err)
 	}
 
+	metadata := db.Metadata{}
+	if response.Usage != nil {
+		metadata["usage"] = usageMetadata(*response.Usage)
+	}
+	if e.storeReasoning && response.Reasoning != "" {
+		metadata["reasoning"] = response.Reasoning
+	}
+	if response.Cascade != nil {
+		metadata["cascade"] = response.Cascade.Metadata()
+	}
+	if len(metadata) == 0 {
+		metadata = nil
+	}
+
 	// Create response fragment
 	responseFragment := &db.Fragment{
-		ID:        id.New(),
-		ActorID:   e.ID,
-		SessionID: sessionID,
-		Content:   response.Content,
-		Embedding: pgvector.NewVector(embedding),
-		CreatedAt: time.Now(),
-		UpdatedAt: time.Now(),
-		Metadata:  nil,
+		ID:             id.New(),
+		ActorID:        e.ID,
+		SessionID:      sessionID,
+		Content:        response.Content,
+		Embedding:      pgvector.NewVector(embedding),
+		EmbeddingModel: llmClient.EmbeddingModel(),
+		CreatedAt:      time.Now(),
+		UpdatedAt:      time.Now(),
+		Metadata:       metadata,
 	}
 
 	return responseFragment, nil
@@ -0,0 +1,328 @@
+package engine
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"github.com/soralabs/zen/db"
+	"github.com/soralabs/zen/id"
+	"github.com/soralabs/zen/llm"
+	"github.com/soralabs/zen/logger"
+	"github.com/soralabs/zen/manager"
+	"github.com/soralabs/zen/state"
+	"github.com/soralabs/zen/stores"
+
+	"github.com/pgvector/pgvector-go"
+	"gorm.io/gorm"
+)
+
+// The fixture is replayed by default. To re-record it against the OpenAI API,
+// run: ZEN_RECORD=1 OPENAI_API_KEY=... go test ./engine
+const engineFixture = "testdata/engine.json"
+
+const (
+	testInput    = "I finally got the build green after a week of flaky tests!"
+	testResponse = "Congratulations! A week of flaky tests is a marathon. Was it a race condition in the end?"
+)
+
+// newReplayClient returns an LLM client whose completions are replayed from
+// the engine fixture and whose embeddings are hashed in-process
+func newReplayClient(t *testing.T) *llm.LLMClient {
+	t.Helper()
+
+	chat := llm.ProviderConfig{
+		Type:      llm.ProviderOpenAI,
+		APIKey:    "replay",
+		Recording: &llm.RecordingConfig{Mode: llm.RecordingModeReplay, Path: engineFixture},
+	}
+	if os.Getenv("ZEN_RECORD") != "" {
+		chat.APIKey = os.Getenv("OPENAI_API_KEY")
+		chat.BaseURL = os.Getenv("OPENAI_BASE_URL")
+		chat.Recording.Mode = llm.RecordingModeRecord
+	}
+
+	client, err := llm.NewLLMClient(llm.Config{
+		DefaultProvider:   chat,
+		EmbeddingProvider: &llm.ProviderConfig{Type: llm.ProviderHashing},
+		Context:           context.Background(),
+	})
+	if err != nil {
+		t.Fatalf("NewLLMClient: %v", err)
+	}
+	return client
+}
+
+// sentimentManager classifies the input with a structured output call
+type sentimentManager struct {
+	manager.BaseManager
+	llm           *llm.LLMClient
+	sentiment     string
+	postProcessed *db.Fragment
+}
+
+func (m *sentimentManager) GetID() manager.ManagerID { return "sentiment" }
+
+func (m *sentimentManager) GetDependencies() []manager.ManagerID { return nil }
+
+func (m *sentimentManager) Process(currentState *state.State) error {
+	var result struct {
+		Sentiment string `json:"sentiment" jsonschema:"required,enum=positive,enum=neutral,enum=negative"`
+	}
+	err := m.llm.GenerateStructuredOutputContext(currentState.Context(), llm.StructuredOutputRequest{
+		Messages: []llm.Message{
+			llm.NewSystemMessage("Classify the sentiment of the user's message."),
+			llm.NewUserMessage(currentState.Input.Content),
+		},
+		ModelType:  llm.ModelTypeFast,
+		SchemaName: "sentiment",
+	}, &result)
+	if err != nil {
+		return err
+	}
+	m.sentiment = result.Sentiment
+	return nil
+}
+
+func (m *sentimentManager) PostProcess(currentState *state.State) error {
+	m.postProcessed = currentState.Output
+	return nil
+}
+
+func (m *sentimentManager) Context(*state.State) ([]state.StateData, error) { return nil, nil }
+
+func (m *sentimentManager) Store(*db.Fragment) error { return nil }
+
+func (m *sentimentManager) StartBackgroundProcesses() {}
+
+func (m *sentimentManager) StopBackgroundProcesses() {}
+
+func responseMessages() []llm.Message {
+	return []llm.Message{
+		llm.NewSystemMessage("You are a friendly assistant. Answer in one or two sentences."),
+		llm.NewUserMessage(testInput),
+	}
+}
+
+func TestReplayManagerAndResponse(t *testing.T) {
+	client := newReplayClient(t)
+	e := &Engine{ctx: context.Background(), ID: id.FromString("agent"), Name: "agent", llmClient: client}
+
+	sentiment := &sentimentManager{llm: client}
+	currentState := state.NewState().SetContext(context.Background())
+	currentState.Input = &db.Fragment{ID: id.New(), ActorID: id.FromString("user"), SessionID: id.FromString("session"), Content: testInput}
+	if err := sentiment.Process(currentState); err != nil {
+		t.Fatalf("Process: %v", err)
+	}
+	if sentiment.sentiment != "positive" {
+		t.Errorf("sentiment = %q, want positive", sentiment.sentiment)
+	}
+
+	response, err := e.GenerateResponse(responseMessages(), currentState.Input.SessionID)
+	if err != nil {
+		t.Fatalf("GenerateResponse: %v", err)
+	}
+	if response.Content != testResponse {
+		t.Errorf("content = %q, want %q", response.Content, testResponse)
+	}
+	if response.ActorID != e.ID || response.EmbeddingModel != "hashing-384" || len(response.Embedding.Slice()) != 384 {
+		t.Errorf("response = %+v", response)
+	}
+	if _, ok := response.Metadata["usage"]; !ok {
+		t.Errorf("metadata = %v, want the recorded usage", response.Metadata)
+	}
+}
+
+// testDatabaseURL returns the Postgres database with pgvector that tests
+// needing a database run against, or skips the test if none is configured
+func testDatabaseURL(t *testing.T) string {
+	t.Helper()
+	url := os.Getenv("ZEN_TEST_DATABASE_URL")
+	if url == "" {
+		t.Skip("ZEN_TEST_DATABASE_URL is not set")
+	}
+	return url
+}
+
+func testLogger(t *testing.T) *logger.Logger {
+	t.Helper()
+	log, err := logger.New(logger.DefaultConfig())
+	if err != nil {
+		t.Fatalf("logger.New: %v", err)
+	}
+	return log
+}
+
+// TestEngineProcessReplay runs a full turn against a database. It needs a
+// Postgres database with pgvector in ZEN_TEST_DATABASE_URL.
+func TestEngineProcessReplay(t *testing.T) {
+	url := testDatabaseURL(t)
+
+	ctx := context.Background()
+	database, err := db.NewDatabase(url)
+	if err != nil {
+		t.Fatalf("NewDatabase: %v", err)
+	}
+	log := testLogger(t)
+
+	client := newReplayClient(t)
+	actorStore := stores.NewActorStore(ctx, database)
+	sessionStore := stores.NewSessionStore(ctx, database)
+	fragmentStore := stores.NewFragmentStore(ctx, database, db.FragmentTableInteraction)
+
+	userID, sessionID := id.New(), id.New()
+	if err := actorStore.Upsert(&db.Actor{ID: userID, Name: "user"}); err != nil {
+		t.Fatalf("Upsert actor: %v", err)
+	}
+	if err := sessionStore.Upsert(&db.Session{ID: sessionID}); err != nil {
+		t.Fatalf("Upsert session: %v", err)
+	}
+	t.Cleanup(func() {
+		if err := fragmentStore.DeleteBySession(sessionID); err != nil {
+			t.Errorf("DeleteBySession: %v", err)
+		}
+	})
+
+	sentiment := &sentimentManager{llm: client}
+	e, err := New(
+		WithContext(ctx),
+		WithDB(database),
+		WithLogger(log),
+		WithIdentifier(id.New(), "agent"),
+		WithActorStore(actorStore),
+		WithSessionStore(sessionStore),
+		WithInteractionFragmentStore(fragmentStore),
+		WithLLMClient(client),
+		WithManagers(sentiment),
+	)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+
+	currentState, err := e.NewState(userID, sessionID, testInput)
+	if err != nil {
+		t.Fatalf("NewState: %v", err)
+	}
+	if err := e.Process(currentState); err != nil {
+		t.Fatalf("Process: %v", err)
+	}
+	if sentiment.sentiment != "positive" {
+		t.Errorf("sentiment = %q, want positive", sentiment.sentiment)
+	}
+
+	response, err := e.GenerateResponse(responseMessages(), sessionID)
+	if err != nil {
+		t.Fatalf("GenerateResponse: %v", err)
+	}
+	if err := e.PostProcess(response, currentState); err != nil {
+		t.Fatalf("PostProcess: %v", err)
+	}
+	if sentiment.postProcessed == nil || sentiment.postProcessed.Content != testResponse {
+		t.Errorf("post-processed output = %+v", sentiment.postProcessed)
+	}
+
+	fragments, err := fragmentStore.GetBySession(sessionID, 10)
+	if err != nil {
+		t.Fatalf("GetBySession: %v", err)
+	}
+	contents := make(map[string]bool)
+	for _, fragment := range fragments {
+		contents[fragment.Content] = true
+		if fragment.EmbeddingModel != client.EmbeddingModel() {
+			t.Errorf("fragment %s embedded with %s", fragment.ID, fragment.EmbeddingModel)
+		}
+	}
+	if len(fragments) != 2 || !contents[testInput] || !contents[testResponse] {
+		t.Errorf("stored fragments = %+v, want the input and the response", fragments)
+	}
+
+	// The interaction table follows the client's embedding provider, so the
+	// stored turn can be searched
+	similar, err := fragmentStore.SearchSimilar(currentState.Input.Embedding, client.EmbeddingModel(), sessionID, 1)
+	if err != nil {
+		t.Fatalf("SearchSimilar: %v", err)
+	}
+	if len(similar) != 1 || similar[0].Content != testInput {
+		t.Errorf("similar = %+v", similar)
+	}
+}
+
+// TestFragmentTablesSurviveRestart restarts an engine whose embedding provider
+// is not the tables' default after it stored an embedded fragment
+func TestFragmentTablesSurviveRestart(t *testing.T) {
+	url := testDatabaseURL(t)
+	ctx := context.Background()
+	log := testLogger(t)
+	client := newReplayClient(t)
+
+	start := func() *gorm.DB {
+		t.Helper()
+		database, err := db.NewDatabase(url)
+		if err != nil {
+			t.Fatalf("NewDatabase: %v", err)
+		}
+		_, err = New(
+			WithContext(ctx),
+			WithDB(database),
+			WithLogger(log),
+			WithIdentifier(id.FromString("restart-agent"), "agent"),
+			WithActorStore(stores.NewActorStore(ctx, database)),
+			WithSessionStore(stores.NewSessionStore(ctx, database)),
+			WithInteractionFragmentStore(stores.NewFragmentStore(ctx, database, db.FragmentTableInteraction)),
+			WithLLMClient(client),
+		)
+		if err != nil {
+			t.Fatalf("New: %v", err)
+		}
+		return database
+	}
+
+	database := start()
+	fragmentStore := stores.NewFragmentStore(ctx, database, db.FragmentTableInteraction)
+	sessionID := id.New()
+	if err := stores.NewSessionStore(ctx, database).Upsert(&db.Session{ID: sessionID}); err != nil {
+		t.Fatalf("Upsert session: %v", err)
+	}
+	embedding, err := client.EmbedText(testInput)
+	if err != nil {
+		t.Fatalf("EmbedText: %v", err)
+	}
+	err = fragmentStore.Create(&db.Fragment{
+		ID:             id.New(),
+		ActorID:        id.FromString("restart-agent"),
+		SessionID:      sessionID,
+		Content:        testInput,
+		Embedding:      pgvector.NewVector(embedding),
+		EmbeddingModel: client.EmbeddingModel(),
+	})
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	t.Cleanup(func() {
+		if err := fragmentStore.DeleteBySession(sessionID); err != nil {
+			t.Errorf("DeleteBySession: %v", err)
+		}
+	})
+
+	// A plain NewDatabase keeps the table at the provider's 384 dimensions
+	database, err = db.NewDatabase(url)
+	if err != nil {
+		t.Fatalf("NewDatabase after restart: %v", err)
+	}
+	config, err := stores.NewFragmentStore(ctx, database, db.FragmentTableInteraction).EmbeddingConfig()
+	if err != nil {
+		t.Fatalf("EmbeddingConfig: %v", err)
+	}
+	if config.EmbeddingModel != client.EmbeddingModel() || config.EmbeddingDimensions != 384 {
+		t.Errorf("config after restart = %+v, want %s with 384 dimensions", config, client.EmbeddingModel())
+	}
+
+	start()
+	fragments, err := fragmentStore.GetBySession(sessionID, 10)
+	if err != nil {
+		t.Fatalf("GetBySession: %v", err)
+	}
+	if len(fragments) != 1 || len(fragments[0].Embedding.Slice()) != 384 {
+		t.Errorf("fragments after restart = %+v", fragments)
+	}
+}
@@ -5,6 +5,7 @@ import (
 
 	"github.com/soralabs/zen/db"
 	"github.com/soralabs/zen/id"
+	"github.com/soralabs/zen/llm"
 	"github.com/soralabs/zen/manager"
 )
 
@@ -44,6 +45,19 @@ func (e *Engine) DoesInteractionFragmentExist(fragmentID id.ID) (bool, error) {
 	return fragment != nil, nil
 }
 
+// usageMetadata converts LLM usage into a JSON-friendly metadata value
+func usageMetadata(usage llm.Usage) map[string]interface{} {
+	return map[string]interface{}{
+		"provider":          string(usage.Provider),
+		"model":             usage.Model,
+		"prompt_tokens":     usage.PromptTokens,
+		"completion_tokens": usage.CompletionTokens,
+		"reasoning_tokens":  usage.ReasoningTokens,
+		"total_tokens":      usage.TotalTokens,
+		"cost":              usage.Cost,
+	}
+}
+
 // Helper function to check if a manager ID is in a list
 func (e *Engine) containsManager(filter []manager.ManagerID, id manager.ManagerID) bool {
 	for _, mid := range filter {
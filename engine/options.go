@@ -147,3 +147,12 @@ func WithLLMClient(client *llm.LLMClient) options.Option[Engine] {
 		return nil
 	}
 }
+
+// WithReasoningStorage stores the reasoning of reasoning models under the
+// "reasoning" key of response fragment metadata, e.g. to audit responses
+func WithReasoningStorage(enabled bool) options.Option[Engine] {
+	return func(e *Engine) error {
+		e.storeReasoning = enabled
+		return nil
+	}
+}
@@ -148,6 +148,10 @@ func (b *ProcessBuilder) Execute() error {
 		}
 	}
 
+	if err := b.state.Context().Err(); err != nil {
+		return fmt.Errorf("turn cancelled: %w", err)
+	}
+
 	input := b.state.Input
 	b.engine.logger.WithFields(map[string]interface{}{
 		"input":         input.ID,
@@ -166,14 +170,15 @@ func (b *ProcessBuilder) Execute() error {
 
 	// Create a copy of the input fragment for storage
 	inputCopy := &db.Fragment{
-		ID:        input.ID,
-		ActorID:   input.ActorID,
-		SessionID: input.SessionID,
-		Content:   input.Content,
-		Metadata:  input.Metadata,
-		Embedding: input.Embedding,
-		Actor:     actor,
-		Session:   session,
+		ID:             input.ID,
+		ActorID:        input.ActorID,
+		SessionID:      input.SessionID,
+		Content:        input.Content,
+		Metadata:       input.Metadata,
+		Embedding:      input.Embedding,
+		EmbeddingModel: input.EmbeddingModel,
+		Actor:          actor,
+		Session:        session,
 	}
 
 	// Apply custom metadata
@@ -342,6 +347,10 @@ func (b *PostProcessBuilder) Execute() error {
 		}
 	}
 
+	if err := b.state.Context().Err(); err != nil {
+		return fmt.Errorf("turn cancelled: %w", err)
+	}
+
 	actor, err := b.engine.actorStore.GetByID(b.response.ActorID)
 	if err != nil {
 		return fmt.Errorf("failed to get actor: %w", err)
@@ -353,14 +362,15 @@ func (b *PostProcessBuilder) Execute() error {
 	}
 
 	responseCopy := &db.Fragment{
-		ID:        b.response.ID,
-		ActorID:   b.response.ActorID,
-		SessionID: b.response.SessionID,
-		Content:   b.response.Content,
-		Metadata:  b.response.Metadata,
-		Embedding: b.response.Embedding,
-		Actor:     actor,
-		Session:   session,
+		ID:             b.response.ID,
+		ActorID:        b.response.ActorID,
+		SessionID:      b.response.SessionID,
+		Content:        b.response.Content,
+		Metadata:       b.response.Metadata,
+		Embedding:      b.response.Embedding,
+		EmbeddingModel: b.response.EmbeddingModel,
+		Actor:          actor,
+		Session:        session,
 	}
 
 	// Apply custom metadata
@@ -1,11 +1,13 @@
 package engine
 
 import (
+	"context"
 	"fmt"
 	"time"
 
 	"github.com/soralabs/zen/db"
 	"github.com/soralabs/zen/id"
+	"github.com/soralabs/zen/llm"
 	"github.com/soralabs/zen/state"
 
 	"github.com/pgvector/pgvector-go"
@@ -40,7 +42,13 @@ func defaultStateOptions() *StateOptions {
 // NewState creates a new State instance with the provided options.
 // It initializes an empty state and applies any provided configuration options.
 func (e *Engine) NewStateFromFragment(fragment *db.Fragment, opts ...StateOption) (*state.State, error) {
-	state := state.NewState()
+	return e.NewStateFromFragmentContext(e.ctx, fragment, opts...)
+}
+
+// NewStateFromFragmentContext works like NewStateFromFragment but binds the
+// state to ctx, which bounds the LLM calls managers make for it
+func (e *Engine) NewStateFromFragmentContext(ctx context.Context, fragment *db.Fragment, opts ...StateOption) (*state.State, error) {
+	state := state.NewState().SetContext(ctx)
 	state.Input = fragment
 	if err := e.UpdateState(state, opts...); err != nil {
 		return nil, fmt.Errorf("failed to create state: %w", err)
@@ -49,21 +57,28 @@ func (e *Engine) NewStateFromFragment(fragment *db.Fragment, opts ...StateOption
 }
 
 func (e *Engine) NewState(actorId, sessionId id.ID, input string, opts ...StateOption) (*state.State, error) {
-	embedding, err := e.llmClient.EmbedText(input)
+	return e.NewStateContext(e.ctx, actorId, sessionId, input, opts...)
+}
+
+// NewStateContext works like NewState but binds the state to ctx, which
+// bounds embedding the input and the LLM calls managers make for the state
+func (e *Engine) NewStateContext(ctx context.Context, actorId, sessionId id.ID, input string, opts ...StateOption) (*state.State, error) {
+	embedding, err := e.llmClient.WithScope(llm.UsageScope{SessionID: sessionId, ActorID: actorId}).EmbedTextContext(ctx, input)
 	if err != nil {
 		return nil, fmt.Errorf("failed to embed text: %w", err)
 	}
 
-	state := state.NewState()
+	state := state.NewState().SetContext(ctx)
 	state.Input = &db.Fragment{
-		ID:        id.New(),
-		ActorID:   actorId,
-		SessionID: sessionId,
-		Content:   input,
-		Metadata:  nil,
-		Embedding: pgvector.NewVector(embedding),
-		CreatedAt: time.Now(),
-		UpdatedAt: time.Now(),
+		ID:             id.New(),
+		ActorID:        actorId,
+		SessionID:      sessionId,
+		Content:        input,
+		Metadata:       nil,
+		Embedding:      pgvector.NewVector(embedding),
+		EmbeddingModel: e.llmClient.EmbeddingModel(),
+		CreatedAt:      time.Now(),
+		UpdatedAt:      time.Now(),
 	}
 
 	if err := e.UpdateState(state, opts...); err != nil {
@@ -105,7 +120,7 @@ func (e *Engine) UpdateState(s *state.State, opts ...StateOption) error {
 		return fmt.Errorf("failed to get recent interactions: %w", err)
 	}
 
-	relevantInteractions, err := e.interactionFragmentStore.SearchSimilar(s.Input.Embedding, s.Input.SessionID, options.relevantInteractionLimit)
+	relevantInteractions, err := e.interactionFragmentStore.SearchSimilar(s.Input.Embedding, s.Input.EmbeddingModel, s.Input.SessionID, options.relevantInteractionLimit)
 	if err != nil {
 		return fmt.Errorf("failed to get relevant interactions: %w", err)
 	}
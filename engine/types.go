@@ -37,4 +37,7 @@ type Engine struct {
 
 	// LLM client
 	llmClient *llm.LLMClient
+
+	// Keep the model's reasoning in response fragment metadata
+	storeReasoning bool
 }
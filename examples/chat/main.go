@@ -58,8 +58,9 @@ func main() {
 				llm.ModelTypeAdvanced: openai.GPT4o,
 			},
 		},
-		Logger:  log.NewSubLogger("llm", &logger.SubLoggerOpts{}),
-		Context: ctx,
+		UsageRecorder: stores.NewUsageStore(ctx, database),
+		Logger:        log.NewSubLogger("llm", &logger.SubLoggerOpts{}),
+		Context:       ctx,
 	})
 
 	sessionStore := stores.NewSessionStore(ctx, database)
@@ -344,16 +345,18 @@ func main() {
 			continue
 		}
 
-		// Generate completion
-		responseFragment, err := assistant.GenerateResponse(messages, sessionID, tools...)
+		// Generate completion, printing it as it streams in
+		fmt.Print("\nAssistant: ")
+		responseFragment, err := assistant.GenerateResponseStream(messages, sessionID, func(event llm.StreamEvent) {
+			if event.Type == llm.StreamEventContent {
+				fmt.Print(event.Content)
+			}
+		}, tools...)
 		if err != nil {
 			log.Errorf("Failed to generate response: %v", err)
 			continue
 		}
 
-		// Print response
-		fmt.Printf("\nAssistant: %s", responseFragment.Content)
-
 		err = assistant.PostProcess(responseFragment, currentState)
 		if err != nil {
 			log.Errorf("Failed to post-process message: %v", err)
@@ -58,8 +58,9 @@ func main() {
 			Type:   llm.ProviderOpenAI,
 			APIKey: os.Getenv("OPENAI_API_KEY"),
 		},
-		Logger:  log.NewSubLogger("llm", &logger.SubLoggerOpts{}),
-		Context: ctx,
+		UsageRecorder: stores.NewUsageStore(ctx, database),
+		Logger:        log.NewSubLogger("llm", &logger.SubLoggerOpts{}),
+		Context:       ctx,
 	})
 
 	sessionStore := stores.NewSessionStore(ctx, database)
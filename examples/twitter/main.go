@@ -54,6 +54,12 @@ func main() {
 				llm.ModelTypeAdvanced: openai.GPT4o,
 			},
 		},
+		// Tweets are embedded again for every reply in their thread
+		EmbeddingCache: &llm.EmbeddingCacheConfig{},
+		// Long threads are summarized instead of failing with context-length errors
+		ContextWindow: &llm.ContextWindowConfig{Policy: llm.ContextPolicySummarize},
+		// Insight extraction runs on the fast model unless its output is rejected
+		Cascade: &llm.CascadeConfig{},
 		Logger:  log.NewSubLogger("llm", &logger.SubLoggerOpts{}),
 		Context: ctx,
 	})
@@ -68,6 +74,8 @@ func main() {
 			60*time.Second,  // min interval
 			120*time.Second, // max interval
 		),
+		twitter.WithTweetTimeout(5*time.Minute),
+		twitter.WithReasoningStorage(true),
 		twitter.WithTwitterCredentials(
 			os.Getenv("TWITTER_CT0"),
 			os.Getenv("TWITTER_AUTH_TOKEN"),
@@ -213,6 +213,7 @@ func (k *Twitter) create() error {
 		engine.WithSessionStore(sessionStore),
 		engine.WithActorStore(actorStore),
 		engine.WithInteractionFragmentStore(interactionFragmentStore),
+		engine.WithReasoningStorage(k.twitterConfig.StoreReasoning),
 		engine.WithManagers(insightManager, personalityManager),
 	)
 	if err != nil {
@@ -82,6 +82,24 @@ func WithTwitterMonitorInterval(min, max time.Duration) options.Option[Twitter]
 	}
 }
 
+// WithTweetTimeout limits how long a single tweet may take to process,
+// including all LLM calls made for it. Tweets that time out are skipped.
+func WithTweetTimeout(timeout time.Duration) options.Option[Twitter] {
+	return func(k *Twitter) error {
+		k.twitterConfig.TweetTimeout = timeout
+		return nil
+	}
+}
+
+// WithReasoningStorage keeps the reasoning behind each reply in the reply's
+// fragment metadata, so replies can be audited later
+func WithReasoningStorage(enabled bool) options.Option[Twitter] {
+	return func(k *Twitter) error {
+		k.twitterConfig.StoreReasoning = enabled
+		return nil
+	}
+}
+
 // WithTwitterCredentials sets the authentication credentials for the Twitter client.
 // ct0: Twitter's ct0 cookie value
 // authToken: Twitter's authentication token
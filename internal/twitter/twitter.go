@@ -1,6 +1,7 @@
 package twitter
 
 import (
+	"context"
 	"fmt"
 	"strings"
 	"time"
@@ -8,6 +9,7 @@ import (
 	"github.com/soralabs/zen/db"
 	"github.com/soralabs/zen/id"
 	"github.com/soralabs/zen/internal/utils"
+	"github.com/soralabs/zen/llm"
 	"github.com/soralabs/zen/managers/insight"
 	"github.com/soralabs/zen/managers/personality"
 	twitter_manager "github.com/soralabs/zen/managers/twitter"
@@ -162,22 +164,32 @@ func (k *Twitter) handleTweetProcessing(tweet *twitter.ParsedTweet) error {
 		"tweet_text":      tweet.TweetText,
 	}).Infof("Processing tweet")
 
+	ctx := k.ctx
+	if k.twitterConfig.TweetTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, k.twitterConfig.TweetTimeout)
+		defer cancel()
+	}
+
 	if err := k.initializeConversationData(tweet); err != nil {
 		return err
 	}
 
-	embedding, err := k.llmClient.EmbedText(tweet.TweetText)
+	embedding, err := k.llmClient.WithScope(llm.UsageScope{
+		SessionID: id.FromString(tweet.TweetConversationID),
+		ActorID:   id.FromString(tweet.UserID),
+	}).EmbedTextContext(ctx, tweet.TweetText)
 	if err != nil {
 		return fmt.Errorf("failed to embed tweet text: %w", err)
 	}
 
 	// Create fragment for the tweet
-	tweetFragment, err := utils.CreateTweetFragment(tweet, id.FromString(tweet.UserID), embedding)
+	tweetFragment, err := utils.CreateTweetFragment(tweet, id.FromString(tweet.UserID), embedding, k.llmClient.EmbeddingModel())
 	if err != nil {
 		return fmt.Errorf("failed to create tweet fragment: %w", err)
 	}
 
-	currentState, err := k.assistant.NewStateFromFragment(tweetFragment)
+	currentState, err := k.assistant.NewStateFromFragmentContext(ctx, tweetFragment)
 	if err != nil {
 		return fmt.Errorf("failed to create state: %w", err)
 	}
@@ -230,22 +242,19 @@ STRICT REQUIREMENTS:
 8. Keep responses concise and tweet-length appropriate
 9. Youre responses should flow naturally from the current branch, but you should also consider the other branches
 
-Twitter Conversation:
-{{.twitter_conversations}}
-
-
-Context for this conversation:
-# Tweet Thread Insights (session = conversation)
-{{.session_insights}}
-
-# User Insights (actor = user)
-{{.actor_insights}}
-
-# Unique Insights
-{{.unique_insights}}
-
 Task:
-You must respond to the user's tweet marked with →`).
+You must respond to the user's tweet marked with → in the Twitter conversation below`).
+		// Context comes in separate sections, least important first, so the
+		// oldest ones can be dropped when the prompt exceeds the context window
+		AddUserSection(`Context for this conversation:
+# Unique Insights
+{{.unique_insights}}`, "").
+		AddUserSection(`# User Insights (actor = user)
+{{.actor_insights}}`, "").
+		AddUserSection(`# Tweet Thread Insights (session = conversation)
+{{.session_insights}}`, "").
+		AddUserSection(`Twitter Conversation:
+{{.twitter_conversations}}`, "").
 		WithManagerData(personality.BasePersonality).
 		WithManagerData(insight.SessionInsights).
 		WithManagerData(insight.ActorInsights).
@@ -258,12 +267,24 @@ You must respond to the user's tweet marked with →`).
 		return nil, fmt.Errorf("failed to build template: %w", err)
 	}
 
+	// Show the model images attached to the tweet, e.g. memes and screenshots.
+	// They are part of the conversation section, which is never dropped.
+	if len(tweet.TweetImages) > 0 && !k.llmClient.Supports(llm.FeatureVision) {
+		k.logger.Warnf("No configured LLM provider supports images, ignoring %d images of tweet %s", len(tweet.TweetImages), tweet.TweetID)
+	} else if len(tweet.TweetImages) > 0 {
+		conversation := &messages[len(messages)-1]
+		conversation.Parts = append(conversation.Parts, llm.TextPart("Images attached to the tweet marked with →:"))
+		for _, image := range tweet.TweetImages {
+			conversation.Parts = append(conversation.Parts, llm.ImageURLPart(image))
+		}
+	}
+
 	k.logger.WithFields(map[string]interface{}{
 		"messages": messages,
 	}).Infof("Generated messages")
 
 	// Get response from LLM
-	responseFragment, err := k.assistant.GenerateResponse(messages, id.FromString(tweet.TweetConversationID))
+	responseFragment, err := k.assistant.GenerateResponseContext(currentState.Context(), messages, id.FromString(tweet.TweetConversationID))
 	if err != nil {
 		return nil, fmt.Errorf("failed to generate response: %w", err)
 	}
@@ -287,8 +308,14 @@ You must respond to the user's tweet marked with →`).
 		return nil, fmt.Errorf("failed to decode tweet metadata: %w", err)
 	}
 
-	// Create response fragment
-	responseFragment.Metadata = metadata
+	// Attach tweet metadata, keeping what the engine recorded (e.g. usage)
+	if responseFragment.Metadata == nil {
+		responseFragment.Metadata = metadata
+	} else {
+		for key, value := range metadata {
+			responseFragment.Metadata[key] = value
+		}
+	}
 
 	return responseFragment, nil
 }
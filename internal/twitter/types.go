@@ -43,4 +43,6 @@ type IntervalConfig struct {
 type TwitterConfig struct {
 	MonitorInterval IntervalConfig
 	Credentials     TwitterCredentials
+	TweetTimeout    time.Duration // Bounds the processing of a single tweet, unlimited if zero
+	StoreReasoning  bool          // Keep the model's reasoning in reply fragment metadata
 }
@@ -14,7 +14,7 @@ import (
 )
 
 // Helper method to create fragment from tweet
-func CreateTweetFragment(tweet *twitter.ParsedTweet, actorId id.ID, embedding []float32) (*db.Fragment, error) {
+func CreateTweetFragment(tweet *twitter.ParsedTweet, actorId id.ID, embedding []float32, embeddingModel string) (*db.Fragment, error) {
 	var metadata db.Metadata
 	decoder, err := mapstructure.NewDecoder(&mapstructure.DecoderConfig{
 		TagName: "json",
@@ -28,13 +28,14 @@ func CreateTweetFragment(tweet *twitter.ParsedTweet, actorId id.ID, embedding []
 	}
 
 	return &db.Fragment{
-		ID:        id.FromString(tweet.TweetID),
-		ActorID:   actorId,
-		SessionID: id.FromString(tweet.TweetConversationID),
-		Content:   tweet.TweetText,
-		Embedding: pgvector.NewVector(embedding),
-		Metadata:  metadata,
-		CreatedAt: time.Unix(tweet.TweetCreatedAt, 0),
+		ID:             id.FromString(tweet.TweetID),
+		ActorID:        actorId,
+		SessionID:      id.FromString(tweet.TweetConversationID),
+		Content:        tweet.TweetText,
+		Embedding:      pgvector.NewVector(embedding),
+		EmbeddingModel: embeddingModel,
+		Metadata:       metadata,
+		CreatedAt:      time.Unix(tweet.TweetCreatedAt, 0),
 	}, nil
 }
 
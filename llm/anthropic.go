@@ -0,0 +1,579 @@
+package llm
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/go-resty/resty/v2"
+	"github.com/soralabs/zen/logger"
+)
+
+const (
+	anthropicAPIVersion       = "2023-06-01"
+	anthropicDefaultMaxTokens = 4096
+)
+
+type AnthropicProvider struct {
+	client         *resty.Client
+	models         map[ModelType]string
+	logger         *logger.Logger
+	roles          map[Role]string
+	thinkingBudget int
+}
+
+// NewAnthropicProvider creates and returns a new AnthropicProvider instance,
+// initializing a default mapping for models and roles if none are provided.
+func NewAnthropicProvider(config Config) *AnthropicProvider {
+	// Default model mapping if not provided
+	models := config.DefaultProvider.ModelConfig
+	if models == nil {
+		models = defaultModels[ProviderAnthropic]
+	}
+
+	// Role mapping. System messages are sent separately and tool results
+	// are sent back as user content blocks.
+	roles := map[Role]string{
+		RoleUser:      "user",
+		RoleAssistant: "assistant",
+		RoleTool:      "user",
+	}
+
+	baseURL := config.DefaultProvider.BaseURL
+	if baseURL == "" {
+		baseURL = "https://api.anthropic.com/v1"
+	}
+
+	client := resty.New().
+		SetBaseURL(baseURL).
+		SetHeader("x-api-key", config.DefaultProvider.APIKey).
+		SetHeader("anthropic-version", anthropicAPIVersion).
+		SetHeader("Content-Type", "application/json").
+		SetHeaders(config.DefaultProvider.Headers).
+		SetTimeout(2 * time.Minute)
+
+	return &AnthropicProvider{
+		client:         client,
+		models:         models,
+		logger:         config.Logger,
+		roles:          roles,
+		thinkingBudget: config.DefaultProvider.ThinkingBudget,
+	}
+}
+
+type anthropicMessagesRequest struct {
+	Model       string               `json:"model"`
+	System      string               `json:"system,omitempty"`
+	Messages    []anthropicMessage   `json:"messages"`
+	MaxTokens   int                  `json:"max_tokens"`
+	Temperature float32              `json:"temperature,omitempty"`
+	Tools       []anthropicTool      `json:"tools,omitempty"`
+	ToolChoice  *anthropicToolChoice `json:"tool_choice,omitempty"`
+	Thinking    *anthropicThinking   `json:"thinking,omitempty"`
+	Stream      bool                 `json:"stream,omitempty"`
+}
+
+type anthropicThinking struct {
+	Type         string `json:"type"`
+	BudgetTokens int    `json:"budget_tokens"`
+}
+
+type anthropicMessage struct {
+	Role    string                  `json:"role"`
+	Content []anthropicContentBlock `json:"content"`
+}
+
+type anthropicContentBlock struct {
+	Type      string                `json:"type"`
+	Text      string                `json:"text,omitempty"`
+	ID        string                `json:"id,omitempty"`
+	Name      string                `json:"name,omitempty"`
+	Input     json.RawMessage       `json:"input,omitempty"`
+	ToolUseID string                `json:"tool_use_id,omitempty"`
+	Content   string                `json:"content,omitempty"`
+	IsError   bool                  `json:"is_error,omitempty"`
+	Source    *anthropicImageSource `json:"source,omitempty"`
+	Thinking  string                `json:"thinking,omitempty"`
+	Signature string                `json:"signature,omitempty"`
+}
+
+type anthropicImageSource struct {
+	Type      string `json:"type"`
+	MediaType string `json:"media_type,omitempty"`
+	Data      string `json:"data,omitempty"`
+	URL       string `json:"url,omitempty"`
+}
+
+type anthropicTool struct {
+	Name        string      `json:"name"`
+	Description string      `json:"description,omitempty"`
+	InputSchema interface{} `json:"input_schema"`
+}
+
+type anthropicToolChoice struct {
+	Type string `json:"type"`
+	Name string `json:"name,omitempty"`
+}
+
+type anthropicMessagesResponse struct {
+	ID         string                  `json:"id"`
+	Type       string                  `json:"type"`
+	Role       string                  `json:"role"`
+	Model      string                  `json:"model"`
+	Content    []anthropicContentBlock `json:"content"`
+	StopReason string                  `json:"stop_reason"`
+	Usage      anthropicUsage          `json:"usage"`
+}
+
+type anthropicUsage struct {
+	InputTokens  int `json:"input_tokens"`
+	OutputTokens int `json:"output_tokens"`
+}
+
+type anthropicStreamEvent struct {
+	Type         string                 `json:"type"`
+	Index        int                    `json:"index"`
+	ContentBlock *anthropicContentBlock `json:"content_block,omitempty"`
+	Message      *struct {
+		Usage anthropicUsage `json:"usage"`
+	} `json:"message,omitempty"`
+	Usage *anthropicUsage `json:"usage,omitempty"`
+	Delta *struct {
+		Type        string `json:"type"`
+		Text        string `json:"text,omitempty"`
+		PartialJSON string `json:"partial_json,omitempty"`
+		Thinking    string `json:"thinking,omitempty"`
+		Signature   string `json:"signature,omitempty"`
+		StopReason  string `json:"stop_reason,omitempty"`
+	} `json:"delta,omitempty"`
+	Error *struct {
+		Type    string `json:"type"`
+		Message string `json:"message"`
+	} `json:"error,omitempty"`
+}
+
+// Capabilities reports chat and vision support. Anthropic has no embeddings API.
+func (p *AnthropicProvider) Capabilities() Capabilities {
+	return Capabilities{
+		Completion:       true,
+		Tools:            true,
+		StructuredOutput: true,
+		Vision:           true,
+		Streaming:        true,
+		MaxContextTokens: maxContextLimit(p.models, nil),
+	}
+}
+
+// GenerateCompletion sends a conversation to the Anthropic Messages API
+// and returns the model's text completion, with its extended thinking as
+// reasoning when enabled.
+func (p *AnthropicProvider) GenerateCompletion(ctx context.Context, req CompletionRequest) (Message, error) {
+	msgReq, err := p.buildMessagesRequest(req)
+	if err != nil {
+		return Message{}, err
+	}
+
+	resp, err := p.createMessage(ctx, msgReq)
+	if err != nil {
+		return Message{}, err
+	}
+
+	usage := p.convertUsage(msgReq.Model, resp.Usage)
+	message := Message{
+		Role:  RoleAssistant,
+		Usage: &usage,
+	}
+	var content, reasoning strings.Builder
+	for _, block := range resp.Content {
+		switch block.Type {
+		case "text":
+			content.WriteString(block.Text)
+		case "thinking":
+			reasoning.WriteString(block.Thinking)
+			message.ReasoningSignature = block.Signature
+		case "tool_use":
+			message.ToolCalls = append(message.ToolCalls, ToolCall{
+				ID:        block.ID,
+				Name:      block.Name,
+				Arguments: string(block.Input),
+			})
+		}
+	}
+	message.Content = content.String()
+	message.Reasoning = reasoning.String()
+
+	return message, nil
+}
+
+// GenerateCompletionStream sends a conversation to the Anthropic Messages API
+// and streams the completion back as server-sent events.
+func (p *AnthropicProvider) GenerateCompletionStream(ctx context.Context, req CompletionRequest) (<-chan StreamEvent, error) {
+	body, err := p.openStream(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	events := make(chan StreamEvent)
+	go func() {
+		defer close(events)
+		defer body.Close()
+
+		var content, arguments, reasoning strings.Builder
+		var signature string
+		var calls []ToolCall
+		var call *ToolCall
+		var streamUsage anthropicUsage
+		err := readSSE(body, func(_, data string) error {
+			var event anthropicStreamEvent
+			if err := json.Unmarshal([]byte(data), &event); err != nil {
+				return fmt.Errorf("failed to parse stream event: %w", err)
+			}
+
+			switch event.Type {
+			case "error":
+				if event.Error != nil {
+					return fmt.Errorf("%s: %s", event.Error.Type, event.Error.Message)
+				}
+				return fmt.Errorf("unknown stream error")
+			case "message_start":
+				if event.Message != nil {
+					streamUsage.InputTokens = event.Message.Usage.InputTokens
+				}
+			case "message_delta":
+				if event.Usage != nil {
+					streamUsage.OutputTokens = event.Usage.OutputTokens
+				}
+			case "content_block_start":
+				if event.ContentBlock != nil && event.ContentBlock.Type == "tool_use" {
+					call = &ToolCall{ID: event.ContentBlock.ID, Name: event.ContentBlock.Name}
+					arguments.Reset()
+				}
+			case "content_block_delta":
+				if event.Delta == nil {
+					return nil
+				}
+				switch event.Delta.Type {
+				case "text_delta":
+					content.WriteString(event.Delta.Text)
+					if !sendStreamEvent(ctx, events, StreamEvent{Type: StreamEventContent, Content: event.Delta.Text}) {
+						return ctx.Err()
+					}
+				case "input_json_delta":
+					arguments.WriteString(event.Delta.PartialJSON)
+				case "thinking_delta":
+					reasoning.WriteString(event.Delta.Thinking)
+				case "signature_delta":
+					signature = event.Delta.Signature
+				}
+			case "content_block_stop":
+				if call != nil {
+					call.Arguments = arguments.String()
+					calls = append(calls, *call)
+					call = nil
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			sendStreamEvent(ctx, events, StreamEvent{Type: StreamEventError, Err: fmt.Errorf("Anthropic stream error: %w", err)})
+			return
+		}
+
+		usage := p.convertUsage(p.getModel(req.ModelType), streamUsage)
+		sendStreamEvent(ctx, events, StreamEvent{
+			Type: StreamEventDone,
+			Message: &Message{
+				Role:               RoleAssistant,
+				Content:            content.String(),
+				ToolCalls:          calls,
+				Reasoning:          reasoning.String(),
+				ReasoningSignature: signature,
+				Usage:              &usage,
+			},
+		})
+	}()
+
+	return events, nil
+}
+
+// GenerateStructuredOutput prompts the Anthropic API to return JSON data by
+// forcing a call to a single tool whose input schema is the result type.
+func (p *AnthropicProvider) GenerateStructuredOutput(ctx context.Context, req StructuredOutputRequest, result interface{}) (Usage, error) {
+	schema, err := GenerateSchema(result)
+	if err != nil {
+		return Usage{}, fmt.Errorf("failed to generate schema: %w", err)
+	}
+
+	toolName := req.SchemaName
+	if toolName == "" {
+		toolName = "structured_output"
+	}
+
+	system, messages := p.convertMessages(req.Messages)
+	msgReq := anthropicMessagesRequest{
+		Model:       p.getModel(req.ModelType),
+		System:      system,
+		Messages:    messages,
+		MaxTokens:   anthropicDefaultMaxTokens,
+		Temperature: req.Temperature,
+		Tools: []anthropicTool{
+			{
+				Name:        toolName,
+				Description: "Respond with the requested data using this tool's input schema",
+				InputSchema: schema,
+			},
+		},
+		ToolChoice: &anthropicToolChoice{
+			Type: "tool",
+			Name: toolName,
+		},
+	}
+
+	resp, err := p.createMessage(ctx, msgReq)
+	if err != nil {
+		return Usage{}, fmt.Errorf("StructuredOutput %w", err)
+	}
+
+	usage := p.convertUsage(msgReq.Model, resp.Usage)
+	for _, block := range resp.Content {
+		if block.Type == "tool_use" && block.Name == toolName {
+			return usage, decodeStructuredOutput(string(block.Input), schema, result)
+		}
+	}
+
+	return usage, fmt.Errorf("no structured output returned")
+}
+
+// EmbedText is not supported as Anthropic does not offer an embeddings API
+func (p *AnthropicProvider) EmbedText(ctx context.Context, text string) ([]float32, Usage, error) {
+	return nil, Usage{}, fmt.Errorf("embeddings not supported by Anthropic API")
+}
+
+// EmbedTexts is not supported as Anthropic does not offer an embeddings API
+func (p *AnthropicProvider) EmbedTexts(ctx context.Context, texts []string) ([][]float32, Usage, error) {
+	return nil, Usage{}, fmt.Errorf("embeddings not supported by Anthropic API")
+}
+
+// convertUsage maps Anthropic token usage to the internal usage format
+func (p *AnthropicProvider) convertUsage(model string, usage anthropicUsage) Usage {
+	return Usage{
+		Provider:         ProviderAnthropic,
+		Model:            model,
+		PromptTokens:     usage.InputTokens,
+		CompletionTokens: usage.OutputTokens,
+		TotalTokens:      usage.InputTokens + usage.OutputTokens,
+	}
+}
+
+// createMessage performs a non-streaming Messages API call
+func (p *AnthropicProvider) createMessage(ctx context.Context, msgReq anthropicMessagesRequest) (*anthropicMessagesResponse, error) {
+	var resp anthropicMessagesResponse
+	httpResp, err := p.client.R().
+		SetContext(ctx).
+		SetBody(msgReq).
+		SetResult(&resp).
+		Post("/messages")
+
+	if err != nil {
+		return nil, newRequestError(ProviderAnthropic, err)
+	}
+
+	if httpResp.StatusCode() != http.StatusOK {
+		return nil, newStatusError(ProviderAnthropic, httpResp.StatusCode(), httpResp.String())
+	}
+
+	if len(resp.Content) == 0 {
+		return nil, fmt.Errorf("no completion returned")
+	}
+
+	return &resp, nil
+}
+
+// openStream starts a streaming Messages API call and returns the raw event stream body
+func (p *AnthropicProvider) openStream(ctx context.Context, req CompletionRequest) (io.ReadCloser, error) {
+	msgReq, err := p.buildMessagesRequest(req)
+	if err != nil {
+		return nil, err
+	}
+	msgReq.Stream = true
+
+	httpResp, err := p.client.R().
+		SetContext(ctx).
+		SetBody(msgReq).
+		SetDoNotParseResponse(true).
+		Post("/messages")
+	if err != nil {
+		return nil, newRequestError(ProviderAnthropic, err)
+	}
+
+	body := httpResp.RawBody()
+	if httpResp.StatusCode() != http.StatusOK {
+		defer body.Close()
+		errBody, _ := io.ReadAll(body)
+		return nil, newStatusError(ProviderAnthropic, httpResp.StatusCode(), string(errBody))
+	}
+
+	return body, nil
+}
+
+// buildMessagesRequest converts a completion request into an Anthropic Messages request
+func (p *AnthropicProvider) buildMessagesRequest(req CompletionRequest) (anthropicMessagesRequest, error) {
+	tools := make([]anthropicTool, len(req.Tools))
+	for i, tool := range req.Tools {
+		schema := tool.GetSchema()
+		var params interface{}
+		if err := json.Unmarshal(schema.Parameters, &params); err != nil {
+			return anthropicMessagesRequest{}, fmt.Errorf("failed to parse tool parameters: %w", err)
+		}
+
+		tools[i] = anthropicTool{
+			Name:        tool.GetName(),
+			Description: tool.GetDescription(),
+			InputSchema: params,
+		}
+	}
+
+	system, messages := p.convertMessages(req.Messages)
+	msgReq := anthropicMessagesRequest{
+		Model:       p.getModel(req.ModelType),
+		System:      system,
+		Messages:    messages,
+		MaxTokens:   anthropicDefaultMaxTokens,
+		Temperature: req.Temperature,
+	}
+	if len(tools) > 0 {
+		msgReq.Tools = tools
+	}
+	// Extended thinking requires the default temperature and counts
+	// towards max_tokens
+	if p.thinkingBudget > 0 {
+		msgReq.Thinking = &anthropicThinking{Type: "enabled", BudgetTokens: p.thinkingBudget}
+		msgReq.MaxTokens += p.thinkingBudget
+		msgReq.Temperature = 0
+	}
+
+	return msgReq, nil
+}
+
+// getModel returns the Anthropic model identifier for the given model type.
+// Falls back to default model if type is not found.
+func (p *AnthropicProvider) getModel(modelType ModelType) string {
+	if model, ok := p.models[modelType]; ok {
+		return model
+	}
+	return p.models[ModelTypeDefault]
+}
+
+// convertMessages transforms internal message format to Anthropic API format.
+// System messages are joined into the separate system prompt, tool calls and
+// results become content blocks, and consecutive messages with the same role
+// are merged since the API requires alternating turns. Tool results are paired
+// with the preceding tool calls in order. Signed reasoning of tool calls is
+// sent back as a thinking block, as extended thinking requires.
+func (p *AnthropicProvider) convertMessages(messages []Message) (string, []anthropicMessage) {
+	var system []string
+	var converted []anthropicMessage
+
+	for _, msg := range pairToolCalls(messages) {
+		if msg.Role == RoleSystem {
+			system = append(system, msg.Text())
+			continue
+		}
+
+		var blocks []anthropicContentBlock
+		switch {
+		case len(msg.ToolCalls) > 0:
+			if msg.Reasoning != "" && msg.ReasoningSignature != "" {
+				blocks = append(blocks, anthropicContentBlock{
+					Type:      "thinking",
+					Thinking:  msg.Reasoning,
+					Signature: msg.ReasoningSignature,
+				})
+			}
+			if msg.Content != "" {
+				blocks = append(blocks, anthropicContentBlock{Type: "text", Text: msg.Content})
+			}
+			for _, call := range msg.ToolCalls {
+				input := json.RawMessage(call.Arguments)
+				if len(input) == 0 {
+					input = json.RawMessage("{}")
+				}
+				blocks = append(blocks, anthropicContentBlock{
+					Type:  "tool_use",
+					ID:    call.ID,
+					Name:  call.Name,
+					Input: input,
+				})
+			}
+		case msg.Role == RoleTool:
+			blocks = append(blocks, anthropicContentBlock{
+				Type:      "tool_result",
+				ToolUseID: msg.ToolCallID,
+				Content:   msg.Content,
+				IsError:   msg.ToolError,
+			})
+		case len(msg.Parts) > 0:
+			if msg.Content != "" {
+				blocks = append(blocks, anthropicContentBlock{Type: "text", Text: msg.Content})
+			}
+			blocks = append(blocks, p.convertParts(msg.Parts)...)
+		case msg.Content != "":
+			blocks = append(blocks, anthropicContentBlock{Type: "text", Text: msg.Content})
+		}
+		// The API rejects empty text blocks and messages
+		if len(blocks) == 0 {
+			continue
+		}
+
+		role := p.mapRole(msg.Role)
+		if n := len(converted); n > 0 && converted[n-1].Role == role {
+			converted[n-1].Content = append(converted[n-1].Content, blocks...)
+			continue
+		}
+		converted = append(converted, anthropicMessage{
+			Role:    role,
+			Content: blocks,
+		})
+	}
+
+	return strings.Join(system, "\n\n"), converted
+}
+
+// convertParts maps message parts to Anthropic text and image blocks
+func (p *AnthropicProvider) convertParts(parts []ContentPart) []anthropicContentBlock {
+	var blocks []anthropicContentBlock
+	for _, part := range parts {
+		switch {
+		case part.Type == ContentPartText && part.Text != "":
+			blocks = append(blocks, anthropicContentBlock{Type: "text", Text: part.Text})
+		case part.Type == ContentPartImage && part.ImageURL != "":
+			blocks = append(blocks, anthropicContentBlock{
+				Type:   "image",
+				Source: &anthropicImageSource{Type: "url", URL: part.ImageURL},
+			})
+		case part.Type == ContentPartImage:
+			blocks = append(blocks, anthropicContentBlock{
+				Type: "image",
+				Source: &anthropicImageSource{
+					Type:      "base64",
+					MediaType: part.mediaType(),
+					Data:      base64.StdEncoding.EncodeToString(part.ImageData),
+				},
+			})
+		}
+	}
+	return blocks
+}
+
+// mapRole converts internal role types to Anthropic API role strings.
+func (p *AnthropicProvider) mapRole(role Role) string {
+	if mappedRole, ok := p.roles[role]; ok {
+		return mappedRole
+	}
+	return string(role)
+}
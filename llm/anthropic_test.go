@@ -0,0 +1,314 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"reflect"
+	"testing"
+
+	toolkit "github.com/soralabs/toolkit/go"
+)
+
+func newTestAnthropicProvider(server *fakeServer) *AnthropicProvider {
+	return NewAnthropicProvider(Config{
+		DefaultProvider: ProviderConfig{
+			Type:    ProviderAnthropic,
+			APIKey:  "test-key",
+			BaseURL: server.URL,
+		},
+	})
+}
+
+func TestAnthropicSystemPrompt(t *testing.T) {
+	server := newFakeServer(t, func(w http.ResponseWriter, req capturedRequest) {
+		writeJSON(w, `{
+			"role": "assistant",
+			"content": [{"type": "text", "text": "Hello"}, {"type": "text", "text": " there"}],
+			"usage": {"input_tokens": 12, "output_tokens": 3}
+		}`)
+	})
+	provider := newTestAnthropicProvider(server)
+
+	msg, err := provider.GenerateCompletion(context.Background(), CompletionRequest{
+		Messages: []Message{
+			NewSystemMessage("Be brief."),
+			NewSystemMessage("Be kind."),
+			NewUserMessage("Hi"),
+			NewAssistantMessage(""),
+			NewUserMessage("Still there?"),
+		},
+		ModelType: ModelTypeFast,
+	})
+	if err != nil {
+		t.Fatalf("GenerateCompletion: %v", err)
+	}
+	if msg.Content != "Hello there" {
+		t.Errorf("content = %q, want %q", msg.Content, "Hello there")
+	}
+	if msg.Usage == nil || msg.Usage.PromptTokens != 12 || msg.Usage.CompletionTokens != 3 || msg.Usage.TotalTokens != 15 {
+		t.Errorf("usage = %+v", msg.Usage)
+	}
+
+	req := server.last(t)
+	if req.Path != "/messages" {
+		t.Errorf("path = %s, want /messages", req.Path)
+	}
+	if got := req.Header.Get("x-api-key"); got != "test-key" {
+		t.Errorf("x-api-key = %q", got)
+	}
+	if got := req.Header.Get("anthropic-version"); got != anthropicAPIVersion {
+		t.Errorf("anthropic-version = %q", got)
+	}
+	if got := req.Body["system"]; got != "Be brief.\n\nBe kind." {
+		t.Errorf("system = %q", got)
+	}
+	if got := req.Body["model"]; got != defaultModels[ProviderAnthropic][ModelTypeFast] {
+		t.Errorf("model = %v", got)
+	}
+
+	// System messages leave the conversation and the empty assistant
+	// message is dropped, merging the user turns around it
+	messages := req.Body["messages"].([]interface{})
+	if len(messages) != 1 {
+		t.Fatalf("messages = %v, want a single user turn", messages)
+	}
+	if got := jsonPath(t, messages, "0.role"); got != "user" {
+		t.Errorf("role = %v", got)
+	}
+	blocks := jsonPath(t, messages, "0.content").([]interface{})
+	if len(blocks) != 2 || jsonPath(t, blocks, "0.text") != "Hi" || jsonPath(t, blocks, "1.text") != "Still there?" {
+		t.Errorf("content blocks = %v", blocks)
+	}
+}
+
+func TestAnthropicToolUse(t *testing.T) {
+	server := newFakeServer(t, func(w http.ResponseWriter, req capturedRequest) {
+		writeJSON(w, `{
+			"role": "assistant",
+			"content": [
+				{"type": "text", "text": "Let me look."},
+				{"type": "tool_use", "id": "toolu_1", "name": "search", "input": {"query": "go"}}
+			],
+			"stop_reason": "tool_use",
+			"usage": {"input_tokens": 20, "output_tokens": 8}
+		}`)
+	})
+	provider := newTestAnthropicProvider(server)
+	tool := &fakeTool{name: "search"}
+
+	msg, err := provider.GenerateCompletion(context.Background(), CompletionRequest{
+		Messages: []Message{NewUserMessage("Search for go")},
+		Tools:    []toolkit.Tool{tool},
+	})
+	if err != nil {
+		t.Fatalf("GenerateCompletion: %v", err)
+	}
+	want := []ToolCall{{ID: "toolu_1", Name: "search", Arguments: `{"query": "go"}`}}
+	if !reflect.DeepEqual(msg.ToolCalls, want) {
+		t.Errorf("tool calls = %+v, want %+v", msg.ToolCalls, want)
+	}
+
+	req := server.last(t)
+	if got := jsonPath(t, req.Body, "tools.0.name"); got != "search" {
+		t.Errorf("tool name = %v", got)
+	}
+	if got := jsonPath(t, req.Body, "tools.0.input_schema.properties.query.type"); got != "string" {
+		t.Errorf("tool input schema = %v", jsonPath(t, req.Body, "tools.0.input_schema"))
+	}
+
+	// Send the calls and their results back
+	failed := NewToolResultMessage("error: timeout", "search", "toolu_2")
+	failed.ToolError = true
+	assistant := msg
+	assistant.ToolCalls = append(assistant.ToolCalls, ToolCall{ID: "toolu_2", Name: "search", Arguments: `{"query": "rust"}`})
+	_, err = provider.GenerateCompletion(context.Background(), CompletionRequest{
+		Messages: []Message{
+			NewUserMessage("Search for go"),
+			assistant,
+			NewToolResultMessage(`{"hits": 3}`, "search", "toolu_1"),
+			failed,
+		},
+		Tools: []toolkit.Tool{tool},
+	})
+	if err != nil {
+		t.Fatalf("GenerateCompletion: %v", err)
+	}
+
+	messages := server.last(t).Body["messages"].([]interface{})
+	if len(messages) != 3 {
+		t.Fatalf("messages = %v, want user, assistant and tool results", messages)
+	}
+	if got := jsonPath(t, messages, "1.role"); got != "assistant" {
+		t.Errorf("tool call role = %v", got)
+	}
+	if got := jsonPath(t, messages, "1.content.0.text"); got != "Let me look." {
+		t.Errorf("tool call text = %v", got)
+	}
+	if got := jsonPath(t, messages, "1.content.1.type"); got != "tool_use" {
+		t.Errorf("tool call block = %v", got)
+	}
+	if got := jsonPath(t, messages, "1.content.1.input.query"); got != "go" {
+		t.Errorf("tool call input = %v", got)
+	}
+	if got := jsonPath(t, messages, "2.role"); got != "user" {
+		t.Errorf("tool result role = %v", got)
+	}
+	results := jsonPath(t, messages, "2.content").([]interface{})
+	if len(results) != 2 {
+		t.Fatalf("tool results = %v", results)
+	}
+	for i, want := range []struct {
+		id      string
+		content string
+		isError interface{}
+	}{
+		{"toolu_1", `{"hits": 3}`, nil},
+		{"toolu_2", "error: timeout", true},
+	} {
+		result := results[i].(map[string]interface{})
+		if result["type"] != "tool_result" || result["tool_use_id"] != want.id || result["content"] != want.content || result["is_error"] != want.isError {
+			t.Errorf("tool result %d = %v", i, result)
+		}
+	}
+}
+
+func TestAnthropicStructuredOutput(t *testing.T) {
+	input := `{"name": "Ada", "age": 36}`
+	server := newFakeServer(t, func(w http.ResponseWriter, req capturedRequest) {
+		writeJSON(w, `{
+			"role": "assistant",
+			"content": [{"type": "tool_use", "id": "toolu_1", "name": "person", "input": `+input+`}],
+			"usage": {"input_tokens": 30, "output_tokens": 10}
+		}`)
+	})
+	provider := newTestAnthropicProvider(server)
+
+	var person struct {
+		Name string `json:"name" jsonschema:"required"`
+		Age  int    `json:"age" jsonschema:"required"`
+	}
+	usage, err := provider.GenerateStructuredOutput(context.Background(), StructuredOutputRequest{
+		Messages:   []Message{NewUserMessage("Who wrote the first program?")},
+		SchemaName: "person",
+	}, &person)
+	if err != nil {
+		t.Fatalf("GenerateStructuredOutput: %v", err)
+	}
+	if person.Name != "Ada" || person.Age != 36 {
+		t.Errorf("result = %+v", person)
+	}
+	if usage.TotalTokens != 40 {
+		t.Errorf("usage = %+v", usage)
+	}
+
+	req := server.last(t)
+	if got := jsonPath(t, req.Body, "tool_choice.type"); got != "tool" {
+		t.Errorf("tool_choice.type = %v", got)
+	}
+	if got := jsonPath(t, req.Body, "tool_choice.name"); got != "person" {
+		t.Errorf("tool_choice.name = %v", got)
+	}
+	if got := jsonPath(t, req.Body, "tools.0.input_schema.required"); !reflect.DeepEqual(got, []interface{}{"name", "age"}) {
+		t.Errorf("required = %v", got)
+	}
+
+	// Input missing a required property fails validation
+	input = `{"name": "Ada"}`
+	_, err = provider.GenerateStructuredOutput(context.Background(), StructuredOutputRequest{
+		Messages:   []Message{NewUserMessage("Who wrote the first program?")},
+		SchemaName: "person",
+	}, &person)
+	var validationErr *ValidationError
+	if !errors.As(err, &validationErr) {
+		t.Fatalf("err = %v, want a ValidationError", err)
+	}
+}
+
+func TestAnthropicStream(t *testing.T) {
+	server := newFakeServer(t, func(w http.ResponseWriter, req capturedRequest) {
+		writeSSE(w,
+			`{"type":"message_start","message":{"usage":{"input_tokens":9,"output_tokens":1}}}`,
+			`{"type":"content_block_start","index":0,"content_block":{"type":"text","text":""}}`,
+			`{"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":"Hel"}}`,
+			`{"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":"lo"}}`,
+			`{"type":"content_block_stop","index":0}`,
+			`{"type":"content_block_start","index":1,"content_block":{"type":"tool_use","id":"toolu_1","name":"search"}}`,
+			`{"type":"content_block_delta","index":1,"delta":{"type":"input_json_delta","partial_json":"{\"query\":"}}`,
+			`{"type":"content_block_delta","index":1,"delta":{"type":"input_json_delta","partial_json":"\"go\"}"}}`,
+			`{"type":"content_block_stop","index":1}`,
+			`{"type":"message_delta","delta":{"stop_reason":"tool_use"},"usage":{"output_tokens":7}}`,
+			`{"type":"message_stop"}`,
+		)
+	})
+	provider := newTestAnthropicProvider(server)
+
+	events, err := provider.GenerateCompletionStream(context.Background(), CompletionRequest{
+		Messages: []Message{NewUserMessage("Hi")},
+		Tools:    []toolkit.Tool{&fakeTool{name: "search"}},
+	})
+	if err != nil {
+		t.Fatalf("GenerateCompletionStream: %v", err)
+	}
+	content, final, err := collectStream(t, events)
+	if err != nil {
+		t.Fatalf("stream error: %v", err)
+	}
+	if content != "Hello" {
+		t.Errorf("streamed content = %q", content)
+	}
+	if final == nil {
+		t.Fatal("no final message")
+	}
+	if final.Content != "Hello" {
+		t.Errorf("final content = %q", final.Content)
+	}
+	want := []ToolCall{{ID: "toolu_1", Name: "search", Arguments: `{"query":"go"}`}}
+	if !reflect.DeepEqual(final.ToolCalls, want) {
+		t.Errorf("tool calls = %+v, want %+v", final.ToolCalls, want)
+	}
+	if final.Usage.PromptTokens != 9 || final.Usage.CompletionTokens != 7 {
+		t.Errorf("usage = %+v", final.Usage)
+	}
+	if got := server.last(t).Body["stream"]; got != true {
+		t.Errorf("stream = %v", got)
+	}
+}
+
+func TestAnthropicStreamError(t *testing.T) {
+	server := newFakeServer(t, func(w http.ResponseWriter, req capturedRequest) {
+		writeSSE(w,
+			`{"type":"message_start","message":{"usage":{"input_tokens":9}}}`,
+			`{"type":"error","error":{"type":"overloaded_error","message":"Overloaded"}}`,
+		)
+	})
+	provider := newTestAnthropicProvider(server)
+
+	events, err := provider.GenerateCompletionStream(context.Background(), CompletionRequest{
+		Messages: []Message{NewUserMessage("Hi")},
+	})
+	if err != nil {
+		t.Fatalf("GenerateCompletionStream: %v", err)
+	}
+	if _, final, err := collectStream(t, events); err == nil || final != nil {
+		t.Errorf("final = %v, err = %v, want a stream error", final, err)
+	}
+}
+
+func TestAnthropicStatusError(t *testing.T) {
+	server := newFakeServer(t, func(w http.ResponseWriter, req capturedRequest) {
+		w.WriteHeader(http.StatusTooManyRequests)
+		writeJSON(w, `{"type":"error","error":{"type":"rate_limit_error","message":"slow down"}}`)
+	})
+	provider := newTestAnthropicProvider(server)
+
+	_, err := provider.GenerateCompletion(context.Background(), CompletionRequest{
+		Messages: []Message{NewUserMessage("Hi")},
+	})
+	if err == nil {
+		t.Fatal("expected an error")
+	}
+	if kind := ClassifyError(err); kind != ErrorKindRateLimit {
+		t.Errorf("kind = %s, want %s", kind, ErrorKindRateLimit)
+	}
+}
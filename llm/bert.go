@@ -0,0 +1,487 @@
+package llm
+
+import (
+	"fmt"
+	"math"
+	"runtime"
+	"strings"
+	"sync"
+	"unicode"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// BERT pooling types of llama.cpp GGUF files
+const (
+	bertPoolingMean = 1
+	bertPoolingCLS  = 2
+)
+
+// bertMaxWordChars is the longest word WordPiece splits before using [UNK]
+const bertMaxWordChars = 100
+
+// bertModel is a BERT sentence-embedding model, e.g. all-MiniLM-L6-v2 or
+// bge-small, in the GGUF layout written by llama.cpp's converter
+type bertModel struct {
+	vocab *wordPieceVocab
+
+	nEmbd   int
+	nHead   int
+	nCtx    int
+	eps     float32
+	pooling int
+
+	tokenEmbd []float32 // nVocab rows of nEmbd
+	posEmbd   []float32 // nCtx rows of nEmbd
+	typeEmbd  []float32 // First token type row, nil if the model has none
+	embdNorm  layerNorm
+	layers    []bertLayer
+}
+
+type bertLayer struct {
+	q, k, v, output linear
+	attnNorm        layerNorm
+	up, down        linear
+	outputNorm      layerNorm
+}
+
+// linear is a dense layer with weights of out rows of in values
+type linear struct {
+	weight  []float32
+	bias    []float32
+	in, out int
+}
+
+type layerNorm struct {
+	weight, bias []float32
+}
+
+// loadBERTModel reads a BERT model from a GGUF file
+func loadBERTModel(path string) (*bertModel, error) {
+	file, err := readGGUF(path)
+	if err != nil {
+		return nil, err
+	}
+
+	arch := file.metaString("general.architecture")
+	if arch != "bert" {
+		return nil, fmt.Errorf("unsupported model architecture %q, expected a bert sentence-embedding model", arch)
+	}
+	nEmbd, _ := file.metaInt("bert.embedding_length")
+	nHead, _ := file.metaInt("bert.attention.head_count")
+	nLayer, _ := file.metaInt("bert.block_count")
+	nFF, _ := file.metaInt("bert.feed_forward_length")
+	nCtx, _ := file.metaInt("bert.context_length")
+	if nEmbd <= 0 || nHead <= 0 || nEmbd%nHead != 0 || nLayer <= 0 || nFF <= 0 || nCtx <= 2 {
+		return nil, fmt.Errorf("invalid bert hyperparameters: embedding %d, heads %d, layers %d, feed forward %d, context %d", nEmbd, nHead, nLayer, nFF, nCtx)
+	}
+
+	model := &bertModel{
+		nEmbd:   nEmbd,
+		nHead:   nHead,
+		nCtx:    nCtx,
+		eps:     1e-12,
+		pooling: bertPoolingMean,
+	}
+	if eps, ok := file.metaFloat("bert.attention.layer_norm_epsilon"); ok {
+		model.eps = float32(eps)
+	}
+	if pooling, ok := file.metaInt("bert.pooling_type"); ok && pooling == bertPoolingCLS {
+		model.pooling = bertPoolingCLS
+	}
+
+	if model.vocab, err = newWordPieceVocab(file); err != nil {
+		return nil, err
+	}
+	nVocab := len(model.vocab.tokens)
+
+	if model.tokenEmbd, err = file.readTensor("token_embd.weight", nEmbd, nVocab); err != nil {
+		return nil, err
+	}
+	if model.posEmbd, err = file.readTensor("position_embd.weight", nEmbd, nCtx); err != nil {
+		return nil, err
+	}
+	if tensor, ok := file.tensors["token_types.weight"]; ok && len(tensor.dims) == 2 {
+		types, err := file.readTensor("token_types.weight", nEmbd, int(tensor.dims[1]))
+		if err != nil {
+			return nil, err
+		}
+		model.typeEmbd = types[:nEmbd]
+	}
+	if model.embdNorm, err = readLayerNorm(file, "token_embd_norm", nEmbd); err != nil {
+		return nil, err
+	}
+
+	model.layers = make([]bertLayer, nLayer)
+	for i := range model.layers {
+		layer := &model.layers[i]
+		prefix := fmt.Sprintf("blk.%d.", i)
+		for _, dense := range []struct {
+			target  *linear
+			name    string
+			in, out int
+		}{
+			{&layer.q, "attn_q", nEmbd, nEmbd},
+			{&layer.k, "attn_k", nEmbd, nEmbd},
+			{&layer.v, "attn_v", nEmbd, nEmbd},
+			{&layer.output, "attn_output", nEmbd, nEmbd},
+			{&layer.up, "ffn_up", nEmbd, nFF},
+			{&layer.down, "ffn_down", nFF, nEmbd},
+		} {
+			if *dense.target, err = readLinear(file, prefix+dense.name, dense.in, dense.out); err != nil {
+				return nil, err
+			}
+		}
+		if layer.attnNorm, err = readLayerNorm(file, prefix+"attn_output_norm", nEmbd); err != nil {
+			return nil, err
+		}
+		if layer.outputNorm, err = readLayerNorm(file, prefix+"layer_output_norm", nEmbd); err != nil {
+			return nil, err
+		}
+	}
+
+	return model, nil
+}
+
+func readLinear(file *ggufFile, name string, in, out int) (linear, error) {
+	weight, err := file.readTensor(name+".weight", in, out)
+	if err != nil {
+		return linear{}, err
+	}
+	bias, err := file.readTensor(name+".bias", out)
+	if err != nil {
+		return linear{}, err
+	}
+	return linear{weight: weight, bias: bias, in: in, out: out}, nil
+}
+
+func readLayerNorm(file *ggufFile, name string, n int) (layerNorm, error) {
+	weight, err := file.readTensor(name+".weight", n)
+	if err != nil {
+		return layerNorm{}, err
+	}
+	bias, err := file.readTensor(name+".bias", n)
+	if err != nil {
+		return layerNorm{}, err
+	}
+	return layerNorm{weight: weight, bias: bias}, nil
+}
+
+// embed returns the normalized sentence embedding of text and its token count
+func (m *bertModel) embed(text string) ([]float32, int) {
+	tokens := m.vocab.tokenize(text, m.nCtx)
+	n, d := len(tokens), m.nEmbd
+
+	x := make([][]float32, n)
+	for i, token := range tokens {
+		x[i] = make([]float32, d)
+		tokenRow := m.tokenEmbd[token*d : (token+1)*d]
+		posRow := m.posEmbd[i*d : (i+1)*d]
+		for j := range x[i] {
+			x[i][j] = tokenRow[j] + posRow[j]
+			if m.typeEmbd != nil {
+				x[i][j] += m.typeEmbd[j]
+			}
+		}
+		m.embdNorm.apply(x[i], m.eps)
+	}
+
+	for _, layer := range m.layers {
+		attention := layer.output.apply(m.attention(layer.q.apply(x), layer.k.apply(x), layer.v.apply(x)))
+		for i := range x {
+			addInPlace(attention[i], x[i])
+			layer.attnNorm.apply(attention[i], m.eps)
+		}
+		x = attention
+
+		hidden := layer.up.apply(x)
+		for _, row := range hidden {
+			for j, value := range row {
+				row[j] = gelu(value)
+			}
+		}
+		output := layer.down.apply(hidden)
+		for i := range x {
+			addInPlace(output[i], x[i])
+			layer.outputNorm.apply(output[i], m.eps)
+		}
+		x = output
+	}
+
+	embedding := make([]float32, d)
+	if m.pooling == bertPoolingCLS {
+		copy(embedding, x[0])
+	} else {
+		for _, row := range x {
+			addInPlace(embedding, row)
+		}
+		for j := range embedding {
+			embedding[j] /= float32(n)
+		}
+	}
+	return normalize(embedding), n
+}
+
+// attention computes bidirectional multi-head self-attention
+func (m *bertModel) attention(q, k, v [][]float32) [][]float32 {
+	n := len(q)
+	headDim := m.nEmbd / m.nHead
+	scale := float32(1 / math.Sqrt(float64(headDim)))
+
+	out := make([][]float32, n)
+	scores := make([]float32, n)
+	for i := range out {
+		out[i] = make([]float32, m.nEmbd)
+		for h := 0; h < m.nHead; h++ {
+			lo, hi := h*headDim, (h+1)*headDim
+			maxScore := float32(math.Inf(-1))
+			for j := range scores {
+				scores[j] = dot(q[i][lo:hi], k[j][lo:hi]) * scale
+				maxScore = max(maxScore, scores[j])
+			}
+			var sum float32
+			for j := range scores {
+				scores[j] = float32(math.Exp(float64(scores[j] - maxScore)))
+				sum += scores[j]
+			}
+			for j := range scores {
+				weight := scores[j] / sum
+				for c := lo; c < hi; c++ {
+					out[i][c] += weight * v[j][c]
+				}
+			}
+		}
+	}
+	return out
+}
+
+// apply multiplies every row of x with the layer, splitting the output
+// neurons across CPUs
+func (l linear) apply(x [][]float32) [][]float32 {
+	out := make([][]float32, len(x))
+	for i := range out {
+		out[i] = make([]float32, l.out)
+	}
+
+	workers := min(runtime.GOMAXPROCS(0), l.out)
+	chunk := (l.out + workers - 1) / workers
+	var wg sync.WaitGroup
+	for start := 0; start < l.out; start += chunk {
+		end := min(start+chunk, l.out)
+		wg.Add(1)
+		go func(start, end int) {
+			defer wg.Done()
+			for o := start; o < end; o++ {
+				row := l.weight[o*l.in : (o+1)*l.in]
+				for i := range x {
+					out[i][o] = dot(row, x[i]) + l.bias[o]
+				}
+			}
+		}(start, end)
+	}
+	wg.Wait()
+	return out
+}
+
+// apply normalizes x in place
+func (n layerNorm) apply(x []float32, eps float32) {
+	var mean float32
+	for _, value := range x {
+		mean += value
+	}
+	mean /= float32(len(x))
+
+	var variance float32
+	for _, value := range x {
+		variance += (value - mean) * (value - mean)
+	}
+	variance /= float32(len(x))
+
+	inv := float32(1 / math.Sqrt(float64(variance+eps)))
+	for i, value := range x {
+		x[i] = (value-mean)*inv*n.weight[i] + n.bias[i]
+	}
+}
+
+func dot(a, b []float32) float32 {
+	var sum float32
+	for i := range a {
+		sum += a[i] * b[i]
+	}
+	return sum
+}
+
+func addInPlace(dst, src []float32) {
+	for i := range dst {
+		dst[i] += src[i]
+	}
+}
+
+// gelu is the exact GELU activation used by BERT
+func gelu(x float32) float32 {
+	return 0.5 * x * (1 + float32(math.Erf(float64(x)/math.Sqrt2)))
+}
+
+// wordPieceVocab tokenizes text like the uncased BERT tokenizer
+type wordPieceVocab struct {
+	tokens       []string
+	wordStart    map[string]int // Pieces that start a word
+	continuation map[string]int // Pieces that continue a word
+	cls, sep     int
+	unk          int
+}
+
+// newWordPieceVocab reads the vocabulary of a GGUF file. llama.cpp marks
+// word-start pieces with a leading "▁" and strips the "##" of continuation
+// pieces, files keeping the original BERT vocabulary use "##" instead.
+func newWordPieceVocab(file *ggufFile) (*wordPieceVocab, error) {
+	if model := file.metaString("tokenizer.ggml.model"); model != "bert" {
+		return nil, fmt.Errorf("unsupported tokenizer %q, expected bert", model)
+	}
+	tokens, _ := file.metadata["tokenizer.ggml.tokens"].([]string)
+	if len(tokens) == 0 {
+		return nil, fmt.Errorf("model has no vocabulary")
+	}
+
+	hashStyle := false
+	for _, token := range tokens {
+		if strings.HasPrefix(token, "##") {
+			hashStyle = true
+			break
+		}
+	}
+
+	vocab := &wordPieceVocab{
+		tokens:       tokens,
+		wordStart:    make(map[string]int, len(tokens)),
+		continuation: make(map[string]int, len(tokens)),
+	}
+	special := make(map[string]int)
+	for id, token := range tokens {
+		switch {
+		case strings.HasPrefix(token, "[") && strings.HasSuffix(token, "]"):
+			special[token] = id
+		case hashStyle && strings.HasPrefix(token, "##"):
+			vocab.continuation[token[2:]] = id
+		case hashStyle:
+			vocab.wordStart[token] = id
+		case strings.HasPrefix(token, "▁"):
+			vocab.wordStart[strings.TrimPrefix(token, "▁")] = id
+		default:
+			vocab.continuation[token] = id
+		}
+	}
+
+	tokenID := func(name string, keys ...string) (int, error) {
+		for _, key := range keys {
+			if id, ok := file.metaInt("tokenizer.ggml." + key); ok && id >= 0 && id < len(tokens) {
+				return id, nil
+			}
+		}
+		if id, ok := special[name]; ok {
+			return id, nil
+		}
+		return 0, fmt.Errorf("vocabulary has no %s token", name)
+	}
+	var err error
+	if vocab.cls, err = tokenID("[CLS]", "cls_token_id", "bos_token_id"); err != nil {
+		return nil, err
+	}
+	if vocab.sep, err = tokenID("[SEP]", "seperator_token_id", "separator_token_id", "eos_token_id"); err != nil {
+		return nil, err
+	}
+	if vocab.unk, err = tokenID("[UNK]", "unknown_token_id"); err != nil {
+		return nil, err
+	}
+	return vocab, nil
+}
+
+// tokenize returns the token IDs of text framed by [CLS] and [SEP], truncated
+// to maxTokens
+func (v *wordPieceVocab) tokenize(text string, maxTokens int) []int {
+	ids := []int{v.cls}
+	for _, word := range bertWords(text) {
+		ids = append(ids, v.wordPieces(word)...)
+		if len(ids) >= maxTokens-1 {
+			ids = ids[:maxTokens-1]
+			break
+		}
+	}
+	return append(ids, v.sep)
+}
+
+// wordPieces splits a word into the longest pieces of the vocabulary
+func (v *wordPieceVocab) wordPieces(word string) []int {
+	runes := []rune(word)
+	if len(runes) > bertMaxWordChars {
+		return []int{v.unk}
+	}
+
+	var ids []int
+	for start := 0; start < len(runes); {
+		pieces := v.wordStart
+		if start > 0 {
+			pieces = v.continuation
+		}
+		end := len(runes)
+		for ; end > start; end-- {
+			if id, ok := pieces[string(runes[start:end])]; ok {
+				ids = append(ids, id)
+				break
+			}
+		}
+		if end == start {
+			return []int{v.unk}
+		}
+		start = end
+	}
+	return ids
+}
+
+// bertWords lowercases text, strips accents and splits it at whitespace,
+// punctuation and CJK characters like BERT's basic tokenizer
+func bertWords(text string) []string {
+	var words []string
+	var word strings.Builder
+	flush := func() {
+		if word.Len() > 0 {
+			words = append(words, word.String())
+			word.Reset()
+		}
+	}
+
+	for _, r := range norm.NFD.String(strings.ToLower(text)) {
+		switch {
+		case r == 0 || r == unicode.ReplacementChar || unicode.Is(unicode.Mn, r) || unicode.IsControl(r) && !unicode.IsSpace(r):
+			continue
+		case unicode.IsSpace(r):
+			flush()
+		case isBERTPunctuation(r) || isCJK(r):
+			flush()
+			words = append(words, string(r))
+		default:
+			word.WriteRune(r)
+		}
+	}
+	flush()
+	return words
+}
+
+// isBERTPunctuation treats all non-alphanumeric ASCII as punctuation, like BERT
+func isBERTPunctuation(r rune) bool {
+	if r >= 33 && r <= 47 || r >= 58 && r <= 64 || r >= 91 && r <= 96 || r >= 123 && r <= 126 {
+		return true
+	}
+	return unicode.IsPunct(r)
+}
+
+func isCJK(r rune) bool {
+	return r >= 0x4E00 && r <= 0x9FFF ||
+		r >= 0x3400 && r <= 0x4DBF ||
+		r >= 0x20000 && r <= 0x2A6DF ||
+		r >= 0x2A700 && r <= 0x2B73F ||
+		r >= 0x2B740 && r <= 0x2B81F ||
+		r >= 0x2B820 && r <= 0x2CEAF ||
+		r >= 0xF900 && r <= 0xFAFF ||
+		r >= 0x2F800 && r <= 0x2FA1F
+}
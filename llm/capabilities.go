@@ -0,0 +1,171 @@
+package llm
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrUnsupportedFeature is returned when no configured provider supports a
+// feature a call needs, e.g. tools or image inputs
+var ErrUnsupportedFeature = errors.New("feature not supported by any configured provider")
+
+// Feature is a capability a call can require from a provider
+type Feature string
+
+const (
+	FeatureCompletion       Feature = "completion"
+	FeatureTools            Feature = "tools"
+	FeatureStructuredOutput Feature = "structured_output"
+	FeatureEmbeddings       Feature = "embeddings"
+	FeatureVision           Feature = "vision"
+	FeatureStreaming        Feature = "streaming"
+)
+
+// Capabilities describes the features a provider supports
+type Capabilities struct {
+	Completion       bool // Chat completions
+	Tools            bool // Function calling
+	StructuredOutput bool // Output following a JSON schema
+	Embeddings       bool
+	Vision           bool // Image content parts
+	Streaming        bool
+	MaxContextTokens int // Largest context window among the provider's chat models, zero without chat
+}
+
+// Supports reports whether the capabilities include a feature
+func (c Capabilities) Supports(feature Feature) bool {
+	switch feature {
+	case FeatureCompletion:
+		return c.Completion
+	case FeatureTools:
+		return c.Tools
+	case FeatureStructuredOutput:
+		return c.StructuredOutput
+	case FeatureEmbeddings:
+		return c.Embeddings
+	case FeatureVision:
+		return c.Vision
+	case FeatureStreaming:
+		return c.Streaming
+	}
+	return false
+}
+
+// missing returns the first of the features the capabilities lack
+func (c Capabilities) missing(features []Feature) (Feature, bool) {
+	for _, feature := range features {
+		if !c.Supports(feature) {
+			return feature, true
+		}
+	}
+	return "", false
+}
+
+// maxContextLimit returns the largest context window among the models,
+// preferring the overrides over DefaultContextLimits
+func maxContextLimit(models map[ModelType]string, overrides map[string]int) int {
+	limit := 0
+	for _, model := range models {
+		limit = max(limit, contextLimit(model, overrides))
+	}
+	return limit
+}
+
+// requireFeature rejects a provider that lacks a feature of the role it is configured for
+func requireFeature(provider Provider, config ProviderConfig, role string, feature Feature) error {
+	if provider.Capabilities().Supports(feature) {
+		return nil
+	}
+	return fmt.Errorf("%s provider %s does not support %s", role, config.Type, feature)
+}
+
+// validateProviders checks that a provider and its fallbacks support the
+// feature of their role
+func validateProviders(providers []Provider, configs []ProviderConfig, role string, feature Feature) error {
+	for i, provider := range providers {
+		if err := requireFeature(provider, configs[i], role, feature); err != nil {
+			if i > 0 {
+				return fmt.Errorf("fallback %d: %w", i, err)
+			}
+			return err
+		}
+	}
+	return nil
+}
+
+// completionFeatures returns the features a completion request needs
+func completionFeatures(req CompletionRequest, stream bool) []Feature {
+	features := []Feature{FeatureCompletion}
+	if len(req.Tools) > 0 {
+		features = append(features, FeatureTools)
+	}
+	if hasImages(req.Messages) {
+		features = append(features, FeatureVision)
+	}
+	if stream {
+		features = append(features, FeatureStreaming)
+	}
+	return features
+}
+
+// structuredOutputFeatures returns the features a structured output request needs
+func structuredOutputFeatures(req StructuredOutputRequest) []Feature {
+	features := []Feature{FeatureStructuredOutput}
+	if hasImages(req.Messages) {
+		features = append(features, FeatureVision)
+	}
+	return features
+}
+
+func hasImages(messages []Message) bool {
+	for _, msg := range messages {
+		if msg.imageCount() > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// Supports reports whether any configured provider supports a feature, e.g.
+// to attach images only when a vision-capable provider can see them
+func (c *LLMClient) Supports(feature Feature) bool {
+	return len(capableProviders(c.providers, []Feature{feature})) > 0
+}
+
+// chatProvidersFor returns the providers of the route matching the request,
+// or the chat provider and its fallbacks, that support the features. When
+// none of them does, other configured providers that do are used instead.
+// Without any capable provider, e.g. for images without a vision-capable
+// provider, ErrUnsupportedFeature is returned rather than dropping content.
+func (c *LLMClient) chatProvidersFor(req routeRequest, features []Feature) ([]Provider, error) {
+	chain, ok := c.router.route(req)
+	if !ok {
+		chain = c.chatProviders
+	}
+
+	capable := capableProviders(chain, features)
+	if len(capable) == len(chain) {
+		return chain, nil
+	}
+	if len(capable) == 0 {
+		capable = capableProviders(c.providers, features)
+		if len(capable) > 0 && c.logger != nil {
+			c.logger.Infof("Chat providers lack a feature of %v, using another configured provider", features)
+		}
+	}
+	if len(capable) == 0 {
+		feature, _ := chain[0].Capabilities().missing(features)
+		return nil, fmt.Errorf("%w: %s", ErrUnsupportedFeature, feature)
+	}
+	return capable, nil
+}
+
+func capableProviders(providers []Provider, features []Feature) []Provider {
+	var capable []Provider
+	for _, provider := range providers {
+		if _, missing := provider.Capabilities().missing(features); !missing {
+			capable = append(capable, provider)
+		}
+	}
+	return capable
+}
@@ -0,0 +1,41 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+)
+
+func TestImagesWithoutVisionProvider(t *testing.T) {
+	server := newFakeServer(t, func(w http.ResponseWriter, req capturedRequest) {
+		writeJSON(w, `{"choices": [{"message": {"role": "assistant", "content": "A cat."}}]}`)
+	})
+	client, err := NewLLMClient(Config{
+		DefaultProvider:   ProviderConfig{Type: ProviderDeepseek, APIKey: "test-key", BaseURL: server.URL},
+		EmbeddingProvider: &ProviderConfig{Type: ProviderHashing},
+		Context:           context.Background(),
+	})
+	if err != nil {
+		t.Fatalf("NewLLMClient: %v", err)
+	}
+	if client.Supports(FeatureVision) || !client.Supports(FeatureTools) {
+		t.Errorf("Supports(vision) = %v, Supports(tools) = %v", client.Supports(FeatureVision), client.Supports(FeatureTools))
+	}
+
+	// Images are never dropped silently
+	_, err = client.GenerateCompletion(CompletionRequest{
+		Messages: []Message{NewUserMessageWithImages("What is this?", "https://example.com/cat.png")},
+	})
+	if !errors.Is(err, ErrUnsupportedFeature) {
+		t.Errorf("GenerateCompletion error = %v, want ErrUnsupportedFeature", err)
+	}
+	if server.count() != 0 {
+		t.Errorf("requests = %d, want none", server.count())
+	}
+
+	msg, err := client.GenerateCompletion(CompletionRequest{Messages: []Message{NewUserMessage("What is this?")}})
+	if err != nil || msg.Content != "A cat." {
+		t.Errorf("text completion = %q, %v", msg.Content, err)
+	}
+}
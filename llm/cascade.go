@@ -0,0 +1,226 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// Judgement is a judge's verdict on an answer of the fast model
+type Judgement struct {
+	Acceptable bool
+	Reason     string // Why the answer is not acceptable
+}
+
+// Judge rates an answer of the fast model to the request
+type Judge func(ctx context.Context, req CompletionRequest, answer Message) (Judgement, error)
+
+// CascadeConfig enables answering calls for ModelTypeAdvanced with
+// ModelTypeFast first. A completion escalates to the advanced model when the
+// judge rejects the fast answer, structured output when it fails validation.
+// Calls also escalate when the fast model fails. Streams and calls for other
+// model types, e.g. engine responses with ModelTypeDefault, are not cascaded.
+type CascadeConfig struct {
+	// Judge rates completions of the fast model. Without a judge, fast
+	// completions are accepted unless they fail.
+	Judge Judge
+}
+
+// CascadeTrace records the stages of a cascaded call
+type CascadeTrace struct {
+	FastModel      string
+	FastResult     string // Content or JSON result of the fast model
+	Escalated      bool
+	Reason         string // Why the call escalated
+	AdvancedModel  string
+	AdvancedResult string
+}
+
+// Metadata converts the trace into a JSON-friendly metadata value
+func (t CascadeTrace) Metadata() map[string]interface{} {
+	metadata := map[string]interface{}{
+		"fast_model":  t.FastModel,
+		"fast_result": t.FastResult,
+		"escalated":   t.Escalated,
+	}
+	if t.Escalated {
+		metadata["reason"] = t.Reason
+		metadata["advanced_model"] = t.AdvancedModel
+		metadata["advanced_result"] = t.AdvancedResult
+	}
+	return metadata
+}
+
+// cascades reports whether calls of the model type go through the cascade
+func (c *LLMClient) cascades(modelType ModelType) bool {
+	return c.cascade != nil && modelType == ModelTypeAdvanced
+}
+
+// cascadeCompletion answers with the fast model and escalates to the
+// requested one if the judge rejects the answer. The advanced model continues
+// from the fast model's tool-calling rounds, so tools are not run twice. Each
+// stage's usage is priced and recorded on its own, the returned message
+// carries their sum.
+func (c *LLMClient) cascadeCompletion(ctx context.Context, req CompletionRequest) (CompletionResult, error) {
+	fastReq := req
+	fastReq.ModelType = ModelTypeFast
+	fast, err := c.generateCompletion(ctx, fastReq)
+
+	trace := &CascadeTrace{
+		FastModel:  fast.Message.Usage.Model,
+		FastResult: fast.Message.Content,
+	}
+	reason, escalate := c.judge(ctx, req, fast.Message, err)
+	if !escalate {
+		fast.Message.Cascade = trace
+		return fast, nil
+	}
+	if ctx.Err() != nil {
+		return fast, err
+	}
+	c.logEscalation(reason)
+
+	advancedReq := req
+	advancedReq.Messages = reusableRounds(fast.rounds, len(req.Messages))
+	advanced, err := c.generateCompletion(ctx, advancedReq)
+	trace.Escalated = true
+	trace.Reason = reason
+	trace.AdvancedModel = advanced.Message.Usage.Model
+	trace.AdvancedResult = advanced.Message.Content
+
+	usage := *advanced.Message.Usage
+	usage.Add(*fast.Message.Usage)
+	advanced.Message.Usage = &usage
+	advanced.Message.Cascade = trace
+	advanced.Trace = append(fast.Trace, advanced.Trace...)
+	return advanced, err
+}
+
+// reusableRounds returns the conversation with the tool-calling rounds of
+// another model, which start at index start. Their reasoning is dropped, as
+// providers only accept signed reasoning from the model that produced it.
+func reusableRounds(rounds []Message, start int) []Message {
+	messages := append([]Message(nil), rounds...)
+	for i := start; i < len(messages); i++ {
+		messages[i].Reasoning = ""
+		messages[i].ReasoningSignature = ""
+		messages[i].Usage = nil
+	}
+	return messages
+}
+
+// judge decides whether a fast completion escalates and why
+func (c *LLMClient) judge(ctx context.Context, req CompletionRequest, answer Message, err error) (string, bool) {
+	if err != nil {
+		return fmt.Sprintf("fast model failed: %v", err), true
+	}
+	if c.cascade.Judge == nil {
+		return "", false
+	}
+
+	judgement, err := c.cascade.Judge(ctx, req, answer)
+	if err != nil {
+		return fmt.Sprintf("judge failed: %v", err), true
+	}
+	if !judgement.Acceptable {
+		return judgement.Reason, true
+	}
+	return "", false
+}
+
+// cascadeStructuredOutput tries the fast model once and escalates to the
+// requested one, with repairs, if the fast result fails validation
+func (c *LLMClient) cascadeStructuredOutput(ctx context.Context, req StructuredOutputRequest, result interface{}) error {
+	fastReq := req
+	fastReq.ModelType = ModelTypeFast
+	fastModel, err := c.structuredOutput(ctx, fastReq, result, 0)
+
+	trace := CascadeTrace{FastModel: fastModel}
+	var validationErr *ValidationError
+	switch {
+	case err == nil:
+		trace.FastResult = marshalResult(result)
+		setCascadeTrace(req, trace)
+		return nil
+	case errors.As(err, &validationErr):
+		trace.FastResult = validationErr.Content
+		trace.Reason = validationErr.Error()
+	default:
+		trace.Reason = fmt.Sprintf("fast model failed: %v", err)
+	}
+	if ctx.Err() != nil {
+		return err
+	}
+	c.logEscalation(trace.Reason)
+
+	// Drop what the fast model decoded, e.g. map entries
+	if value := reflect.ValueOf(result); value.Kind() == reflect.Pointer && !value.IsNil() {
+		value.Elem().SetZero()
+	}
+	advancedModel, err := c.structuredOutput(ctx, req, result, c.maxRepairs)
+	trace.Escalated = true
+	trace.AdvancedModel = advancedModel
+	if err == nil {
+		trace.AdvancedResult = marshalResult(result)
+	}
+	setCascadeTrace(req, trace)
+	return err
+}
+
+func setCascadeTrace(req StructuredOutputRequest, trace CascadeTrace) {
+	if req.Cascade != nil {
+		*req.Cascade = trace
+	}
+}
+
+func marshalResult(result interface{}) string {
+	data, err := json.Marshal(result)
+	if err != nil {
+		return ""
+	}
+	return string(data)
+}
+
+func (c *LLMClient) logEscalation(reason string) {
+	if c.logger != nil {
+		c.logger.Infof("Escalating to the advanced model: %s", reason)
+	}
+}
+
+// NewModelJudge returns a judge that asks the client's fast model whether an
+// answer fully and correctly addresses the conversation
+func NewModelJudge(client *LLMClient) Judge {
+	return func(ctx context.Context, req CompletionRequest, answer Message) (Judgement, error) {
+		var verdict struct {
+			Acceptable bool   `json:"acceptable" jsonschema:"required" description:"Whether the answer is correct, complete and follows the instructions"`
+			Reason     string `json:"reason" jsonschema:"required" description:"The problems of the answer if it is not acceptable"`
+		}
+		err := client.GenerateStructuredOutputContext(ctx, StructuredOutputRequest{
+			Messages: []Message{
+				NewSystemMessage("You review answers of an assistant. Accept answers that are correct, complete and follow the instructions of the conversation, reject low quality ones."),
+				NewUserMessage(fmt.Sprintf("Conversation:\n%s\nProposed answer:\n%s\n\nIs this answer acceptable?", conversationText(req.Messages), answer.Content)),
+			},
+			ModelType:    ModelTypeFast,
+			SchemaName:   "answer_judgement",
+			StrictSchema: true,
+		}, &verdict)
+		if err != nil {
+			return Judgement{}, err
+		}
+		return Judgement{Acceptable: verdict.Acceptable, Reason: verdict.Reason}, nil
+	}
+}
+
+// conversationText flattens a conversation into role-prefixed lines
+func conversationText(messages []Message) string {
+	var text strings.Builder
+	for _, msg := range messages {
+		if content := msg.Text(); content != "" {
+			fmt.Fprintf(&text, "%s: %s\n", msg.Role, content)
+		}
+	}
+	return text.String()
+}
@@ -0,0 +1,123 @@
+package llm
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net/http"
+	"sync"
+	"testing"
+
+	toolkit "github.com/soralabs/toolkit/go"
+)
+
+// usageLog collects recorded usage
+type usageLog struct {
+	mu      sync.Mutex
+	records []UsageRecord
+}
+
+func (l *usageLog) RecordUsage(record UsageRecord) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.records = append(l.records, record)
+	return nil
+}
+
+func TestCascadeEscalationReusesToolRounds(t *testing.T) {
+	server := newFakeServer(t, func(w http.ResponseWriter, req capturedRequest) {
+		model := req.Body["model"].(string)
+		messages := req.Body["messages"].([]interface{})
+		usage := `{"prompt_tokens": 1000000, "completion_tokens": 0, "total_tokens": 1000000}`
+		switch {
+		case model == "fast" && len(messages) == 1:
+			writeJSON(w, `{
+				"model": "fast",
+				"choices": [{
+					"message": {
+						"role": "assistant",
+						"tool_calls": [{"id": "call_1", "type": "function", "function": {"name": "search", "arguments": "{\"query\":\"go\"}"}}]
+					},
+					"finish_reason": "tool_calls"
+				}],
+				"usage": `+usage+`
+			}`)
+		case model == "fast":
+			writeJSON(w, `{"model": "fast", "choices": [{"message": {"role": "assistant", "content": "Hits."}, "finish_reason": "stop"}], "usage": `+usage+`}`)
+		default:
+			writeJSON(w, fmt.Sprintf(`{"model": %q, "choices": [{"message": {"role": "assistant", "content": "Go has 3 hits."}, "finish_reason": "stop"}], "usage": %s}`, model, usage))
+		}
+	})
+
+	recorder := &usageLog{}
+	client, err := NewLLMClient(Config{
+		DefaultProvider: ProviderConfig{
+			Type:        ProviderDeepseek,
+			APIKey:      "test-key",
+			BaseURL:     server.URL,
+			ModelConfig: map[ModelType]string{ModelTypeFast: "fast", ModelTypeAdvanced: "advanced"},
+		},
+		EmbeddingProvider: &ProviderConfig{Type: ProviderHashing},
+		Prices: PriceTable{
+			"fast":     {PromptPerMillion: 1},
+			"advanced": {PromptPerMillion: 10},
+		},
+		UsageRecorder: recorder,
+		Cascade: &CascadeConfig{
+			Judge: func(ctx context.Context, req CompletionRequest, answer Message) (Judgement, error) {
+				return Judgement{Reason: "too short"}, nil
+			},
+		},
+		Context: context.Background(),
+	})
+	if err != nil {
+		t.Fatalf("NewLLMClient: %v", err)
+	}
+	tool := &fakeTool{name: "search", result: `{"hits":3}`}
+
+	result, err := client.GenerateCompletionWithTrace(CompletionRequest{
+		Messages:  []Message{NewUserMessage("Search for go")},
+		Tools:     []toolkit.Tool{tool},
+		ModelType: ModelTypeAdvanced,
+	})
+	if err != nil {
+		t.Fatalf("GenerateCompletionWithTrace: %v", err)
+	}
+	if result.Message.Content != "Go has 3 hits." {
+		t.Errorf("content = %q", result.Message.Content)
+	}
+	if tool.callCount() != 1 {
+		t.Errorf("tool ran %d times, want once", tool.callCount())
+	}
+	if len(result.Trace) != 1 || result.Trace[0].CallID != "call_1" {
+		t.Errorf("trace = %+v, want the fast model's tool call", result.Trace)
+	}
+	cascade := result.Message.Cascade
+	if cascade == nil || !cascade.Escalated || cascade.Reason != "too short" ||
+		cascade.FastModel != "fast" || cascade.AdvancedModel != "advanced" || cascade.FastResult != "Hits." {
+		t.Errorf("cascade = %+v", cascade)
+	}
+
+	// The advanced model answers from the fast model's tool round
+	messages := server.last(t).Body["messages"].([]interface{})
+	if len(messages) != 3 || jsonPath(t, messages, "1.tool_calls.0.id") != "call_1" || jsonPath(t, messages, "2.content") != `{"hits":3}` {
+		t.Errorf("advanced messages = %v", messages)
+	}
+
+	// Each stage is priced at its own model's rate
+	if len(recorder.records) != 2 {
+		t.Fatalf("records = %+v, want one per stage", recorder.records)
+	}
+	for i, want := range []struct {
+		model string
+		cost  float64
+	}{{"fast", 2}, {"advanced", 10}} {
+		usage := recorder.records[i].Usage
+		if usage.Model != want.model || math.Abs(usage.Cost-want.cost) > 1e-9 {
+			t.Errorf("record %d = %s at %v, want %s at %v", i, usage.Model, usage.Cost, want.model, want.cost)
+		}
+	}
+	if usage := result.Message.Usage; usage == nil || math.Abs(usage.Cost-12) > 1e-9 || usage.TotalTokens != 3000000 {
+		t.Errorf("message usage = %+v, want both stages", usage)
+	}
+}
@@ -0,0 +1,310 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	toolkit "github.com/soralabs/toolkit/go"
+	"github.com/soralabs/zen/logger"
+)
+
+// ErrContextWindowExceeded is returned when a request does not fit into the
+// model's context window even after shortening it
+var ErrContextWindowExceeded = errors.New("context window exceeded")
+
+// ContextPolicy selects how requests that exceed the context window are shortened
+type ContextPolicy string
+
+const (
+	// ContextPolicyTruncate drops the oldest non-system messages
+	ContextPolicyTruncate ContextPolicy = "truncate"
+	// ContextPolicySummarize replaces the oldest non-system messages with a
+	// summary generated by the fast model, truncating if summarization fails
+	ContextPolicySummarize ContextPolicy = "summarize"
+)
+
+// DefaultReservedTokens is kept free for the completion unless
+// ContextWindowConfig.ReservedTokens is set
+const DefaultReservedTokens = 4096
+
+// summaryReservedTokens is kept free for the summary of dropped messages
+const summaryReservedTokens = 512
+
+// estimatedImageTokens is the rough prompt cost of one image input
+const estimatedImageTokens = 1000
+
+// ContextWindowConfig fits requests into the context window of their model.
+// System messages and the last message are always kept.
+type ContextWindowConfig struct {
+	Policy         ContextPolicy  // Defaults to ContextPolicyTruncate
+	Limits         map[string]int // Context windows by model name, overriding DefaultContextLimits
+	ReservedTokens int            // Tokens kept free for the completion, defaults to DefaultReservedTokens
+}
+
+func (c ContextWindowConfig) withDefaults() ContextWindowConfig {
+	if c.Policy == "" {
+		c.Policy = ContextPolicyTruncate
+	}
+	if c.ReservedTokens <= 0 {
+		c.ReservedTokens = DefaultReservedTokens
+	}
+	return c
+}
+
+// EstimateTokens roughly estimates the prompt tokens of a conversation
+func EstimateTokens(messages []Message) int {
+	tokens := 0
+	for _, message := range messages {
+		// Role and formatting overhead per message
+		tokens += 4 + estimateTextTokens(message.Text()) + message.imageCount()*estimatedImageTokens
+		for _, call := range message.ToolCalls {
+			tokens += estimateTextTokens(call.Name) + estimateTextTokens(call.Arguments)
+		}
+	}
+	return tokens
+}
+
+// estimateToolTokens roughly estimates the prompt tokens of tool definitions
+func estimateToolTokens(tools []toolkit.Tool) int {
+	tokens := 0
+	for _, tool := range tools {
+		tokens += estimateTextTokens(tool.GetName()) + estimateTextTokens(tool.GetDescription()) +
+			estimateTextTokens(string(tool.GetSchema().Parameters))
+	}
+	return tokens
+}
+
+// estimateTextTokens assumes about four characters per token
+func estimateTextTokens(text string) int {
+	return (len(text) + 3) / 4
+}
+
+// contextWindowProvider shortens requests that exceed the context window of
+// their model before passing them to the provider
+type contextWindowProvider struct {
+	Provider
+	config ProviderConfig
+	window ContextWindowConfig
+	prices PriceTable // Prices summaries, which use another model than the call
+	logger *logger.Logger
+}
+
+// newContextWindowProvider wraps the provider if a context window config is set
+func newContextWindowProvider(provider Provider, config ProviderConfig, window *ContextWindowConfig, prices PriceTable, logger *logger.Logger) Provider {
+	if window == nil {
+		return provider
+	}
+	return &contextWindowProvider{
+		Provider: provider,
+		config:   config,
+		window:   window.withDefaults(),
+		prices:   prices,
+		logger:   logger,
+	}
+}
+
+// Capabilities reports the context window with the configured limits applied
+func (p *contextWindowProvider) Capabilities() Capabilities {
+	capabilities := p.Provider.Capabilities()
+	if capabilities.Completion {
+		models := p.config.ModelConfig
+		if models == nil {
+			models = defaultModels[p.config.Type]
+		}
+		capabilities.MaxContextTokens = maxContextLimit(models, p.window.Limits)
+	}
+	return capabilities
+}
+
+func (p *contextWindowProvider) GenerateCompletion(ctx context.Context, req CompletionRequest) (Message, error) {
+	messages, usage, err := p.fit(ctx, req.ModelType, req.Messages, estimateToolTokens(req.Tools))
+	if err != nil {
+		return Message{}, err
+	}
+	req.Messages = messages
+
+	message, err := p.Provider.GenerateCompletion(ctx, req)
+	if message.Usage != nil {
+		message.Usage = p.withSummaryUsage(*message.Usage, usage)
+	}
+	return message, err
+}
+
+// GenerateCompletionStream adds the usage of any summarization to the final message
+func (p *contextWindowProvider) GenerateCompletionStream(ctx context.Context, req CompletionRequest) (<-chan StreamEvent, error) {
+	messages, usage, err := p.fit(ctx, req.ModelType, req.Messages, estimateToolTokens(req.Tools))
+	if err != nil {
+		return nil, err
+	}
+	req.Messages = messages
+
+	stream, err := p.Provider.GenerateCompletionStream(ctx, req)
+	if err != nil || usage.TotalTokens == 0 {
+		return stream, err
+	}
+
+	events := make(chan StreamEvent)
+	go func() {
+		defer close(events)
+		for event := range stream {
+			if event.Type == StreamEventDone && event.Message != nil && event.Message.Usage != nil {
+				event.Message.Usage = p.withSummaryUsage(*event.Message.Usage, usage)
+			}
+			if !sendStreamEvent(ctx, events, event) {
+				return
+			}
+		}
+	}()
+	return events, nil
+}
+
+func (p *contextWindowProvider) GenerateStructuredOutput(ctx context.Context, req StructuredOutputRequest, result interface{}) (Usage, error) {
+	messages, fitUsage, err := p.fit(ctx, req.ModelType, req.Messages, 0)
+	if err != nil {
+		return Usage{}, err
+	}
+	req.Messages = messages
+
+	usage, err := p.Provider.GenerateStructuredOutput(ctx, req, result)
+	if fitUsage.TotalTokens == 0 {
+		return usage, err
+	}
+	return *p.withSummaryUsage(usage, fitUsage), err
+}
+
+// withSummaryUsage adds the usage of a summary to that of the call. Both are
+// priced first, as the summary is made by the fast model.
+func (p *contextWindowProvider) withSummaryUsage(usage, summary Usage) *Usage {
+	if summary.TotalTokens == 0 {
+		return &usage
+	}
+	usage = p.prices.price(usage)
+	usage.Add(p.prices.price(summary))
+	return &usage
+}
+
+// fit shortens messages to the context window of the model, keeping system
+// messages and the last message. Returns the usage of any summarization.
+func (p *contextWindowProvider) fit(ctx context.Context, modelType ModelType, messages []Message, extraTokens int) ([]Message, Usage, error) {
+	model := modelFor(p.config, modelType)
+	budget := contextLimit(model, p.window.Limits) - p.window.ReservedTokens - extraTokens
+	total := EstimateTokens(messages)
+	if total <= budget || len(messages) == 0 {
+		return messages, Usage{}, nil
+	}
+
+	target := budget
+	if p.window.Policy == ContextPolicySummarize {
+		target -= summaryReservedTokens
+	}
+
+	// Drop the oldest groups first. Tool calls are grouped with their results
+	// so that no result is left without its call.
+	groups := groupMessages(messages)
+	dropped := make([]bool, len(groups))
+	var droppedMessages []Message
+	summaryAt := -1
+	for i, group := range groups[:len(groups)-1] {
+		if total <= target {
+			break
+		}
+		if group[0].Role == RoleSystem {
+			continue
+		}
+		if summaryAt < 0 {
+			summaryAt = i
+		}
+		dropped[i] = true
+		droppedMessages = append(droppedMessages, group...)
+		total -= EstimateTokens(group)
+	}
+	if total > target {
+		return nil, Usage{}, fmt.Errorf("%w: %d estimated tokens exceed the %d available for %s",
+			ErrContextWindowExceeded, total, target, model)
+	}
+
+	var summary *Message
+	var usage Usage
+	if p.window.Policy == ContextPolicySummarize {
+		message, err := p.summarize(ctx, droppedMessages)
+		if err != nil {
+			if p.logger != nil {
+				p.logger.Warnf("Failed to summarize messages exceeding the context window, dropping them: %v", err)
+			}
+		} else {
+			summary = &message
+			if message.Usage != nil {
+				usage = *message.Usage
+			}
+		}
+	}
+
+	fitted := make([]Message, 0, len(messages)-len(droppedMessages)+1)
+	for i, group := range groups {
+		if i == summaryAt && summary != nil {
+			fitted = append(fitted, NewSystemMessage("Summary of the earlier conversation:\n"+summary.Content))
+		}
+		if !dropped[i] {
+			fitted = append(fitted, group...)
+		}
+	}
+
+	if p.logger != nil {
+		p.logger.Infof("Request for %s exceeded its context window, removed %d messages (%s)", model, len(droppedMessages), p.window.Policy)
+	}
+	return fitted, usage, nil
+}
+
+// summarize condenses messages into a short summary with the fast model
+func (p *contextWindowProvider) summarize(ctx context.Context, messages []Message) (Message, error) {
+	var transcript strings.Builder
+	for _, message := range messages {
+		fmt.Fprintf(&transcript, "%s: %s\n", message.Role, message.Text())
+		for _, call := range message.ToolCalls {
+			fmt.Fprintf(&transcript, "%s called %s(%s)\n", message.Role, call.Name, call.Arguments)
+		}
+	}
+
+	// Keep the most recent part of transcripts too long for the fast model
+	model := modelFor(p.config, ModelTypeFast)
+	maxTokens := contextLimit(model, p.window.Limits) - p.window.ReservedTokens - summaryReservedTokens
+
+	return p.Provider.GenerateCompletion(ctx, CompletionRequest{
+		Messages: []Message{
+			NewSystemMessage("Summarize the following conversation in at most 200 words. " +
+				"Keep names, facts, decisions and open questions."),
+			NewUserMessage(tailText(transcript.String(), maxTokens)),
+		},
+		ModelType: ModelTypeFast,
+	})
+}
+
+// groupMessages splits messages into groups of a single message, or an
+// assistant message with tool calls followed by its tool results
+func groupMessages(messages []Message) [][]Message {
+	var groups [][]Message
+	for _, message := range messages {
+		if last := len(groups) - 1; message.Role == RoleTool && last >= 0 && len(groups[last][0].ToolCalls) > 0 {
+			groups[last] = append(groups[last], message)
+			continue
+		}
+		groups = append(groups, []Message{message})
+	}
+	return groups
+}
+
+// tailText returns the end of text that fits into roughly maxTokens tokens
+func tailText(text string, maxTokens int) string {
+	maxBytes := maxTokens * 4
+	if maxBytes <= 0 || len(text) <= maxBytes {
+		return text
+	}
+	start := len(text) - maxBytes
+	for start < len(text) && !utf8.RuneStart(text[start]) {
+		start++
+	}
+	return text[start:]
+}
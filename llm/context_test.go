@@ -0,0 +1,179 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// longText is estimated at 600 tokens, more than summaryReservedTokens
+var longText = strings.Repeat("word ", 480)
+
+// countingProvider answers completions, recording the prompt it received and
+// summarizing with a fixed summary
+type countingProvider struct {
+	funcProvider
+	summaryErr error
+
+	mu        sync.Mutex
+	prompts   [][]Message
+	summaries int
+}
+
+func newCountingProvider() *countingProvider {
+	p := &countingProvider{}
+	p.caps = allCapabilities
+	p.complete = func(ctx context.Context, req CompletionRequest) (Message, error) {
+		p.mu.Lock()
+		defer p.mu.Unlock()
+		if req.ModelType == ModelTypeFast {
+			p.summaries++
+			if p.summaryErr != nil {
+				return Message{}, p.summaryErr
+			}
+			return Message{Role: RoleAssistant, Content: "They talked.", Usage: &Usage{Model: "small", PromptTokens: 50, TotalTokens: 50}}, nil
+		}
+		p.prompts = append(p.prompts, req.Messages)
+		tokens := EstimateTokens(req.Messages)
+		return Message{Role: RoleAssistant, Content: "ok", Usage: &Usage{Model: "small", PromptTokens: tokens, TotalTokens: tokens}}, nil
+	}
+	return p
+}
+
+// newWindowedProvider fits requests into budget tokens with the policy
+func newWindowedProvider(inner Provider, policy ContextPolicy, budget int) Provider {
+	return newContextWindowProvider(inner, ProviderConfig{
+		Type:        ProviderOpenAI,
+		ModelConfig: map[ModelType]string{ModelTypeDefault: "small", ModelTypeFast: "small"},
+	}, &ContextWindowConfig{
+		Policy:         policy,
+		Limits:         map[string]int{"small": budget + 10},
+		ReservedTokens: 10,
+	}, PriceTable{"small": {PromptPerMillion: 1}}, nil)
+}
+
+func conversation() []Message {
+	return []Message{
+		NewSystemMessage("Be brief."),
+		NewUserMessage("first " + longText),
+		NewAssistantMessage("second " + longText),
+		NewUserMessage("third " + longText),
+		NewUserMessage("last " + longText),
+	}
+}
+
+// contents returns the first word of every message
+func contents(messages []Message) []string {
+	words := make([]string, len(messages))
+	for i, message := range messages {
+		words[i], _, _ = strings.Cut(message.Content, " ")
+	}
+	return words
+}
+
+func TestContextWindowTruncates(t *testing.T) {
+	inner := newCountingProvider()
+	provider := newWindowedProvider(inner, ContextPolicyTruncate, EstimateTokens(conversation())-1)
+
+	if _, err := provider.GenerateCompletion(context.Background(), CompletionRequest{Messages: conversation()}); err != nil {
+		t.Fatalf("GenerateCompletion: %v", err)
+	}
+	// The oldest message goes first, the system prompt and last message stay
+	if got := strings.Join(contents(inner.prompts[0]), " "); got != "Be second third last" {
+		t.Errorf("prompt = %s", got)
+	}
+	if inner.summaries != 0 {
+		t.Errorf("summaries = %d, want none", inner.summaries)
+	}
+
+	// A request that fits is passed on unchanged
+	inner = newCountingProvider()
+	provider = newWindowedProvider(inner, ContextPolicyTruncate, EstimateTokens(conversation()))
+	if _, err := provider.GenerateCompletion(context.Background(), CompletionRequest{Messages: conversation()}); err != nil {
+		t.Fatalf("GenerateCompletion: %v", err)
+	}
+	if len(inner.prompts[0]) != 5 {
+		t.Errorf("prompt = %v, want all messages", contents(inner.prompts[0]))
+	}
+}
+
+func TestContextWindowKeepsToolResultsWithCalls(t *testing.T) {
+	call := NewAssistantMessage("")
+	call.ToolCalls = []ToolCall{{ID: "call_1", Name: "search", Arguments: `{"q":"x"}`}}
+	messages := []Message{
+		NewSystemMessage("Be brief."),
+		NewUserMessage("first " + longText),
+		call,
+		NewToolResultMessage("result "+longText, "search", "call_1"),
+		NewUserMessage("last " + longText),
+	}
+	inner := newCountingProvider()
+	provider := newWindowedProvider(inner, ContextPolicyTruncate, EstimateTokens(messages)-EstimateTokens(messages[1:2])-1)
+
+	if _, err := provider.GenerateCompletion(context.Background(), CompletionRequest{Messages: messages}); err != nil {
+		t.Fatalf("GenerateCompletion: %v", err)
+	}
+	// Dropping the first message is not enough, so the call goes together
+	// with its result
+	prompt := inner.prompts[0]
+	if got := strings.Join(contents(prompt), " "); got != "Be last" {
+		t.Errorf("prompt = %s", got)
+	}
+	for _, message := range prompt {
+		if message.Role == RoleTool {
+			t.Errorf("tool result kept without its call: %+v", prompt)
+		}
+	}
+}
+
+func TestContextWindowSummarizes(t *testing.T) {
+	inner := newCountingProvider()
+	provider := newWindowedProvider(inner, ContextPolicySummarize, EstimateTokens(conversation())-1)
+
+	message, err := provider.GenerateCompletion(context.Background(), CompletionRequest{Messages: conversation()})
+	if err != nil {
+		t.Fatalf("GenerateCompletion: %v", err)
+	}
+	prompt := inner.prompts[0]
+	if got := strings.Join(contents(prompt), " "); got != "Be Summary second third last" {
+		t.Errorf("prompt = %s", got)
+	}
+	if prompt[1].Role != RoleSystem || !strings.HasSuffix(prompt[1].Content, "They talked.") {
+		t.Errorf("summary = %+v", prompt[1])
+	}
+	// The summary's tokens are added to the call's usage
+	if message.Usage == nil || message.Usage.PromptTokens != EstimateTokens(prompt)+50 {
+		t.Errorf("usage = %+v, want the call and the summary", message.Usage)
+	}
+}
+
+func TestContextWindowSummaryFailureTruncates(t *testing.T) {
+	inner := newCountingProvider()
+	inner.summaryErr = errors.New("unavailable")
+	provider := newWindowedProvider(inner, ContextPolicySummarize, EstimateTokens(conversation())-1)
+
+	if _, err := provider.GenerateCompletion(context.Background(), CompletionRequest{Messages: conversation()}); err != nil {
+		t.Fatalf("GenerateCompletion: %v", err)
+	}
+	if inner.summaries != 1 {
+		t.Errorf("summaries = %d, want 1", inner.summaries)
+	}
+	if got := strings.Join(contents(inner.prompts[0]), " "); got != "Be second third last" {
+		t.Errorf("prompt = %s", got)
+	}
+}
+
+func TestContextWindowExceeded(t *testing.T) {
+	inner := newCountingProvider()
+	provider := newWindowedProvider(inner, ContextPolicyTruncate, 50)
+
+	_, err := provider.GenerateCompletion(context.Background(), CompletionRequest{Messages: conversation()})
+	if !errors.Is(err, ErrContextWindowExceeded) {
+		t.Errorf("err = %v, want ErrContextWindowExceeded", err)
+	}
+	if len(inner.prompts) != 0 {
+		t.Errorf("provider called with %d prompts", len(inner.prompts))
+	}
+}
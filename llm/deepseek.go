@@ -4,8 +4,9 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"io"
 	"net/http"
-	"reflect"
+	"strings"
 	"time"
 
 	"github.com/go-resty/resty/v2"
@@ -13,10 +14,12 @@ import (
 )
 
 type DeepseekProvider struct {
-	client *resty.Client
-	models map[ModelType]string
-	logger *logger.Logger
-	roles  map[Role]string
+	client         *resty.Client
+	models         map[ModelType]string
+	embeddingModel string
+	embeddingBatch int
+	logger         *logger.Logger
+	roles          map[Role]string
 }
 
 // NewDeepseekProvider creates and returns a new DeepseekProvider instance,
@@ -25,11 +28,7 @@ func NewDeepseekProvider(config Config) *DeepseekProvider {
 	// Default model mapping if not provided
 	models := config.DefaultProvider.ModelConfig
 	if models == nil {
-		models = map[ModelType]string{
-			ModelTypeFast:     "deepseek-chat",
-			ModelTypeDefault:  "deepseek-chat",
-			ModelTypeAdvanced: "deepseek-reasoner",
-		}
+		models = defaultModels[ProviderDeepseek]
 	}
 
 	// Role mapping
@@ -40,17 +39,25 @@ func NewDeepseekProvider(config Config) *DeepseekProvider {
 		RoleTool:      "tool",
 	}
 
+	baseURL := config.DefaultProvider.BaseURL
+	if baseURL == "" {
+		baseURL = "https://api.deepseek.com/v1"
+	}
+
 	client := resty.New().
-		SetBaseURL("https://api.deepseek.com/v1").
+		SetBaseURL(baseURL).
 		SetHeader("Authorization", fmt.Sprintf("Bearer %s", config.DefaultProvider.APIKey)).
 		SetHeader("Content-Type", "application/json").
+		SetHeaders(config.DefaultProvider.Headers).
 		SetTimeout(2 * time.Minute)
 
 	return &DeepseekProvider{
-		client: client,
-		models: models,
-		logger: config.Logger,
-		roles:  roles,
+		client:         client,
+		models:         models,
+		embeddingModel: config.DefaultProvider.EmbeddingModel,
+		embeddingBatch: config.DefaultProvider.EmbeddingBatchSize,
+		logger:         config.Logger,
+		roles:          roles,
 	}
 }
 
@@ -60,12 +67,30 @@ type deepseekChatCompletionRequest struct {
 	Temperature    float32                 `json:"temperature,omitempty"`
 	Tools          []deepseekTool          `json:"tools,omitempty"`
 	ResponseFormat *deepseekResponseFormat `json:"response_format,omitempty"`
+	Stream         bool                    `json:"stream,omitempty"`
+	StreamOptions  *deepseekStreamOptions  `json:"stream_options,omitempty"`
+}
+
+type deepseekStreamOptions struct {
+	IncludeUsage bool `json:"include_usage"`
 }
 
 type deepseekMessage struct {
-	Role    string `json:"role"`
-	Content string `json:"content"`
-	Name    string `json:"name,omitempty"`
+	Role       string             `json:"role"`
+	Content    string             `json:"content"`
+	Name       string             `json:"name,omitempty"`
+	ToolCalls  []deepseekToolCall `json:"tool_calls,omitempty"`
+	ToolCallID string             `json:"tool_call_id,omitempty"`
+}
+
+type deepseekToolCall struct {
+	Index    *int   `json:"index,omitempty"` // Only set on streamed fragments
+	ID       string `json:"id,omitempty"`
+	Type     string `json:"type,omitempty"`
+	Function struct {
+		Name      string `json:"name,omitempty"`
+		Arguments string `json:"arguments"`
+	} `json:"function"`
 }
 
 type deepseekTool struct {
@@ -90,23 +115,199 @@ type deepseekChatCompletionResponse struct {
 	Model   string `json:"model"`
 	Choices []struct {
 		Message struct {
-			Role             string `json:"role"`
-			Content          string `json:"content"`
-			ReasoningContent string `json:"reasoning_content,omitempty"`
+			Role             string             `json:"role"`
+			Content          string             `json:"content"`
+			ReasoningContent string             `json:"reasoning_content,omitempty"`
+			ToolCalls        []deepseekToolCall `json:"tool_calls,omitempty"`
 		} `json:"message"`
 		FinishReason string `json:"finish_reason"`
 	} `json:"choices"`
+	Usage *deepseekUsage `json:"usage,omitempty"`
+}
+
+type deepseekUsage struct {
+	PromptTokens            int `json:"prompt_tokens"`
+	CompletionTokens        int `json:"completion_tokens"`
+	TotalTokens             int `json:"total_tokens"`
+	CompletionTokensDetails *struct {
+		ReasoningTokens int `json:"reasoning_tokens"`
+	} `json:"completion_tokens_details,omitempty"`
+}
+
+type deepseekChatCompletionChunk struct {
+	ID      string `json:"id"`
+	Model   string `json:"model"`
+	Choices []struct {
+		Delta struct {
+			Role             string             `json:"role,omitempty"`
+			Content          string             `json:"content,omitempty"`
+			ReasoningContent string             `json:"reasoning_content,omitempty"`
+			ToolCalls        []deepseekToolCall `json:"tool_calls,omitempty"`
+		} `json:"delta"`
+		FinishReason string `json:"finish_reason"`
+	} `json:"choices"`
+	Usage *deepseekUsage `json:"usage,omitempty"`
+}
+
+type deepseekEmbeddingRequest struct {
+	Model string   `json:"model"`
+	Input []string `json:"input"`
+}
+
+type deepseekEmbeddingResponse struct {
+	Data []struct {
+		Index     int       `json:"index"`
+		Embedding []float32 `json:"embedding"`
+	} `json:"data"`
+	Usage *deepseekUsage `json:"usage,omitempty"`
+}
+
+// Capabilities reports chat support. Images are not supported, and
+// embeddings only when an EmbeddingModel is configured, see EmbedText.
+func (p *DeepseekProvider) Capabilities() Capabilities {
+	return Capabilities{
+		Completion:       true,
+		Tools:            true,
+		StructuredOutput: true,
+		Embeddings:       p.embeddingModel != "",
+		Streaming:        true,
+		MaxContextTokens: maxContextLimit(p.models, nil),
+	}
 }
 
 // GenerateCompletion sends a conversation to the Deepseek Chat API
-// and returns the model's text completion.
+// and returns the model's text completion or requested tool calls.
 func (p *DeepseekProvider) GenerateCompletion(ctx context.Context, req CompletionRequest) (Message, error) {
+	chatReq, err := p.buildChatRequest(req)
+	if err != nil {
+		return Message{}, err
+	}
+
+	resp, err := p.createChatCompletion(ctx, chatReq)
+	if err != nil {
+		return Message{}, err
+	}
+
+	if len(resp.Choices) == 0 {
+		return Message{}, fmt.Errorf("no completion returned")
+	}
+
+	choice := resp.Choices[0]
+	usage := p.convertUsage(chatReq.Model, resp.Usage)
+	message := Message{
+		Role:      RoleAssistant,
+		Content:   choice.Message.Content,
+		Reasoning: choice.Message.ReasoningContent,
+		Usage:     &usage,
+	}
+	for _, call := range choice.Message.ToolCalls {
+		message.ToolCalls = append(message.ToolCalls, ToolCall{
+			ID:        call.ID,
+			Name:      call.Function.Name,
+			Arguments: call.Function.Arguments,
+		})
+	}
+
+	return message, nil
+}
+
+// GenerateCompletionStream sends a conversation to the Deepseek Chat API
+// and streams the completion back as server-sent events. Requested tool calls
+// are returned on the final message.
+func (p *DeepseekProvider) GenerateCompletionStream(ctx context.Context, req CompletionRequest) (<-chan StreamEvent, error) {
+	body, err := p.openStream(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	model := p.getModel(req.ModelType)
+
+	events := make(chan StreamEvent)
+	go func() {
+		defer close(events)
+		defer body.Close()
+
+		var content, reasoning strings.Builder
+		var calls []ToolCall
+		usage := Usage{Provider: ProviderDeepseek, Model: model}
+		err := readSSE(body, func(_, data string) error {
+			var chunk deepseekChatCompletionChunk
+			if err := json.Unmarshal([]byte(data), &chunk); err != nil {
+				return fmt.Errorf("failed to parse stream chunk: %w", err)
+			}
+			if chunk.Usage != nil {
+				usage = p.convertUsage(model, chunk.Usage)
+			}
+			if len(chunk.Choices) == 0 {
+				return nil
+			}
+
+			delta := chunk.Choices[0].Delta
+			reasoning.WriteString(delta.ReasoningContent)
+			if delta.Content != "" {
+				content.WriteString(delta.Content)
+				if !sendStreamEvent(ctx, events, StreamEvent{Type: StreamEventContent, Content: delta.Content}) {
+					return ctx.Err()
+				}
+			}
+			for _, callDelta := range delta.ToolCalls {
+				calls = mergeToolCallDelta(calls, callDelta.Index, callDelta.ID, callDelta.Function.Name, callDelta.Function.Arguments)
+			}
+			return nil
+		})
+		if err != nil {
+			sendStreamEvent(ctx, events, StreamEvent{Type: StreamEventError, Err: fmt.Errorf("Deepseek stream error: %w", err)})
+			return
+		}
+
+		message := &Message{
+			Role:      RoleAssistant,
+			Content:   content.String(),
+			ToolCalls: calls,
+			Reasoning: reasoning.String(),
+			Usage:     &usage,
+		}
+		sendStreamEvent(ctx, events, StreamEvent{Type: StreamEventDone, Message: message})
+	}()
+
+	return events, nil
+}
+
+// openStream starts a streaming chat completion and returns the raw event stream body
+func (p *DeepseekProvider) openStream(ctx context.Context, req CompletionRequest) (io.ReadCloser, error) {
+	chatReq, err := p.buildChatRequest(req)
+	if err != nil {
+		return nil, err
+	}
+	chatReq.Stream = true
+	chatReq.StreamOptions = &deepseekStreamOptions{IncludeUsage: true}
+
+	httpResp, err := p.client.R().
+		SetContext(ctx).
+		SetBody(chatReq).
+		SetDoNotParseResponse(true).
+		Post("/chat/completions")
+	if err != nil {
+		return nil, newRequestError(ProviderDeepseek, err)
+	}
+
+	body := httpResp.RawBody()
+	if httpResp.StatusCode() != http.StatusOK {
+		defer body.Close()
+		errBody, _ := io.ReadAll(body)
+		return nil, newStatusError(ProviderDeepseek, httpResp.StatusCode(), string(errBody))
+	}
+
+	return body, nil
+}
+
+// buildChatRequest converts a completion request into a Deepseek chat request
+func (p *DeepseekProvider) buildChatRequest(req CompletionRequest) (deepseekChatCompletionRequest, error) {
 	tools := make([]deepseekTool, len(req.Tools))
 	for i, tool := range req.Tools {
 		schema := tool.GetSchema()
 		var params interface{}
 		if err := json.Unmarshal(schema.Parameters, &params); err != nil {
-			return Message{}, fmt.Errorf("failed to parse tool parameters: %w", err)
+			return deepseekChatCompletionRequest{}, fmt.Errorf("failed to parse tool parameters: %w", err)
 		}
 
 		tools[i] = deepseekTool{
@@ -119,10 +320,9 @@ func (p *DeepseekProvider) GenerateCompletion(ctx context.Context, req Completio
 		}
 	}
 
-	messages := p.convertMessages(req.Messages)
 	chatReq := deepseekChatCompletionRequest{
 		Model:       p.getModel(req.ModelType),
-		Messages:    messages,
+		Messages:    p.convertMessages(req.Messages),
 		Temperature: req.Temperature,
 		ResponseFormat: &deepseekResponseFormat{
 			Type: "text",
@@ -132,117 +332,56 @@ func (p *DeepseekProvider) GenerateCompletion(ctx context.Context, req Completio
 		chatReq.Tools = tools
 	}
 
-	var resp deepseekChatCompletionResponse
-	httpResp, err := p.client.R().
-		SetContext(ctx).
-		SetBody(chatReq).
-		SetResult(&resp).
-		Post("/chat/completions")
-
-	if err != nil {
-		return Message{}, fmt.Errorf("Deepseek API error: %w", err)
-	}
-
-	if httpResp.StatusCode() != http.StatusOK {
-		return Message{}, fmt.Errorf("Deepseek API error: %s", httpResp.String())
-	}
-
-	if len(resp.Choices) == 0 {
-		return Message{}, fmt.Errorf("no completion returned")
-	}
-
-	choice := resp.Choices[0]
-	if choice.Message.ReasoningContent != "" {
-		p.logger.WithFields(map[string]interface{}{
-			"reasoning": choice.Message.ReasoningContent,
-		}).Info("Reasoning output")
-	}
-	toolCall := p.extractToolCall(choice.Message.Content)
-	if toolCall != nil {
-		for _, tool := range req.Tools {
-			if tool.GetName() == toolCall.Name {
-				// Execute the tool
-				result, err := tool.Execute(ctx, json.RawMessage(toolCall.Arguments))
-				if err != nil {
-					return Message{}, fmt.Errorf("tool execution error: %w", err)
-				}
-
-				// Create a new message array with the tool result
-				toolResultMessages := append(req.Messages,
-					Message{
-						Role:    RoleAssistant,
-						Content: "",
-						ToolCall: &ToolCall{
-							Name:      toolCall.Name,
-							Arguments: toolCall.Arguments,
-						},
-					},
-					Message{
-						Role:    RoleTool,
-						Content: string(result),
-						Name:    tool.GetName(),
-					},
-				)
-
-				// Make a follow-up completion request with the tool result
-				followUpReq := CompletionRequest{
-					Messages:    toolResultMessages,
-					ModelType:   req.ModelType,
-					Temperature: req.Temperature,
-					Tools:       req.Tools,
-				}
-
-				return p.GenerateCompletion(ctx, followUpReq)
-			}
-		}
-		return Message{}, fmt.Errorf("function %s not found", toolCall.Name)
-	}
-
-	return Message{
-		Role:    RoleAssistant,
-		Content: choice.Message.Content,
-	}, nil
+	return chatReq, nil
 }
 
-// extractToolCall extracts tool call information from a message
-func (p *DeepseekProvider) extractToolCall(content string) *ToolCall {
-	// Parse the content as JSON to check for function call
-	var parsed struct {
-		Name      string `json:"name"`
-		Arguments string `json:"arguments"`
+// GenerateStructuredOutput asks the model for JSON matching a schema generated
+// from result's json and jsonschema tags. Deepseek's JSON mode does not enforce
+// a schema, so the schema is included in the system prompt.
+func (p *DeepseekProvider) GenerateStructuredOutput(ctx context.Context, req StructuredOutputRequest, result interface{}) (Usage, error) {
+	schema, err := GenerateSchema(result)
+	if err != nil {
+		return Usage{}, fmt.Errorf("failed to generate schema: %w", err)
 	}
-	if err := json.Unmarshal([]byte(content), &parsed); err == nil && parsed.Name != "" {
-		return &ToolCall{
-			Name:      parsed.Name,
-			Arguments: parsed.Arguments,
-		}
+	schemaJSON, err := json.MarshalIndent(schema, "", "  ")
+	if err != nil {
+		return Usage{}, fmt.Errorf("failed to encode schema: %w", err)
 	}
-	return nil
-}
 
-// GenerateStructuredOutput prompts the Deepseek API to return JSON data
-func (p *DeepseekProvider) GenerateStructuredOutput(ctx context.Context, req StructuredOutputRequest, result interface{}) error {
-	resultType := reflect.TypeOf(result)
-	if resultType.Kind() == reflect.Ptr {
-		resultType = resultType.Elem()
+	name := req.SchemaName
+	if name == "" {
+		name = "response"
 	}
-	description := fmt.Sprintf("The response should be a valid JSON object matching the following Go struct type: %s", resultType.String())
-
 	systemMessage := Message{
-		Role:    RoleSystem,
-		Content: description,
+		Role: RoleSystem,
+		Content: fmt.Sprintf("Respond with a single JSON object named %s that conforms to the following JSON Schema. "+
+			"Include every required property and use only the listed enum values.\n\n%s", name, schemaJSON),
 	}
-	messages := append([]Message{systemMessage}, req.Messages...)
 
 	chatReq := deepseekChatCompletionRequest{
 		Model:       p.getModel(req.ModelType),
-		Messages:    p.convertMessages(messages),
+		Messages:    p.convertMessages(append([]Message{systemMessage}, req.Messages...)),
 		Temperature: req.Temperature,
 		ResponseFormat: &deepseekResponseFormat{
 			Type: "json_object",
 		},
 	}
 
+	resp, err := p.createChatCompletion(ctx, chatReq)
+	if err != nil {
+		return Usage{}, fmt.Errorf("StructuredOutput %w", err)
+	}
+
+	usage := p.convertUsage(chatReq.Model, resp.Usage)
+	if len(resp.Choices) == 0 {
+		return usage, fmt.Errorf("no completion returned")
+	}
+
+	return usage, decodeStructuredOutput(resp.Choices[0].Message.Content, schema, result)
+}
+
+// createChatCompletion posts a non-streaming chat completion request
+func (p *DeepseekProvider) createChatCompletion(ctx context.Context, chatReq deepseekChatCompletionRequest) (deepseekChatCompletionResponse, error) {
 	var resp deepseekChatCompletionResponse
 	httpResp, err := p.client.R().
 		SetContext(ctx).
@@ -251,23 +390,90 @@ func (p *DeepseekProvider) GenerateStructuredOutput(ctx context.Context, req Str
 		Post("/chat/completions")
 
 	if err != nil {
-		return fmt.Errorf("StructuredOutput Deepseek API error: %w", err)
+		return resp, newRequestError(ProviderDeepseek, err)
 	}
 
 	if httpResp.StatusCode() != http.StatusOK {
-		return fmt.Errorf("StructuredOutput Deepseek API error: %s", httpResp.String())
+		return resp, newStatusError(ProviderDeepseek, httpResp.StatusCode(), httpResp.String())
 	}
 
-	if len(resp.Choices) == 0 {
-		return fmt.Errorf("no completion returned")
+	return resp, nil
+}
+
+// EmbedText generates an embedding vector for the given text.
+// The Deepseek API has no embedding model, but when the provider points at an
+// OpenAI-compatible server with an EmbeddingModel configured, its /embeddings
+// endpoint is used.
+func (p *DeepseekProvider) EmbedText(ctx context.Context, text string) ([]float32, Usage, error) {
+	return firstEmbedding(p.EmbedTexts(ctx, []string{text}))
+}
+
+// embeddingBatchSize implements embeddingBatcher for the rate limiter
+func (p *DeepseekProvider) embeddingBatchSize() int {
+	return p.embeddingBatch
+}
+
+// EmbedTexts generates embedding vectors for the given texts through the
+// /embeddings endpoint, see EmbedText. Inputs are sent in batches of
+// EmbeddingBatchSize, defaulting to DefaultEmbeddingBatchSize.
+func (p *DeepseekProvider) EmbedTexts(ctx context.Context, texts []string) ([][]float32, Usage, error) {
+	if p.embeddingModel == "" {
+		return nil, Usage{}, fmt.Errorf("embeddings not yet supported by Deepseek API")
 	}
 
-	return json.Unmarshal([]byte(resp.Choices[0].Message.Content), result)
+	return embedInBatches(texts, p.embeddingBatch, func(batch []string) ([][]float32, Usage, error) {
+		var resp deepseekEmbeddingResponse
+		httpResp, err := p.client.R().
+			SetContext(ctx).
+			SetBody(deepseekEmbeddingRequest{
+				Model: p.embeddingModel,
+				Input: batch,
+			}).
+			SetResult(&resp).
+			Post("/embeddings")
+
+		if err != nil {
+			return nil, Usage{}, newRequestError(ProviderDeepseek, err)
+		}
+
+		if httpResp.StatusCode() != http.StatusOK {
+			return nil, Usage{}, newStatusError(ProviderDeepseek, httpResp.StatusCode(), httpResp.String())
+		}
+
+		usage := p.convertUsage(p.embeddingModel, resp.Usage)
+		if len(resp.Data) != len(batch) {
+			return nil, usage, fmt.Errorf("expected %d embeddings, got %d", len(batch), len(resp.Data))
+		}
+
+		embeddings := make([][]float32, len(batch))
+		for i, data := range resp.Data {
+			index := data.Index
+			if index < 0 || index >= len(batch) {
+				index = i
+			}
+			embeddings[index] = data.Embedding
+		}
+		return embeddings, usage, nil
+	})
 }
 
-// EmbedText generates an embedding vector for the given text using Deepseek's embedding model
-func (p *DeepseekProvider) EmbedText(ctx context.Context, text string) ([]float32, error) {
-	return nil, fmt.Errorf("embeddings not yet supported by Deepseek API")
+// convertUsage maps Deepseek token usage to the internal usage format
+func (p *DeepseekProvider) convertUsage(model string, usage *deepseekUsage) Usage {
+	converted := Usage{
+		Provider: ProviderDeepseek,
+		Model:    model,
+	}
+	if usage == nil {
+		return converted
+	}
+
+	converted.PromptTokens = usage.PromptTokens
+	converted.CompletionTokens = usage.CompletionTokens
+	converted.TotalTokens = usage.TotalTokens
+	if usage.CompletionTokensDetails != nil {
+		converted.ReasoningTokens = usage.CompletionTokensDetails.ReasoningTokens
+	}
+	return converted
 }
 
 // getModel returns the Deepseek model identifier for the given model type.
@@ -282,24 +488,24 @@ func (p *DeepseekProvider) getModel(modelType ModelType) string {
 // convertMessages transforms internal message format to Deepseek API format.
 func (p *DeepseekProvider) convertMessages(messages []Message) []deepseekMessage {
 	converted := make([]deepseekMessage, len(messages))
-	for i, msg := range messages {
-		content := msg.Content
-		if msg.ToolCall != nil {
-			// Format tool call as JSON in the content
-			toolCall := map[string]interface{}{
-				"name":      msg.ToolCall.Name,
-				"arguments": msg.ToolCall.Arguments,
-			}
-			if jsonContent, err := json.Marshal(toolCall); err == nil {
-				content = string(jsonContent)
-			}
-		}
-
+	for i, msg := range pairToolCalls(messages) {
+		// Deepseek models do not accept images, only the text parts are sent
 		converted[i] = deepseekMessage{
 			Role:    p.mapRole(msg.Role),
-			Content: content,
+			Content: msg.Text(),
 			Name:    msg.Name,
 		}
+		for _, call := range msg.ToolCalls {
+			toolCall := deepseekToolCall{ID: call.ID, Type: "function"}
+			toolCall.Function.Name = call.Name
+			toolCall.Function.Arguments = call.Arguments
+			converted[i].ToolCalls = append(converted[i].ToolCalls, toolCall)
+		}
+		// Tool results are identified by their call ID rather than a name
+		if msg.Role == RoleTool {
+			converted[i].Name = ""
+			converted[i].ToolCallID = msg.ToolCallID
+		}
 	}
 	return converted
 }
@@ -0,0 +1,184 @@
+package llm
+
+import (
+	"context"
+	"net/http"
+	"reflect"
+	"testing"
+
+	toolkit "github.com/soralabs/toolkit/go"
+)
+
+func newTestDeepseekProvider(server *fakeServer) *DeepseekProvider {
+	return NewDeepseekProvider(Config{
+		DefaultProvider: ProviderConfig{
+			Type:    ProviderDeepseek,
+			APIKey:  "test-key",
+			BaseURL: server.URL,
+		},
+	})
+}
+
+func TestDeepseekToolCalls(t *testing.T) {
+	server := newFakeServer(t, func(w http.ResponseWriter, req capturedRequest) {
+		writeJSON(w, `{
+			"model": "deepseek-chat",
+			"choices": [{
+				"message": {
+					"role": "assistant",
+					"content": "",
+					"tool_calls": [
+						{"id": "call_1", "type": "function", "function": {"name": "search", "arguments": "{\"query\":\"go\"}"}},
+						{"id": "call_2", "type": "function", "function": {"name": "search", "arguments": "{\"query\":\"rust\"}"}}
+					]
+				},
+				"finish_reason": "tool_calls"
+			}],
+			"usage": {"prompt_tokens": 30, "completion_tokens": 12, "total_tokens": 42}
+		}`)
+	})
+	provider := newTestDeepseekProvider(server)
+
+	msg, err := provider.GenerateCompletion(context.Background(), CompletionRequest{
+		Messages: []Message{NewUserMessage("Compare go and rust")},
+		Tools:    []toolkit.Tool{&fakeTool{name: "search"}},
+	})
+	if err != nil {
+		t.Fatalf("GenerateCompletion: %v", err)
+	}
+	want := []ToolCall{
+		{ID: "call_1", Name: "search", Arguments: `{"query":"go"}`},
+		{ID: "call_2", Name: "search", Arguments: `{"query":"rust"}`},
+	}
+	if !reflect.DeepEqual(msg.ToolCalls, want) {
+		t.Errorf("tool calls = %+v, want %+v", msg.ToolCalls, want)
+	}
+	if msg.Usage == nil || msg.Usage.Provider != ProviderDeepseek || msg.Usage.TotalTokens != 42 {
+		t.Errorf("usage = %+v", msg.Usage)
+	}
+
+	req := server.last(t)
+	if req.Path != "/chat/completions" {
+		t.Errorf("path = %s, want /chat/completions", req.Path)
+	}
+	if got := req.Header.Get("Authorization"); got != "Bearer test-key" {
+		t.Errorf("Authorization = %q", got)
+	}
+	if got := jsonPath(t, req.Body, "tools.0.function.name"); got != "search" {
+		t.Errorf("tool name = %v", got)
+	}
+	if got := jsonPath(t, req.Body, "tools.0.function.parameters.properties.query.type"); got != "string" {
+		t.Errorf("tool parameters = %v", jsonPath(t, req.Body, "tools.0.function.parameters"))
+	}
+}
+
+func TestDeepseekToolCallRoundTrip(t *testing.T) {
+	server := newFakeServer(t, func(w http.ResponseWriter, req capturedRequest) {
+		messages := req.Body["messages"].([]interface{})
+		if jsonPath(t, messages, "0.role") == "user" && len(messages) == 1 {
+			writeJSON(w, `{
+				"choices": [{
+					"message": {
+						"role": "assistant",
+						"tool_calls": [{"id": "call_1", "type": "function", "function": {"name": "search", "arguments": "{\"query\":\"go\"}"}}]
+					},
+					"finish_reason": "tool_calls"
+				}]
+			}`)
+			return
+		}
+		writeJSON(w, `{"choices": [{"message": {"role": "assistant", "content": "Go has 3 hits."}, "finish_reason": "stop"}]}`)
+	})
+
+	client, err := NewLLMClient(Config{
+		DefaultProvider:   ProviderConfig{Type: ProviderDeepseek, APIKey: "test-key", BaseURL: server.URL},
+		EmbeddingProvider: &ProviderConfig{Type: ProviderHashing},
+		Context:           context.Background(),
+	})
+	if err != nil {
+		t.Fatalf("NewLLMClient: %v", err)
+	}
+	tool := &fakeTool{name: "search", result: `{"hits":3}`}
+
+	msg, err := client.GenerateCompletion(CompletionRequest{
+		Messages: []Message{NewUserMessage("Search for go")},
+		Tools:    []toolkit.Tool{tool},
+	})
+	if err != nil {
+		t.Fatalf("GenerateCompletion: %v", err)
+	}
+	if msg.Content != "Go has 3 hits." {
+		t.Errorf("content = %q", msg.Content)
+	}
+	if tool.callCount() != 1 || tool.calls[0] != `{"query":"go"}` {
+		t.Errorf("tool calls = %v", tool.calls)
+	}
+
+	// The follow-up request replays the assistant's call and answers it by ID
+	if server.count() != 2 {
+		t.Fatalf("requests = %d, want 2", server.count())
+	}
+	messages := server.last(t).Body["messages"].([]interface{})
+	if len(messages) != 3 {
+		t.Fatalf("messages = %v, want user, assistant and tool", messages)
+	}
+	if got := jsonPath(t, messages, "1.tool_calls.0.id"); got != "call_1" {
+		t.Errorf("assistant tool call id = %v", got)
+	}
+	if got := jsonPath(t, messages, "1.tool_calls.0.function.arguments"); got != `{"query":"go"}` {
+		t.Errorf("assistant tool call arguments = %v", got)
+	}
+	toolResult := messages[2].(map[string]interface{})
+	if toolResult["role"] != "tool" || toolResult["tool_call_id"] != "call_1" || toolResult["content"] != `{"hits":3}` {
+		t.Errorf("tool message = %v", toolResult)
+	}
+	if _, ok := toolResult["name"]; ok {
+		t.Errorf("tool message has a name: %v", toolResult)
+	}
+}
+
+func TestDeepseekStreamToolCalls(t *testing.T) {
+	server := newFakeServer(t, func(w http.ResponseWriter, req capturedRequest) {
+		writeSSE(w,
+			`{"choices":[{"delta":{"role":"assistant","content":"Checking"}}]}`,
+			`{"choices":[{"delta":{"tool_calls":[{"index":0,"id":"call_1","type":"function","function":{"name":"search","arguments":""}}]}}]}`,
+			`{"choices":[{"delta":{"tool_calls":[{"index":0,"function":{"arguments":"{\"query\":"}}]}}]}`,
+			`{"choices":[{"delta":{"tool_calls":[{"index":1,"id":"call_2","type":"function","function":{"name":"search","arguments":"{\"query\":\"rust\"}"}}]}}]}`,
+			`{"choices":[{"delta":{"tool_calls":[{"index":0,"function":{"arguments":"\"go\"}"}}]}}]}`,
+			`{"choices":[{"delta":{},"finish_reason":"tool_calls"}]}`,
+			`{"choices":[],"usage":{"prompt_tokens":25,"completion_tokens":18,"total_tokens":43}}`,
+			`[DONE]`,
+		)
+	})
+	provider := newTestDeepseekProvider(server)
+
+	events, err := provider.GenerateCompletionStream(context.Background(), CompletionRequest{
+		Messages: []Message{NewUserMessage("Compare go and rust")},
+		Tools:    []toolkit.Tool{&fakeTool{name: "search"}},
+	})
+	if err != nil {
+		t.Fatalf("GenerateCompletionStream: %v", err)
+	}
+	content, final, err := collectStream(t, events)
+	if err != nil {
+		t.Fatalf("stream error: %v", err)
+	}
+	if content != "Checking" || final == nil || final.Content != "Checking" {
+		t.Errorf("content = %q, final = %+v", content, final)
+	}
+	want := []ToolCall{
+		{ID: "call_1", Name: "search", Arguments: `{"query":"go"}`},
+		{ID: "call_2", Name: "search", Arguments: `{"query":"rust"}`},
+	}
+	if !reflect.DeepEqual(final.ToolCalls, want) {
+		t.Errorf("tool calls = %+v, want %+v", final.ToolCalls, want)
+	}
+	if final.Usage == nil || final.Usage.TotalTokens != 43 {
+		t.Errorf("usage = %+v", final.Usage)
+	}
+
+	req := server.last(t)
+	if req.Body["stream"] != true || jsonPath(t, req.Body, "stream_options.include_usage") != true {
+		t.Errorf("stream request = %v", req.Body)
+	}
+}
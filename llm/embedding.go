@@ -0,0 +1,98 @@
+package llm
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+
+	"github.com/sashabaranov/go-openai"
+)
+
+// ErrEmbeddingModelMismatch is returned when an embedding was generated by a
+// different model than the client's embedding model, e.g. by a fallback
+// provider. Vectors of different models cannot be compared.
+var ErrEmbeddingModelMismatch = errors.New("embedding generated by a different model")
+
+// DefaultEmbeddingBatchSize is the number of inputs per embedding request
+// used when neither the provider nor ProviderConfig.EmbeddingBatchSize set one
+const DefaultEmbeddingBatchSize = 256
+
+// embeddingBatcher is implemented by providers whose EmbedTexts sends one
+// request per batch of at most embeddingBatchSize texts
+type embeddingBatcher interface {
+	embeddingBatchSize() int
+}
+
+// embedInBatches splits texts into batches of at most batchSize inputs, embeds
+// them one batch at a time and returns the embeddings in input order together
+// with the usage of all requests.
+func embedInBatches(texts []string, batchSize int, embed func(batch []string) ([][]float32, Usage, error)) ([][]float32, Usage, error) {
+	if batchSize <= 0 {
+		batchSize = DefaultEmbeddingBatchSize
+	}
+
+	var usage Usage
+	embeddings := make([][]float32, 0, len(texts))
+	for start := 0; start < len(texts); start += batchSize {
+		end := min(start+batchSize, len(texts))
+
+		batch, batchUsage, err := embed(texts[start:end])
+		usage.Add(batchUsage)
+		if err != nil {
+			return nil, usage, err
+		}
+		if len(batch) != end-start {
+			return nil, usage, fmt.Errorf("expected %d embeddings, got %d", end-start, len(batch))
+		}
+		embeddings = append(embeddings, batch...)
+	}
+
+	return embeddings, usage, nil
+}
+
+// firstEmbedding unwraps the result of a single-text EmbedTexts call
+func firstEmbedding(embeddings [][]float32, usage Usage, err error) ([]float32, Usage, error) {
+	if err != nil {
+		return nil, usage, err
+	}
+	if len(embeddings) == 0 {
+		return nil, usage, fmt.Errorf("no embedding returned")
+	}
+	return embeddings[0], usage, nil
+}
+
+// embeddingDimensions returns the vector size a provider config produces, or
+// zero if it is only known after embedding, e.g. for local models
+func embeddingDimensions(config ProviderConfig) int {
+	switch config.Type {
+	case ProviderHashing:
+		return hashingDimensions(config)
+	case ProviderLocal:
+		return 0
+	case ProviderOpenAI, ProviderGemini:
+		// Both shorten embeddings to EmbeddingDimensions
+		if config.EmbeddingDimensions > 0 {
+			return config.EmbeddingDimensions
+		}
+	}
+	return DefaultEmbeddingDimensions[embeddingModelName(config)]
+}
+
+// embeddingModelName returns the embedding model a provider config resolves to
+func embeddingModelName(config ProviderConfig) string {
+	if config.EmbeddingModel != "" {
+		return config.EmbeddingModel
+	}
+	switch config.Type {
+	case ProviderOpenAI:
+		return string(openai.AdaEmbeddingV2)
+	case ProviderGemini:
+		return geminiDefaultEmbeddingModel
+	case ProviderHashing:
+		return fmt.Sprintf("hashing-%d", hashingDimensions(config))
+	case ProviderLocal:
+		return "local:" + filepath.Base(config.ModelPath)
+	default:
+		return ""
+	}
+}
@@ -0,0 +1,85 @@
+package llm
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"time"
+
+	"github.com/soralabs/zen/cache"
+)
+
+// EmbeddingStore persists cached embeddings beyond the in-memory cache,
+// e.g. stores.EmbeddingCacheStore
+type EmbeddingStore interface {
+	GetEmbedding(key string) ([]float32, bool, error)
+	SetEmbedding(key string, embedding []float32) error
+}
+
+// EmbeddingCacheConfig configures the embedding cache of LLMClient
+type EmbeddingCacheConfig struct {
+	MaxSize int            // Entries kept in memory, defaults to 10000
+	TTL     time.Duration  // Lifetime of in-memory entries, defaults to 24 hours
+	Store   EmbeddingStore // Optional persistent backend consulted on in-memory misses
+}
+
+// embeddingCache caches embeddings keyed by a hash of the primary embedding
+// provider, its model and dimension, and the text
+type embeddingCache struct {
+	memory    *cache.Cache
+	store     EmbeddingStore
+	namespace string
+}
+
+func newEmbeddingCache(config EmbeddingCacheConfig, provider ProviderConfig) *embeddingCache {
+	if config.MaxSize <= 0 {
+		config.MaxSize = 10000
+	}
+	if config.TTL <= 0 {
+		config.TTL = 24 * time.Hour
+	}
+
+	return &embeddingCache{
+		memory: cache.New(cache.Config{
+			MaxSize:       config.MaxSize,
+			TTL:           config.TTL,
+			CleanupPeriod: config.TTL / 4,
+		}),
+		store:     config.Store,
+		namespace: fmt.Sprintf("%s\x00%s\x00%d\x00", provider.Type, embeddingModelName(provider), provider.EmbeddingDimensions),
+	}
+}
+
+// key returns the content hash a text is cached under
+func (c *embeddingCache) key(text string) string {
+	sum := sha256.Sum256([]byte(c.namespace + text))
+	return hex.EncodeToString(sum[:])
+}
+
+// get looks the text up in memory and then in the persistent store
+func (c *embeddingCache) get(text string) ([]float32, bool) {
+	key := c.key(text)
+	if value, ok := c.memory.Get(cache.CacheKey(key)); ok {
+		return value.([]float32), true
+	}
+	if c.store == nil {
+		return nil, false
+	}
+
+	embedding, ok, err := c.store.GetEmbedding(key)
+	if err != nil || !ok {
+		return nil, false
+	}
+	c.memory.Set(cache.CacheKey(key), embedding)
+	return embedding, true
+}
+
+// set caches an embedding. Returns an error only if the persistent store failed.
+func (c *embeddingCache) set(text string, embedding []float32) error {
+	key := c.key(text)
+	c.memory.Set(cache.CacheKey(key), embedding)
+	if c.store == nil {
+		return nil
+	}
+	return c.store.SetEmbedding(key, embedding)
+}
@@ -0,0 +1,169 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"sync"
+	"testing"
+)
+
+// mapStore is an in-memory EmbeddingStore
+type mapStore struct {
+	mu         sync.Mutex
+	embeddings map[string][]float32
+	err        error
+}
+
+func newMapStore() *mapStore {
+	return &mapStore{embeddings: make(map[string][]float32)}
+}
+
+func (s *mapStore) GetEmbedding(key string) ([]float32, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	embedding, ok := s.embeddings[key]
+	return embedding, ok, s.err
+}
+
+func (s *mapStore) SetEmbedding(key string, embedding []float32) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.err != nil {
+		return s.err
+	}
+	s.embeddings[key] = embedding
+	return nil
+}
+
+// newCachingClient returns a client embedding each text as its length,
+// caching in store under the provider config. Every embedding request is
+// appended to requests.
+func newCachingClient(t *testing.T, provider ProviderConfig, store EmbeddingStore, requests *[][]string) *LLMClient {
+	client := newTestClient(&funcProvider{
+		caps: allCapabilities,
+		embed: func(ctx context.Context, texts []string) ([][]float32, Usage, error) {
+			*requests = append(*requests, texts)
+			embeddings := make([][]float32, len(texts))
+			for i, text := range texts {
+				embeddings[i] = []float32{float32(len(text))}
+			}
+			return embeddings, Usage{}, nil
+		},
+	})
+	client.embeddingCache = newEmbeddingCache(EmbeddingCacheConfig{Store: store}, provider)
+	t.Cleanup(client.embeddingCache.memory.Close)
+	return client
+}
+
+func TestEmbeddingCacheHits(t *testing.T) {
+	var requests [][]string
+	client := newCachingClient(t, ProviderConfig{Type: ProviderOpenAI}, nil, &requests)
+
+	for range 2 {
+		embedding, err := client.EmbedText("go")
+		if err != nil || !slices.Equal(embedding, []float32{2}) {
+			t.Fatalf("EmbedText = %v, %v", embedding, err)
+		}
+	}
+	if len(requests) != 1 {
+		t.Errorf("requests = %v, want one", requests)
+	}
+}
+
+func TestEmbeddingCachePartialBatch(t *testing.T) {
+	var requests [][]string
+	client := newCachingClient(t, ProviderConfig{Type: ProviderOpenAI}, nil, &requests)
+	if _, err := client.EmbedText("a"); err != nil {
+		t.Fatalf("EmbedText: %v", err)
+	}
+
+	// Only uncached texts are sent, each once, and the results keep the order of the texts
+	embeddings, err := client.EmbedTexts([]string{"bb", "a", "ccc", "bb"})
+	if err != nil {
+		t.Fatalf("EmbedTexts: %v", err)
+	}
+	want := [][]float32{{2}, {1}, {3}, {2}}
+	if !slices.EqualFunc(embeddings, want, slices.Equal) {
+		t.Errorf("embeddings = %v, want %v", embeddings, want)
+	}
+	if len(requests) != 2 || !slices.Equal(requests[1], []string{"bb", "ccc"}) {
+		t.Errorf("requests = %v, want the uncached texts", requests)
+	}
+
+	// A batch of cached texts makes no request
+	if _, err := client.EmbedTexts([]string{"ccc", "a"}); err != nil {
+		t.Fatalf("EmbedTexts: %v", err)
+	}
+	if len(requests) != 2 {
+		t.Errorf("requests = %v, want no new request", requests)
+	}
+}
+
+func TestEmbeddingCacheStore(t *testing.T) {
+	store := newMapStore()
+	provider := ProviderConfig{Type: ProviderOpenAI}
+	var requests [][]string
+	if _, err := newCachingClient(t, provider, store, &requests).EmbedText("go"); err != nil {
+		t.Fatalf("EmbedText: %v", err)
+	}
+	if len(store.embeddings) != 1 {
+		t.Errorf("stored = %d, want 1", len(store.embeddings))
+	}
+
+	// A new client misses in memory and hits the store
+	requests = nil
+	embedding, err := newCachingClient(t, provider, store, &requests).EmbedText("go")
+	if err != nil || !slices.Equal(embedding, []float32{2}) || len(requests) != 0 {
+		t.Errorf("EmbedText = %v, %v with requests %v, want a store hit", embedding, err, requests)
+	}
+
+	// Store failures don't fail embedding
+	store.err = errors.New("store down")
+	embedding, err = newCachingClient(t, provider, store, &requests).EmbedText("rust")
+	if err != nil || !slices.Equal(embedding, []float32{4}) {
+		t.Errorf("EmbedText = %v, %v with a failing store", embedding, err)
+	}
+}
+
+func TestEmbeddingCacheKeys(t *testing.T) {
+	base := ProviderConfig{Type: ProviderOpenAI, EmbeddingModel: "text-embedding-3-small"}
+	key := newEmbeddingCache(EmbeddingCacheConfig{}, base).key("go")
+
+	tests := []struct {
+		name     string
+		provider ProviderConfig
+		text     string
+		same     bool
+	}{
+		{"same model and text", base, "go", true},
+		{"ignores chat models", ProviderConfig{Type: ProviderOpenAI, EmbeddingModel: "text-embedding-3-small", ModelConfig: map[ModelType]string{ModelTypeDefault: "gpt-4o"}}, "go", true},
+		{"other text", base, "rust", false},
+		{"other model", ProviderConfig{Type: ProviderOpenAI, EmbeddingModel: "text-embedding-3-large"}, "go", false},
+		{"default model", ProviderConfig{Type: ProviderOpenAI}, "go", false},
+		{"other dimensions", ProviderConfig{Type: ProviderOpenAI, EmbeddingModel: "text-embedding-3-small", EmbeddingDimensions: 512}, "go", false},
+		{"other provider", ProviderConfig{Type: ProviderGemini, EmbeddingModel: "text-embedding-3-small"}, "go", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cache := newEmbeddingCache(EmbeddingCacheConfig{}, tt.provider)
+			defer cache.memory.Close()
+			if got := cache.key(tt.text) == key; got != tt.same {
+				t.Errorf("same key = %v, want %v", got, tt.same)
+			}
+		})
+	}
+
+	// Embeddings of another model sharing the store are not served
+	store := newMapStore()
+	var requests [][]string
+	if _, err := newCachingClient(t, base, store, &requests).EmbedText("go"); err != nil {
+		t.Fatalf("EmbedText: %v", err)
+	}
+	if _, err := newCachingClient(t, tests[3].provider, store, &requests).EmbedText("go"); err != nil {
+		t.Fatalf("EmbedText: %v", err)
+	}
+	if len(requests) != 2 {
+		t.Errorf("requests = %v, want one per model", requests)
+	}
+}
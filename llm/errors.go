@@ -0,0 +1,125 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+
+	"github.com/sashabaranov/go-openai"
+)
+
+// ErrorKind classifies provider failures to decide whether a call is retried
+type ErrorKind string
+
+const (
+	ErrorKindRateLimit      ErrorKind = "rate_limit"
+	ErrorKindTimeout        ErrorKind = "timeout"
+	ErrorKindServer         ErrorKind = "server"
+	ErrorKindInvalidRequest ErrorKind = "invalid_request"
+	ErrorKindUnknown        ErrorKind = "unknown"
+)
+
+// Retryable reports whether a failure of this kind may succeed when retried
+func (k ErrorKind) Retryable() bool {
+	switch k {
+	case ErrorKindRateLimit, ErrorKindTimeout, ErrorKindServer:
+		return true
+	default:
+		return false
+	}
+}
+
+// ProviderError is returned by providers for failed API calls
+type ProviderError struct {
+	Provider   ProviderType
+	Kind       ErrorKind
+	StatusCode int
+	Err        error
+}
+
+func (e *ProviderError) Error() string {
+	if e.StatusCode != 0 {
+		return fmt.Sprintf("%s API error (status %d): %v", e.Provider, e.StatusCode, e.Err)
+	}
+	return fmt.Sprintf("%s API error: %v", e.Provider, e.Err)
+}
+
+func (e *ProviderError) Unwrap() error {
+	return e.Err
+}
+
+// newStatusError creates a ProviderError for a non-successful HTTP response
+func newStatusError(provider ProviderType, statusCode int, body string) error {
+	return &ProviderError{
+		Provider:   provider,
+		Kind:       kindForStatus(statusCode),
+		StatusCode: statusCode,
+		Err:        errors.New(body),
+	}
+}
+
+// newRequestError creates a ProviderError for a request that never got a response
+func newRequestError(provider ProviderType, err error) error {
+	return &ProviderError{
+		Provider: provider,
+		Kind:     ClassifyError(err),
+		Err:      err,
+	}
+}
+
+// ClassifyError determines the kind of a provider error
+func ClassifyError(err error) ErrorKind {
+	if err == nil {
+		return ErrorKindUnknown
+	}
+
+	var providerErr *ProviderError
+	if errors.As(err, &providerErr) && providerErr.Kind != "" {
+		return providerErr.Kind
+	}
+
+	var apiErr *openai.APIError
+	if errors.As(err, &apiErr) {
+		return kindForStatus(apiErr.HTTPStatusCode)
+	}
+	var requestErr *openai.RequestError
+	if errors.As(err, &requestErr) {
+		return kindForStatus(requestErr.HTTPStatusCode)
+	}
+
+	if errors.Is(err, context.DeadlineExceeded) {
+		return ErrorKindTimeout
+	}
+	if errors.Is(err, context.Canceled) {
+		return ErrorKindUnknown
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		if netErr.Timeout() {
+			return ErrorKindTimeout
+		}
+		// Connection refused, reset, DNS failures and the like
+		return ErrorKindServer
+	}
+
+	return ErrorKindUnknown
+}
+
+// kindForStatus maps an HTTP status code to an error kind
+func kindForStatus(statusCode int) ErrorKind {
+	switch {
+	case statusCode == http.StatusTooManyRequests:
+		return ErrorKindRateLimit
+	case statusCode == http.StatusRequestTimeout || statusCode == http.StatusGatewayTimeout:
+		return ErrorKindTimeout
+	case statusCode >= 500:
+		return ErrorKindServer
+	case statusCode >= 400:
+		return ErrorKindInvalidRequest
+	default:
+		return ErrorKindUnknown
+	}
+}
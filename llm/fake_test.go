@@ -0,0 +1,257 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	toolkit "github.com/soralabs/toolkit/go"
+)
+
+// capturedRequest is a request received by a fakeServer
+type capturedRequest struct {
+	Method string
+	Path   string
+	Query  string
+	Header http.Header
+	Body   map[string]interface{}
+}
+
+// fakeServer stands in for a provider API and records every request
+type fakeServer struct {
+	*httptest.Server
+	mu       sync.Mutex
+	requests []capturedRequest
+}
+
+// newFakeServer starts a fake API answering every request with respond
+func newFakeServer(t *testing.T, respond func(w http.ResponseWriter, req capturedRequest)) *fakeServer {
+	t.Helper()
+	s := &fakeServer{}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, err := io.ReadAll(r.Body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		req := capturedRequest{
+			Method: r.Method,
+			Path:   r.URL.Path,
+			Query:  r.URL.RawQuery,
+			Header: r.Header.Clone(),
+		}
+		if len(data) > 0 {
+			if err := json.Unmarshal(data, &req.Body); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+		}
+
+		s.mu.Lock()
+		s.requests = append(s.requests, req)
+		s.mu.Unlock()
+		respond(w, req)
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+// last returns the most recent request
+func (s *fakeServer) last(t *testing.T) capturedRequest {
+	t.Helper()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.requests) == 0 {
+		t.Fatal("no request received")
+	}
+	return s.requests[len(s.requests)-1]
+}
+
+// count returns the number of requests received
+func (s *fakeServer) count() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.requests)
+}
+
+// writeJSON answers with a JSON body
+func writeJSON(w http.ResponseWriter, body string) {
+	w.Header().Set("Content-Type", "application/json")
+	io.WriteString(w, body)
+}
+
+// writeSSE answers with server-sent events, one data line each
+func writeSSE(w http.ResponseWriter, events ...string) {
+	w.Header().Set("Content-Type", "text/event-stream")
+	for _, event := range events {
+		fmt.Fprintf(w, "data: %s\n\n", event)
+	}
+}
+
+// jsonPath returns the value at a dot-separated path of decoded JSON, where
+// numeric segments index arrays
+func jsonPath(t *testing.T, data interface{}, path string) interface{} {
+	t.Helper()
+	for _, key := range strings.Split(path, ".") {
+		switch value := data.(type) {
+		case map[string]interface{}:
+			data = value[key]
+		case []interface{}:
+			var i int
+			if _, err := fmt.Sscan(key, &i); err != nil || i < 0 || i >= len(value) {
+				t.Fatalf("%s: no element %s in %v", path, key, value)
+			}
+			data = value[i]
+		default:
+			t.Fatalf("%s: cannot index %v with %s", path, data, key)
+		}
+	}
+	return data
+}
+
+// collectStream reads a completion stream until it closes
+func collectStream(t *testing.T, events <-chan StreamEvent) (content string, final *Message, err error) {
+	t.Helper()
+	for event := range events {
+		switch event.Type {
+		case StreamEventContent:
+			content += event.Content
+		case StreamEventDone:
+			final = event.Message
+		case StreamEventError:
+			err = event.Err
+		}
+	}
+	return content, final, err
+}
+
+// fakeTool is a toolkit.Tool returning a fixed result
+type fakeTool struct {
+	name   string
+	params string
+	result string
+	err    error
+
+	mu    sync.Mutex
+	calls []string // Arguments of every call
+}
+
+func (f *fakeTool) GetName() string        { return f.name }
+func (f *fakeTool) GetDescription() string { return "fake " + f.name }
+
+func (f *fakeTool) GetSchema() toolkit.Schema {
+	params := f.params
+	if params == "" {
+		params = `{"type":"object","properties":{"query":{"type":"string"}},"required":["query"]}`
+	}
+	return toolkit.Schema{Parameters: json.RawMessage(params)}
+}
+
+func (f *fakeTool) Execute(ctx context.Context, params json.RawMessage) (json.RawMessage, error) {
+	f.mu.Lock()
+	f.calls = append(f.calls, string(params))
+	f.mu.Unlock()
+	if f.err != nil {
+		return nil, f.err
+	}
+	return json.RawMessage(f.result), nil
+}
+
+// callCount returns the number of times the tool ran
+func (f *fakeTool) callCount() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return len(f.calls)
+}
+
+// funcProvider serves calls with its function fields. Calls without a
+// function fail.
+type funcProvider struct {
+	caps       Capabilities
+	complete   func(ctx context.Context, req CompletionRequest) (Message, error)
+	stream     func(ctx context.Context, req CompletionRequest) (<-chan StreamEvent, error)
+	structured func(ctx context.Context, req StructuredOutputRequest, result interface{}) (Usage, error)
+	embed      func(ctx context.Context, texts []string) ([][]float32, Usage, error)
+}
+
+// allCapabilities enables every feature of a funcProvider
+var allCapabilities = Capabilities{
+	Completion:       true,
+	Tools:            true,
+	StructuredOutput: true,
+	Embeddings:       true,
+	Vision:           true,
+	Streaming:        true,
+	MaxContextTokens: 128000,
+}
+
+func (p *funcProvider) Capabilities() Capabilities { return p.caps }
+
+func (p *funcProvider) GenerateCompletion(ctx context.Context, req CompletionRequest) (Message, error) {
+	if p.complete == nil {
+		return Message{}, fmt.Errorf("unexpected completion")
+	}
+	return p.complete(ctx, req)
+}
+
+func (p *funcProvider) GenerateCompletionStream(ctx context.Context, req CompletionRequest) (<-chan StreamEvent, error) {
+	if p.stream == nil {
+		return nil, fmt.Errorf("unexpected stream")
+	}
+	return p.stream(ctx, req)
+}
+
+func (p *funcProvider) GenerateStructuredOutput(ctx context.Context, req StructuredOutputRequest, result interface{}) (Usage, error) {
+	if p.structured == nil {
+		return Usage{}, fmt.Errorf("unexpected structured output")
+	}
+	return p.structured(ctx, req, result)
+}
+
+func (p *funcProvider) EmbedText(ctx context.Context, text string) ([]float32, Usage, error) {
+	embeddings, usage, err := p.EmbedTexts(ctx, []string{text})
+	if err != nil {
+		return nil, usage, err
+	}
+	return embeddings[0], usage, nil
+}
+
+func (p *funcProvider) EmbedTexts(ctx context.Context, texts []string) ([][]float32, Usage, error) {
+	if p.embed == nil {
+		return nil, Usage{}, fmt.Errorf("unexpected embedding")
+	}
+	return p.embed(ctx, texts)
+}
+
+// newTestClient returns a client whose chat and embedding providers are the
+// given provider and its fallbacks. Failed calls are not retried.
+func newTestClient(providers ...Provider) *LLMClient {
+	return &LLMClient{
+		defaultProvider:    providers[0],
+		chatProviders:      providers,
+		embeddingProviders: providers,
+		providers:          providers,
+		router:             &router{},
+		retry:              RetryConfig{MaxAttempts: 1},
+		maxToolSteps:       DefaultMaxToolSteps,
+		maxRepairs:         DefaultMaxStructuredRepairs,
+		prices:             mergePrices(nil),
+		ctx:                context.Background(),
+	}
+}
+
+// streamOf returns a closed stream of the events
+func streamOf(events ...StreamEvent) <-chan StreamEvent {
+	stream := make(chan StreamEvent, len(events))
+	for _, event := range events {
+		stream <- event
+	}
+	close(stream)
+	return stream
+}
@@ -0,0 +1,717 @@
+package llm
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/go-resty/resty/v2"
+	"github.com/soralabs/zen/logger"
+)
+
+const (
+	geminiDefaultEmbeddingModel = "text-embedding-004"
+	// geminiEmbeddingBatchSize is the maximum number of inputs per batchEmbedContents request
+	geminiEmbeddingBatchSize = 100
+)
+
+type GeminiProvider struct {
+	client              *resty.Client
+	models              map[ModelType]string
+	embeddingModel      string
+	embeddingDimensions int
+	embeddingBatch      int
+	logger              *logger.Logger
+	roles               map[Role]string
+}
+
+// NewGeminiProvider creates and returns a new GeminiProvider instance for the
+// Google Gemini REST API, initializing a default mapping for models and roles
+// if none are provided.
+func NewGeminiProvider(config Config) *GeminiProvider {
+	// Default model mapping if not provided
+	models := config.DefaultProvider.ModelConfig
+	if models == nil {
+		models = defaultModels[ProviderGemini]
+	}
+
+	// Role mapping. System messages are sent as the system instruction and
+	// tool results are sent back as user function responses.
+	roles := map[Role]string{
+		RoleUser:      "user",
+		RoleAssistant: "model",
+		RoleTool:      "user",
+	}
+
+	baseURL := config.DefaultProvider.BaseURL
+	if baseURL == "" {
+		baseURL = "https://generativelanguage.googleapis.com/v1beta"
+	}
+
+	client := resty.New().
+		SetBaseURL(baseURL).
+		SetHeader("x-goog-api-key", config.DefaultProvider.APIKey).
+		SetHeader("Content-Type", "application/json").
+		SetHeaders(config.DefaultProvider.Headers).
+		SetTimeout(2 * time.Minute)
+
+	embeddingBatch := config.DefaultProvider.EmbeddingBatchSize
+	if embeddingBatch <= 0 {
+		embeddingBatch = geminiEmbeddingBatchSize
+	}
+
+	return &GeminiProvider{
+		client:              client,
+		models:              models,
+		embeddingModel:      embeddingModelName(config.DefaultProvider),
+		embeddingDimensions: config.DefaultProvider.EmbeddingDimensions,
+		embeddingBatch:      embeddingBatch,
+		logger:              config.Logger,
+		roles:               roles,
+	}
+}
+
+type geminiGenerateRequest struct {
+	Contents          []geminiContent         `json:"contents"`
+	SystemInstruction *geminiContent          `json:"systemInstruction,omitempty"`
+	Tools             []geminiTool            `json:"tools,omitempty"`
+	GenerationConfig  *geminiGenerationConfig `json:"generationConfig,omitempty"`
+}
+
+type geminiContent struct {
+	Role  string       `json:"role,omitempty"`
+	Parts []geminiPart `json:"parts"`
+}
+
+type geminiPart struct {
+	Text             string                  `json:"text,omitempty"`
+	Thought          bool                    `json:"thought,omitempty"`
+	InlineData       *geminiBlob             `json:"inlineData,omitempty"`
+	FileData         *geminiFileData         `json:"fileData,omitempty"`
+	FunctionCall     *geminiFunctionCall     `json:"functionCall,omitempty"`
+	FunctionResponse *geminiFunctionResponse `json:"functionResponse,omitempty"`
+}
+
+type geminiBlob struct {
+	MimeType string `json:"mimeType"`
+	Data     string `json:"data"`
+}
+
+type geminiFileData struct {
+	MimeType string `json:"mimeType,omitempty"`
+	FileURI  string `json:"fileUri"`
+}
+
+type geminiFunctionCall struct {
+	ID   string          `json:"id,omitempty"`
+	Name string          `json:"name"`
+	Args json.RawMessage `json:"args,omitempty"`
+}
+
+type geminiFunctionResponse struct {
+	ID       string          `json:"id,omitempty"`
+	Name     string          `json:"name"`
+	Response json.RawMessage `json:"response"`
+}
+
+type geminiTool struct {
+	FunctionDeclarations []geminiFunctionDeclaration `json:"functionDeclarations"`
+}
+
+type geminiFunctionDeclaration struct {
+	Name        string      `json:"name"`
+	Description string      `json:"description,omitempty"`
+	Parameters  interface{} `json:"parameters,omitempty"`
+}
+
+type geminiGenerationConfig struct {
+	Temperature      float32     `json:"temperature,omitempty"`
+	ResponseMimeType string      `json:"responseMimeType,omitempty"`
+	ResponseSchema   interface{} `json:"responseSchema,omitempty"`
+}
+
+type geminiGenerateResponse struct {
+	Candidates []struct {
+		Content      geminiContent `json:"content"`
+		FinishReason string        `json:"finishReason"`
+	} `json:"candidates"`
+	PromptFeedback *struct {
+		BlockReason string `json:"blockReason"`
+	} `json:"promptFeedback,omitempty"`
+	UsageMetadata *geminiUsage `json:"usageMetadata,omitempty"`
+	ModelVersion  string       `json:"modelVersion"`
+}
+
+type geminiUsage struct {
+	PromptTokenCount     int `json:"promptTokenCount"`
+	CandidatesTokenCount int `json:"candidatesTokenCount"`
+	ThoughtsTokenCount   int `json:"thoughtsTokenCount"`
+	TotalTokenCount      int `json:"totalTokenCount"`
+}
+
+type geminiEmbedRequest struct {
+	Requests []geminiEmbedContentRequest `json:"requests"`
+}
+
+type geminiEmbedContentRequest struct {
+	Model                string        `json:"model"`
+	Content              geminiContent `json:"content"`
+	OutputDimensionality int           `json:"outputDimensionality,omitempty"`
+}
+
+type geminiEmbedResponse struct {
+	Embeddings []struct {
+		Values []float32 `json:"values"`
+	} `json:"embeddings"`
+}
+
+// Capabilities reports support for every feature
+func (p *GeminiProvider) Capabilities() Capabilities {
+	return Capabilities{
+		Completion:       true,
+		Tools:            true,
+		StructuredOutput: true,
+		Embeddings:       true,
+		Vision:           true,
+		Streaming:        true,
+		MaxContextTokens: maxContextLimit(p.models, nil),
+	}
+}
+
+// GenerateCompletion sends a conversation to the Gemini generateContent
+// endpoint and returns the model's text completion or requested tool calls.
+func (p *GeminiProvider) GenerateCompletion(ctx context.Context, req CompletionRequest) (Message, error) {
+	genReq, err := p.buildGenerateRequest(req)
+	if err != nil {
+		return Message{}, err
+	}
+
+	model := p.getModel(req.ModelType)
+	resp, err := p.generateContent(ctx, model, genReq)
+	if err != nil {
+		return Message{}, err
+	}
+
+	usage := p.convertUsage(model, resp.UsageMetadata)
+	message := Message{
+		Role:  RoleAssistant,
+		Usage: &usage,
+	}
+	var content strings.Builder
+	for _, part := range resp.Candidates[0].Content.Parts {
+		content.WriteString(p.appendPart(&message, part))
+	}
+	message.Content = content.String()
+
+	return message, nil
+}
+
+// GenerateCompletionStream sends a conversation to the Gemini
+// streamGenerateContent endpoint and streams the completion back as
+// server-sent events.
+func (p *GeminiProvider) GenerateCompletionStream(ctx context.Context, req CompletionRequest) (<-chan StreamEvent, error) {
+	model := p.getModel(req.ModelType)
+	body, err := p.openStream(ctx, model, req)
+	if err != nil {
+		return nil, err
+	}
+
+	events := make(chan StreamEvent)
+	go func() {
+		defer close(events)
+		defer body.Close()
+
+		message := Message{Role: RoleAssistant}
+		var content strings.Builder
+		var streamUsage *geminiUsage
+		err := readSSE(body, func(_, data string) error {
+			var chunk geminiGenerateResponse
+			if err := json.Unmarshal([]byte(data), &chunk); err != nil {
+				return fmt.Errorf("failed to parse stream event: %w", err)
+			}
+			if chunk.UsageMetadata != nil {
+				streamUsage = chunk.UsageMetadata
+			}
+			if len(chunk.Candidates) == 0 {
+				return nil
+			}
+
+			for _, part := range chunk.Candidates[0].Content.Parts {
+				text := p.appendPart(&message, part)
+				if text == "" {
+					continue
+				}
+				content.WriteString(text)
+				if !sendStreamEvent(ctx, events, StreamEvent{Type: StreamEventContent, Content: text}) {
+					return ctx.Err()
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			sendStreamEvent(ctx, events, StreamEvent{Type: StreamEventError, Err: fmt.Errorf("Gemini stream error: %w", err)})
+			return
+		}
+
+		usage := p.convertUsage(model, streamUsage)
+		message.Content = content.String()
+		message.Usage = &usage
+		sendStreamEvent(ctx, events, StreamEvent{Type: StreamEventDone, Message: &message})
+	}()
+
+	return events, nil
+}
+
+// GenerateStructuredOutput asks the Gemini API for JSON following the result
+// type's schema. Schemas Gemini cannot express, such as maps, are sent as
+// instructions in the system prompt instead.
+func (p *GeminiProvider) GenerateStructuredOutput(ctx context.Context, req StructuredOutputRequest, result interface{}) (Usage, error) {
+	schema, err := GenerateSchema(result)
+	if err != nil {
+		return Usage{}, fmt.Errorf("failed to generate schema: %w", err)
+	}
+
+	messages := req.Messages
+	genConfig := &geminiGenerationConfig{
+		Temperature:      req.Temperature,
+		ResponseMimeType: "application/json",
+	}
+	if responseSchema, ok := geminiSchema(schema); ok {
+		genConfig.ResponseSchema = responseSchema
+	} else {
+		schemaJSON, err := json.MarshalIndent(schema, "", "  ")
+		if err != nil {
+			return Usage{}, fmt.Errorf("failed to encode schema: %w", err)
+		}
+		name := req.SchemaName
+		if name == "" {
+			name = "response"
+		}
+		messages = append([]Message{{
+			Role: RoleSystem,
+			Content: fmt.Sprintf("Respond with a single JSON object named %s that conforms to the following JSON Schema. "+
+				"Include every required property and use only the listed enum values.\n\n%s", name, schemaJSON),
+		}}, messages...)
+	}
+
+	system, contents := p.convertMessages(messages)
+	genReq := geminiGenerateRequest{
+		Contents:          contents,
+		SystemInstruction: system,
+		GenerationConfig:  genConfig,
+	}
+
+	model := p.getModel(req.ModelType)
+	resp, err := p.generateContent(ctx, model, genReq)
+	if err != nil {
+		return Usage{}, fmt.Errorf("StructuredOutput %w", err)
+	}
+
+	usage := p.convertUsage(model, resp.UsageMetadata)
+	var content strings.Builder
+	for _, part := range resp.Candidates[0].Content.Parts {
+		if !part.Thought {
+			content.WriteString(part.Text)
+		}
+	}
+
+	return usage, decodeStructuredOutput(content.String(), schema, result)
+}
+
+// EmbedText generates an embedding vector for the given text
+func (p *GeminiProvider) EmbedText(ctx context.Context, text string) ([]float32, Usage, error) {
+	return firstEmbedding(p.EmbedTexts(ctx, []string{text}))
+}
+
+// embeddingBatchSize implements embeddingBatcher for the rate limiter
+func (p *GeminiProvider) embeddingBatchSize() int {
+	return p.embeddingBatch
+}
+
+// EmbedTexts generates embedding vectors for the given texts through the
+// batchEmbedContents endpoint, sending at most 100 inputs per request.
+// The API does not report token usage for embeddings.
+func (p *GeminiProvider) EmbedTexts(ctx context.Context, texts []string) ([][]float32, Usage, error) {
+	model := strings.TrimPrefix(p.embeddingModel, "models/")
+	return embedInBatches(texts, p.embeddingBatch, func(batch []string) ([][]float32, Usage, error) {
+		embedReq := geminiEmbedRequest{Requests: make([]geminiEmbedContentRequest, len(batch))}
+		for i, text := range batch {
+			embedReq.Requests[i] = geminiEmbedContentRequest{
+				Model:                "models/" + model,
+				Content:              geminiContent{Parts: []geminiPart{{Text: text}}},
+				OutputDimensionality: p.embeddingDimensions,
+			}
+		}
+
+		var resp geminiEmbedResponse
+		httpResp, err := p.client.R().
+			SetContext(ctx).
+			SetBody(embedReq).
+			SetResult(&resp).
+			Post(geminiModelPath(model, "batchEmbedContents"))
+
+		if err != nil {
+			return nil, Usage{}, newRequestError(ProviderGemini, err)
+		}
+
+		if httpResp.StatusCode() != http.StatusOK {
+			return nil, Usage{}, newStatusError(ProviderGemini, httpResp.StatusCode(), httpResp.String())
+		}
+
+		usage := Usage{Provider: ProviderGemini, Model: model}
+		embeddings := make([][]float32, len(resp.Embeddings))
+		for i, embedding := range resp.Embeddings {
+			embeddings[i] = embedding.Values
+		}
+		return embeddings, usage, nil
+	})
+}
+
+// convertUsage maps Gemini token usage to the internal usage format. Thinking
+// tokens are billed as output and counted as completion tokens.
+func (p *GeminiProvider) convertUsage(model string, usage *geminiUsage) Usage {
+	converted := Usage{
+		Provider: ProviderGemini,
+		Model:    model,
+	}
+	if usage != nil {
+		converted.PromptTokens = usage.PromptTokenCount
+		converted.CompletionTokens = usage.CandidatesTokenCount + usage.ThoughtsTokenCount
+		converted.TotalTokens = usage.TotalTokenCount
+	}
+	return converted
+}
+
+// appendPart adds a function call of a response part to the message and
+// returns the part's text. Thought summaries become the message's reasoning.
+func (p *GeminiProvider) appendPart(message *Message, part geminiPart) string {
+	switch {
+	case part.FunctionCall != nil:
+		args := string(part.FunctionCall.Args)
+		if args == "" {
+			args = "{}"
+		}
+		id := part.FunctionCall.ID
+		if id == "" {
+			id = fmt.Sprintf("call_%d", len(message.ToolCalls))
+		}
+		message.ToolCalls = append(message.ToolCalls, ToolCall{
+			ID:        id,
+			Name:      part.FunctionCall.Name,
+			Arguments: args,
+		})
+	case part.Thought:
+		message.Reasoning += part.Text
+	default:
+		return part.Text
+	}
+	return ""
+}
+
+// generateContent performs a non-streaming generateContent call
+func (p *GeminiProvider) generateContent(ctx context.Context, model string, genReq geminiGenerateRequest) (*geminiGenerateResponse, error) {
+	var resp geminiGenerateResponse
+	httpResp, err := p.client.R().
+		SetContext(ctx).
+		SetBody(genReq).
+		SetResult(&resp).
+		Post(geminiModelPath(model, "generateContent"))
+
+	if err != nil {
+		return nil, newRequestError(ProviderGemini, err)
+	}
+
+	if httpResp.StatusCode() != http.StatusOK {
+		return nil, newStatusError(ProviderGemini, httpResp.StatusCode(), httpResp.String())
+	}
+
+	if len(resp.Candidates) == 0 {
+		if resp.PromptFeedback != nil && resp.PromptFeedback.BlockReason != "" {
+			return nil, fmt.Errorf("prompt blocked: %s", resp.PromptFeedback.BlockReason)
+		}
+		return nil, fmt.Errorf("no completion returned")
+	}
+
+	return &resp, nil
+}
+
+// openStream starts a streamGenerateContent call and returns the raw event stream body
+func (p *GeminiProvider) openStream(ctx context.Context, model string, req CompletionRequest) (io.ReadCloser, error) {
+	genReq, err := p.buildGenerateRequest(req)
+	if err != nil {
+		return nil, err
+	}
+
+	httpResp, err := p.client.R().
+		SetContext(ctx).
+		SetBody(genReq).
+		SetQueryParam("alt", "sse").
+		SetDoNotParseResponse(true).
+		Post(geminiModelPath(model, "streamGenerateContent"))
+	if err != nil {
+		return nil, newRequestError(ProviderGemini, err)
+	}
+
+	body := httpResp.RawBody()
+	if httpResp.StatusCode() != http.StatusOK {
+		defer body.Close()
+		errBody, _ := io.ReadAll(body)
+		return nil, newStatusError(ProviderGemini, httpResp.StatusCode(), string(errBody))
+	}
+
+	return body, nil
+}
+
+// buildGenerateRequest converts a completion request into a Gemini generateContent request
+func (p *GeminiProvider) buildGenerateRequest(req CompletionRequest) (geminiGenerateRequest, error) {
+	declarations := make([]geminiFunctionDeclaration, len(req.Tools))
+	for i, tool := range req.Tools {
+		var params interface{}
+		if err := json.Unmarshal(tool.GetSchema().Parameters, &params); err != nil {
+			return geminiGenerateRequest{}, fmt.Errorf("failed to parse tool parameters: %w", err)
+		}
+
+		declarations[i] = geminiFunctionDeclaration{
+			Name:        tool.GetName(),
+			Description: tool.GetDescription(),
+		}
+		// Functions without arguments omit their parameters, as Gemini
+		// rejects objects without properties
+		if properties, _ := params.(map[string]interface{})["properties"].(map[string]interface{}); len(properties) == 0 {
+			continue
+		}
+		parameters, ok := geminiSchema(params)
+		if !ok {
+			return geminiGenerateRequest{}, fmt.Errorf("parameters of tool %s cannot be expressed as a Gemini schema", tool.GetName())
+		}
+		declarations[i].Parameters = parameters
+	}
+
+	system, contents := p.convertMessages(req.Messages)
+	genReq := geminiGenerateRequest{
+		Contents:          contents,
+		SystemInstruction: system,
+	}
+	if req.Temperature != 0 {
+		genReq.GenerationConfig = &geminiGenerationConfig{Temperature: req.Temperature}
+	}
+	if len(declarations) > 0 {
+		genReq.Tools = []geminiTool{{FunctionDeclarations: declarations}}
+	}
+
+	return genReq, nil
+}
+
+// getModel returns the Gemini model identifier for the given model type.
+// Falls back to default model if type is not found.
+func (p *GeminiProvider) getModel(modelType ModelType) string {
+	if model, ok := p.models[modelType]; ok {
+		return model
+	}
+	return p.models[ModelTypeDefault]
+}
+
+// convertMessages transforms internal message format to Gemini API format.
+// System messages are joined into the system instruction, tool calls and
+// results become function call and response parts, and consecutive messages
+// with the same role are merged since the API requires alternating turns.
+func (p *GeminiProvider) convertMessages(messages []Message) (*geminiContent, []geminiContent) {
+	var system []string
+	var converted []geminiContent
+	callNames := make(map[string]string)
+
+	for _, msg := range pairToolCalls(messages) {
+		if msg.Role == RoleSystem {
+			system = append(system, msg.Text())
+			continue
+		}
+
+		var parts []geminiPart
+		switch {
+		case len(msg.ToolCalls) > 0:
+			if msg.Content != "" {
+				parts = append(parts, geminiPart{Text: msg.Content})
+			}
+			for _, call := range msg.ToolCalls {
+				args := json.RawMessage(call.Arguments)
+				if len(args) == 0 {
+					args = json.RawMessage("{}")
+				}
+				callNames[call.ID] = call.Name
+				parts = append(parts, geminiPart{FunctionCall: &geminiFunctionCall{Name: call.Name, Args: args}})
+			}
+		case msg.Role == RoleTool:
+			name := callNames[msg.ToolCallID]
+			if name == "" {
+				name = msg.Name
+			}
+			parts = append(parts, geminiPart{FunctionResponse: &geminiFunctionResponse{
+				Name:     name,
+				Response: geminiFunctionResult(msg.Content, msg.ToolError),
+			}})
+		case len(msg.Parts) > 0:
+			if msg.Content != "" {
+				parts = append(parts, geminiPart{Text: msg.Content})
+			}
+			parts = append(parts, p.convertParts(msg.Parts)...)
+		case msg.Content != "":
+			parts = append(parts, geminiPart{Text: msg.Content})
+		}
+
+		// The API rejects empty text parts and contents without parts
+		if len(parts) == 0 {
+			continue
+		}
+		role := p.mapRole(msg.Role)
+		if n := len(converted); n > 0 && converted[n-1].Role == role {
+			converted[n-1].Parts = append(converted[n-1].Parts, parts...)
+			continue
+		}
+		converted = append(converted, geminiContent{
+			Role:  role,
+			Parts: parts,
+		})
+	}
+
+	if len(system) == 0 {
+		return nil, converted
+	}
+	return &geminiContent{Parts: []geminiPart{{Text: strings.Join(system, "\n\n")}}}, converted
+}
+
+// convertParts maps message parts to Gemini text, inline data and file parts
+func (p *GeminiProvider) convertParts(parts []ContentPart) []geminiPart {
+	var converted []geminiPart
+	for _, part := range parts {
+		switch {
+		case part.Type == ContentPartText && part.Text != "":
+			converted = append(converted, geminiPart{Text: part.Text})
+		case part.Type == ContentPartImage && part.ImageURL != "":
+			converted = append(converted, geminiPart{FileData: &geminiFileData{
+				MimeType: imageURLMediaType(part),
+				FileURI:  part.ImageURL,
+			}})
+		case part.Type == ContentPartImage:
+			converted = append(converted, geminiPart{InlineData: &geminiBlob{
+				MimeType: part.mediaType(),
+				Data:     base64.StdEncoding.EncodeToString(part.ImageData),
+			}})
+		}
+	}
+	return converted
+}
+
+// mapRole converts internal role types to Gemini API role strings.
+func (p *GeminiProvider) mapRole(role Role) string {
+	if mappedRole, ok := p.roles[role]; ok {
+		return mappedRole
+	}
+	return string(role)
+}
+
+// geminiModelPath returns the path of a method of a model
+func geminiModelPath(model, method string) string {
+	return "/models/" + url.PathEscape(strings.TrimPrefix(model, "models/")) + ":" + method
+}
+
+// geminiFunctionResult wraps a tool result in the JSON object Gemini expects
+// as a function response, unless it already is one. Failed calls are reported
+// under the "error" key.
+func geminiFunctionResult(content string, failed bool) json.RawMessage {
+	if failed {
+		result, _ := json.Marshal(map[string]string{"error": content})
+		return result
+	}
+	var object map[string]json.RawMessage
+	if err := json.Unmarshal([]byte(content), &object); err == nil && object != nil {
+		return json.RawMessage(content)
+	}
+	result, _ := json.Marshal(map[string]string{"result": content})
+	return result
+}
+
+// imageURLMediaType returns the media type of an image URL, guessed from its
+// extension when the part does not set one
+func imageURLMediaType(part ContentPart) string {
+	if part.MIMEType != "" {
+		return part.MIMEType
+	}
+	if parsed, err := url.Parse(part.ImageURL); err == nil {
+		if mediaType := mime.TypeByExtension(path.Ext(parsed.Path)); strings.HasPrefix(mediaType, "image/") {
+			return mediaType
+		}
+	}
+	return "image/jpeg"
+}
+
+// geminiSchemaKeys are the JSON Schema keywords Gemini's OpenAPI-based schema supports
+var geminiSchemaKeys = map[string]bool{
+	"description": true, "enum": true, "format": true, "nullable": true,
+	"minimum": true, "maximum": true, "minItems": true, "maxItems": true,
+	"minLength": true, "maxLength": true, "pattern": true, "required": true,
+}
+
+// geminiSchema converts a JSON Schema, either a *Schema or decoded JSON, into
+// the subset Gemini accepts: types are upper-cased and unsupported keywords
+// dropped. It reports false for schemas Gemini cannot express, such as
+// objects without properties or values of any type.
+func geminiSchema(schema interface{}) (map[string]interface{}, bool) {
+	if _, ok := schema.(*Schema); ok {
+		data, err := json.Marshal(schema)
+		if err != nil {
+			return nil, false
+		}
+		schema = nil
+		if err := json.Unmarshal(data, &schema); err != nil {
+			return nil, false
+		}
+	}
+
+	source, ok := schema.(map[string]interface{})
+	if !ok {
+		return nil, false
+	}
+	schemaType, ok := source["type"].(string)
+	if !ok {
+		return nil, false
+	}
+
+	converted := map[string]interface{}{"type": strings.ToUpper(schemaType)}
+	for key, value := range source {
+		switch {
+		case key == "properties":
+			properties, ok := value.(map[string]interface{})
+			if !ok {
+				return nil, false
+			}
+			convertedProperties := make(map[string]interface{}, len(properties))
+			for name, property := range properties {
+				if convertedProperties[name], ok = geminiSchema(property); !ok {
+					return nil, false
+				}
+			}
+			converted[key] = convertedProperties
+		case key == "items":
+			if converted[key], ok = geminiSchema(value); !ok {
+				return nil, false
+			}
+		case geminiSchemaKeys[key]:
+			converted[key] = value
+		}
+	}
+
+	if schemaType == "object" {
+		if properties, _ := converted["properties"].(map[string]interface{}); len(properties) == 0 {
+			return nil, false
+		}
+	}
+	return converted, true
+}
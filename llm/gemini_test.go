@@ -0,0 +1,395 @@
+package llm
+
+import (
+	"context"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+
+	toolkit "github.com/soralabs/toolkit/go"
+)
+
+func newTestGeminiProvider(server *fakeServer, config ProviderConfig) *GeminiProvider {
+	config.Type = ProviderGemini
+	config.APIKey = "test-key"
+	config.BaseURL = server.URL
+	return NewGeminiProvider(Config{DefaultProvider: config})
+}
+
+func TestGeminiFunctionCalls(t *testing.T) {
+	server := newFakeServer(t, func(w http.ResponseWriter, req capturedRequest) {
+		writeJSON(w, `{
+			"candidates": [{
+				"content": {"role": "model", "parts": [
+					{"text": "Thinking it over", "thought": true},
+					{"text": "Let me look."},
+					{"functionCall": {"name": "search", "args": {"query": "go"}}},
+					{"functionCall": {"name": "clock"}}
+				]},
+				"finishReason": "STOP"
+			}],
+			"usageMetadata": {"promptTokenCount": 20, "candidatesTokenCount": 8, "thoughtsTokenCount": 4, "totalTokenCount": 32}
+		}`)
+	})
+	provider := newTestGeminiProvider(server, ProviderConfig{})
+
+	msg, err := provider.GenerateCompletion(context.Background(), CompletionRequest{
+		Messages: []Message{
+			NewSystemMessage("Use the tools."),
+			NewUserMessage("Search for go"),
+		},
+		Tools:       []toolkit.Tool{&fakeTool{name: "search"}, &fakeTool{name: "clock", params: `{"type":"object","properties":{}}`}},
+		Temperature: 0.5,
+	})
+	if err != nil {
+		t.Fatalf("GenerateCompletion: %v", err)
+	}
+	want := []ToolCall{
+		{ID: "call_0", Name: "search", Arguments: `{"query": "go"}`},
+		{ID: "call_1", Name: "clock", Arguments: "{}"},
+	}
+	if !reflect.DeepEqual(msg.ToolCalls, want) {
+		t.Errorf("tool calls = %+v, want %+v", msg.ToolCalls, want)
+	}
+	if msg.Content != "Let me look." || msg.Reasoning != "Thinking it over" {
+		t.Errorf("content = %q, reasoning = %q", msg.Content, msg.Reasoning)
+	}
+	if msg.Usage == nil || msg.Usage.PromptTokens != 20 || msg.Usage.CompletionTokens != 12 || msg.Usage.TotalTokens != 32 {
+		t.Errorf("usage = %+v", msg.Usage)
+	}
+
+	req := server.last(t)
+	if req.Path != "/models/gemini-2.0-flash:generateContent" {
+		t.Errorf("path = %s", req.Path)
+	}
+	if got := req.Header.Get("x-goog-api-key"); got != "test-key" {
+		t.Errorf("x-goog-api-key = %q", got)
+	}
+	if got := jsonPath(t, req.Body, "systemInstruction.parts.0.text"); got != "Use the tools." {
+		t.Errorf("system instruction = %v", got)
+	}
+	if got := jsonPath(t, req.Body, "generationConfig.temperature"); got != 0.5 {
+		t.Errorf("temperature = %v", got)
+	}
+	if got := jsonPath(t, req.Body, "tools.0.functionDeclarations.0.parameters.properties.query.type"); got != "STRING" {
+		t.Errorf("search parameters = %v", jsonPath(t, req.Body, "tools.0.functionDeclarations.0"))
+	}
+	if clock := jsonPath(t, req.Body, "tools.0.functionDeclarations.1").(map[string]interface{}); clock["parameters"] != nil {
+		t.Errorf("clock declares parameters: %v", clock)
+	}
+}
+
+func TestGeminiFunctionResponses(t *testing.T) {
+	server := newFakeServer(t, func(w http.ResponseWriter, req capturedRequest) {
+		writeJSON(w, `{"candidates": [{"content": {"role": "model", "parts": [{"text": "Done."}]}}]}`)
+	})
+	provider := newTestGeminiProvider(server, ProviderConfig{})
+
+	assistant := NewAssistantMessage("Looking.")
+	assistant.ToolCalls = []ToolCall{
+		{ID: "call_0", Name: "search", Arguments: `{"query":"go"}`},
+		{ID: "call_1", Name: "clock"},
+		{ID: "call_2", Name: "search", Arguments: `{"query":"rust"}`},
+	}
+	failed := NewToolResultMessage("error: timeout", "search", "call_2")
+	failed.ToolError = true
+	_, err := provider.GenerateCompletion(context.Background(), CompletionRequest{
+		Messages: []Message{
+			NewUserMessage("Search"),
+			assistant,
+			NewToolResultMessage(`{"hits":3}`, "search", "call_0"),
+			NewToolResultMessage("12:00", "clock", "call_1"),
+			failed,
+		},
+	})
+	if err != nil {
+		t.Fatalf("GenerateCompletion: %v", err)
+	}
+
+	// Calls become model function calls and the results a single user turn
+	// of function responses
+	contents := server.last(t).Body["contents"].([]interface{})
+	if len(contents) != 3 {
+		t.Fatalf("contents = %v, want user, model and user turns", contents)
+	}
+	if got := jsonPath(t, contents, "1.role"); got != "model" {
+		t.Errorf("call role = %v", got)
+	}
+	if got := jsonPath(t, contents, "1.parts.0.text"); got != "Looking." {
+		t.Errorf("call text = %v", got)
+	}
+	if got := jsonPath(t, contents, "1.parts.1.functionCall.args.query"); got != "go" {
+		t.Errorf("call args = %v", jsonPath(t, contents, "1.parts.1"))
+	}
+	if got := jsonPath(t, contents, "1.parts.2.functionCall.args"); !reflect.DeepEqual(got, map[string]interface{}{}) {
+		t.Errorf("call without arguments = %v", got)
+	}
+
+	if got := jsonPath(t, contents, "2.role"); got != "user" {
+		t.Errorf("response role = %v", got)
+	}
+	responses := jsonPath(t, contents, "2.parts").([]interface{})
+	wantResponses := []map[string]interface{}{
+		{"name": "search", "response": map[string]interface{}{"hits": float64(3)}},
+		{"name": "clock", "response": map[string]interface{}{"result": "12:00"}},
+		{"name": "search", "response": map[string]interface{}{"error": "error: timeout"}},
+	}
+	if len(responses) != len(wantResponses) {
+		t.Fatalf("function responses = %v", responses)
+	}
+	for i, want := range wantResponses {
+		if got := jsonPath(t, responses[i], "functionResponse"); !reflect.DeepEqual(got, want) {
+			t.Errorf("function response %d = %v, want %v", i, got, want)
+		}
+	}
+}
+
+func TestGeminiSkipsEmptyText(t *testing.T) {
+	server := newFakeServer(t, func(w http.ResponseWriter, req capturedRequest) {
+		writeJSON(w, `{"candidates": [{"content": {"role": "model", "parts": [{"text": "Done."}]}}]}`)
+	})
+	provider := newTestGeminiProvider(server, ProviderConfig{})
+
+	reasoningOnly := NewAssistantMessage("")
+	reasoningOnly.Reasoning = "Nothing to say"
+	callOnly := NewAssistantMessage("")
+	callOnly.ToolCalls = []ToolCall{{ID: "call_0", Name: "search", Arguments: `{"query":"go"}`}}
+	_, err := provider.GenerateCompletion(context.Background(), CompletionRequest{
+		Messages: []Message{
+			NewUserMessage("Hi"),
+			reasoningOnly,
+			NewUserMessage(""),
+			NewUserMessage("Search for go"),
+			callOnly,
+			NewToolResultMessage(`{"hits":3}`, "search", "call_0"),
+		},
+	})
+	if err != nil {
+		t.Fatalf("GenerateCompletion: %v", err)
+	}
+
+	// The messages without text are dropped, so both user texts share a turn
+	contents := server.last(t).Body["contents"].([]interface{})
+	if len(contents) != 3 {
+		t.Fatalf("contents = %v, want user, model and user turns", contents)
+	}
+	for i, content := range contents {
+		for j, part := range jsonPath(t, content, "parts").([]interface{}) {
+			if fields := part.(map[string]interface{}); len(fields) == 0 || fields["text"] == "" {
+				t.Errorf("content %d part %d is empty: %v", i, j, part)
+			}
+		}
+	}
+	if got := jsonPath(t, contents, "0.parts.1.text"); got != "Search for go" {
+		t.Errorf("second user text = %v", got)
+	}
+	if parts := jsonPath(t, contents, "1.parts").([]interface{}); len(parts) != 1 || jsonPath(t, parts, "0.functionCall.name") != "search" {
+		t.Errorf("call parts = %v, want only the function call", parts)
+	}
+}
+
+func TestGeminiStructuredOutput(t *testing.T) {
+	server := newFakeServer(t, func(w http.ResponseWriter, req capturedRequest) {
+		writeJSON(w, `{
+			"candidates": [{"content": {"parts": [{"text": "{\"mood\": \"happy\", \"score\": 7}"}]}}],
+			"usageMetadata": {"promptTokenCount": 9, "candidatesTokenCount": 5, "totalTokenCount": 14}
+		}`)
+	})
+	provider := newTestGeminiProvider(server, ProviderConfig{})
+
+	var result struct {
+		Mood  string `json:"mood" jsonschema:"enum=happy,enum=sad"`
+		Score int    `json:"score"`
+	}
+	usage, err := provider.GenerateStructuredOutput(context.Background(), StructuredOutputRequest{
+		Messages:  []Message{NewUserMessage("How do I feel?")},
+		ModelType: ModelTypeAdvanced,
+	}, &result)
+	if err != nil {
+		t.Fatalf("GenerateStructuredOutput: %v", err)
+	}
+	if result.Mood != "happy" || result.Score != 7 {
+		t.Errorf("result = %+v", result)
+	}
+	if usage.Model != "gemini-2.5-pro" || usage.TotalTokens != 14 {
+		t.Errorf("usage = %+v", usage)
+	}
+
+	req := server.last(t)
+	if req.Path != "/models/gemini-2.5-pro:generateContent" {
+		t.Errorf("path = %s", req.Path)
+	}
+	if got := jsonPath(t, req.Body, "generationConfig.responseMimeType"); got != "application/json" {
+		t.Errorf("response mime type = %v", got)
+	}
+	schema := jsonPath(t, req.Body, "generationConfig.responseSchema")
+	if jsonPath(t, schema, "type") != "OBJECT" || jsonPath(t, schema, "properties.score.type") != "INTEGER" {
+		t.Errorf("response schema = %v", schema)
+	}
+	if got := jsonPath(t, schema, "properties.mood.enum"); !reflect.DeepEqual(got, []interface{}{"happy", "sad"}) {
+		t.Errorf("mood enum = %v", got)
+	}
+	if _, ok := schema.(map[string]interface{})["additionalProperties"]; ok {
+		t.Errorf("response schema keeps additionalProperties: %v", schema)
+	}
+	if req.Body["systemInstruction"] != nil {
+		t.Errorf("system instruction = %v, want none", req.Body["systemInstruction"])
+	}
+}
+
+func TestGeminiStructuredOutputSchemaFallback(t *testing.T) {
+	server := newFakeServer(t, func(w http.ResponseWriter, req capturedRequest) {
+		writeJSON(w, `{"candidates": [{"content": {"parts": [{"text": "{\"labels\": {\"go\": \"language\"}}"}]}}]}`)
+	})
+	provider := newTestGeminiProvider(server, ProviderConfig{})
+
+	// Maps have no properties, which Gemini's schema cannot express
+	var result struct {
+		Labels map[string]string `json:"labels"`
+	}
+	_, err := provider.GenerateStructuredOutput(context.Background(), StructuredOutputRequest{
+		Messages:   []Message{NewSystemMessage("Label the words."), NewUserMessage("go")},
+		SchemaName: "labels",
+	}, &result)
+	if err != nil {
+		t.Fatalf("GenerateStructuredOutput: %v", err)
+	}
+	if result.Labels["go"] != "language" {
+		t.Errorf("result = %+v", result)
+	}
+
+	req := server.last(t)
+	if got := jsonPath(t, req.Body, "generationConfig"); jsonPath(t, got, "responseMimeType") != "application/json" || got.(map[string]interface{})["responseSchema"] != nil {
+		t.Errorf("generation config = %v, want JSON without a response schema", got)
+	}
+	system := jsonPath(t, req.Body, "systemInstruction.parts.0.text").(string)
+	if !strings.HasPrefix(system, "Respond with a single JSON object named labels") || !strings.Contains(system, `"labels"`) || !strings.HasSuffix(system, "\n\nLabel the words.") {
+		t.Errorf("system instruction = %q", system)
+	}
+}
+
+func TestGeminiStream(t *testing.T) {
+	server := newFakeServer(t, func(w http.ResponseWriter, req capturedRequest) {
+		writeSSE(w,
+			`{"candidates":[{"content":{"role":"model","parts":[{"text":"Hel"}]}}]}`,
+			`{"candidates":[{"content":{"role":"model","parts":[{"text":"lo"},{"functionCall":{"id":"fc_1","name":"search","args":{"query":"go"}}}]}}]}`,
+			`{"candidates":[{"content":{"role":"model","parts":[{"text":""}]},"finishReason":"STOP"}],"usageMetadata":{"promptTokenCount":6,"candidatesTokenCount":3,"totalTokenCount":9}}`,
+		)
+	})
+	provider := newTestGeminiProvider(server, ProviderConfig{})
+
+	events, err := provider.GenerateCompletionStream(context.Background(), CompletionRequest{
+		Messages:  []Message{NewUserMessage("Hi")},
+		ModelType: ModelTypeFast,
+	})
+	if err != nil {
+		t.Fatalf("GenerateCompletionStream: %v", err)
+	}
+	content, final, err := collectStream(t, events)
+	if err != nil {
+		t.Fatalf("stream error: %v", err)
+	}
+	if content != "Hello" || final == nil || final.Content != "Hello" {
+		t.Errorf("content = %q, final = %+v", content, final)
+	}
+	want := []ToolCall{{ID: "fc_1", Name: "search", Arguments: `{"query":"go"}`}}
+	if !reflect.DeepEqual(final.ToolCalls, want) {
+		t.Errorf("tool calls = %+v, want %+v", final.ToolCalls, want)
+	}
+	if final.Usage == nil || final.Usage.Model != "gemini-2.0-flash-lite" || final.Usage.TotalTokens != 9 {
+		t.Errorf("usage = %+v", final.Usage)
+	}
+
+	req := server.last(t)
+	if req.Path != "/models/gemini-2.0-flash-lite:streamGenerateContent" || req.Query != "alt=sse" {
+		t.Errorf("request = %s?%s", req.Path, req.Query)
+	}
+}
+
+func TestGeminiEmbedTexts(t *testing.T) {
+	server := newFakeServer(t, func(w http.ResponseWriter, req capturedRequest) {
+		requests := req.Body["requests"].([]interface{})
+		embeddings := make([]string, len(requests))
+		for i, request := range requests {
+			// Embed each text as one value per character to check the order
+			text := jsonPath(t, request, "content.parts.0.text").(string)
+			embeddings[i] = `{"values": [` + strings.Repeat("1,", len(text)-1) + `1]}`
+		}
+		writeJSON(w, `{"embeddings": [`+strings.Join(embeddings, ",")+`]}`)
+	})
+	provider := newTestGeminiProvider(server, ProviderConfig{EmbeddingBatchSize: 2, EmbeddingDimensions: 256})
+
+	embeddings, usage, err := provider.EmbedTexts(context.Background(), []string{"a", "bb", "ccc"})
+	if err != nil {
+		t.Fatalf("EmbedTexts: %v", err)
+	}
+	for i, embedding := range embeddings {
+		if len(embedding) != i+1 {
+			t.Errorf("embedding %d = %v, want %d values", i, embedding, i+1)
+		}
+	}
+	if usage.Provider != ProviderGemini || usage.Model != geminiDefaultEmbeddingModel {
+		t.Errorf("usage = %+v", usage)
+	}
+
+	if server.count() != 2 {
+		t.Fatalf("requests = %d, want 2 batches", server.count())
+	}
+	req := server.last(t)
+	if req.Path != "/models/text-embedding-004:batchEmbedContents" {
+		t.Errorf("path = %s", req.Path)
+	}
+	if got := jsonPath(t, req.Body, "requests.0.model"); got != "models/text-embedding-004" {
+		t.Errorf("model = %v", got)
+	}
+	if got := jsonPath(t, req.Body, "requests.0.outputDimensionality"); got != float64(256) {
+		t.Errorf("output dimensionality = %v", got)
+	}
+}
+
+func TestGeminiNoCandidates(t *testing.T) {
+	tests := []struct {
+		name     string
+		response string
+		want     string
+	}{
+		{"blocked", `{"candidates": [], "promptFeedback": {"blockReason": "SAFETY"}}`, "prompt blocked: SAFETY"},
+		{"empty", `{"usageMetadata": {"promptTokenCount": 3}}`, "no completion returned"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := newFakeServer(t, func(w http.ResponseWriter, req capturedRequest) {
+				writeJSON(w, tt.response)
+			})
+			provider := newTestGeminiProvider(server, ProviderConfig{})
+
+			_, err := provider.GenerateCompletion(context.Background(), CompletionRequest{Messages: []Message{NewUserMessage("Hi")}})
+			if err == nil || err.Error() != tt.want {
+				t.Errorf("GenerateCompletion error = %v, want %q", err, tt.want)
+			}
+
+			var result struct {
+				Answer string `json:"answer"`
+			}
+			_, err = provider.GenerateStructuredOutput(context.Background(), StructuredOutputRequest{Messages: []Message{NewUserMessage("Hi")}}, &result)
+			if err == nil || !strings.HasSuffix(err.Error(), tt.want) {
+				t.Errorf("GenerateStructuredOutput error = %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestGeminiStatusError(t *testing.T) {
+	server := newFakeServer(t, func(w http.ResponseWriter, req capturedRequest) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusTooManyRequests)
+		w.Write([]byte(`{"error": {"code": 429, "status": "RESOURCE_EXHAUSTED"}}`))
+	})
+	provider := newTestGeminiProvider(server, ProviderConfig{})
+
+	_, err := provider.GenerateCompletionStream(context.Background(), CompletionRequest{Messages: []Message{NewUserMessage("Hi")}})
+	if ClassifyError(err) != ErrorKindRateLimit {
+		t.Errorf("error = %v, want a rate limit error", err)
+	}
+}
@@ -0,0 +1,347 @@
+package llm
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+)
+
+// ggufMagic starts every GGUF file ("GGUF" in little-endian byte order)
+const ggufMagic = 0x46554747
+
+// ggufDefaultAlignment aligns tensor data unless general.alignment is set
+const ggufDefaultAlignment = 32
+
+// GGUF metadata value types
+const (
+	ggufTypeUint8   = 0
+	ggufTypeInt8    = 1
+	ggufTypeUint16  = 2
+	ggufTypeInt16   = 3
+	ggufTypeUint32  = 4
+	ggufTypeInt32   = 5
+	ggufTypeFloat32 = 6
+	ggufTypeBool    = 7
+	ggufTypeString  = 8
+	ggufTypeArray   = 9
+	ggufTypeUint64  = 10
+	ggufTypeInt64   = 11
+	ggufTypeFloat64 = 12
+)
+
+// ggml tensor types supported by readTensor
+const (
+	ggmlTypeF32  = 0
+	ggmlTypeF16  = 1
+	ggmlTypeQ8_0 = 8
+	ggmlTypeBF16 = 30
+)
+
+// ggmlQ8Block is the number of weights of a Q8_0 block
+const ggmlQ8Block = 32
+
+// ggufFile is a parsed GGUF model file (version 2 or 3)
+type ggufFile struct {
+	metadata map[string]interface{}
+	tensors  map[string]ggufTensor
+	data     []byte // Tensor data section
+}
+
+// ggufTensor describes a tensor. dims[0] is the innermost dimension, so a
+// matrix of dims [n, m] has m rows of n values.
+type ggufTensor struct {
+	dims   []uint64
+	typ    uint32
+	offset uint64
+}
+
+// ggufReader decodes little-endian GGUF values from a byte slice
+type ggufReader struct {
+	buf []byte
+	pos int
+	err error
+}
+
+var errGGUFTruncated = errors.New("unexpected end of file")
+
+func (r *ggufReader) next(n int) []byte {
+	if r.err != nil {
+		return nil
+	}
+	if n < 0 || r.pos+n > len(r.buf) {
+		r.err = errGGUFTruncated
+		return nil
+	}
+	b := r.buf[r.pos : r.pos+n]
+	r.pos += n
+	return b
+}
+
+func (r *ggufReader) uint32() uint32 {
+	if b := r.next(4); b != nil {
+		return binary.LittleEndian.Uint32(b)
+	}
+	return 0
+}
+
+func (r *ggufReader) uint64() uint64 {
+	if b := r.next(8); b != nil {
+		return binary.LittleEndian.Uint64(b)
+	}
+	return 0
+}
+
+func (r *ggufReader) string() string {
+	n := r.uint64()
+	if n > uint64(len(r.buf)) {
+		r.err = errGGUFTruncated
+		return ""
+	}
+	return string(r.next(int(n)))
+}
+
+// value reads a metadata value of the given type. Arrays of strings are
+// returned as []string, other arrays as []interface{}.
+func (r *ggufReader) value(typ uint32) interface{} {
+	switch typ {
+	case ggufTypeUint8:
+		if b := r.next(1); b != nil {
+			return b[0]
+		}
+	case ggufTypeInt8:
+		if b := r.next(1); b != nil {
+			return int8(b[0])
+		}
+	case ggufTypeUint16:
+		if b := r.next(2); b != nil {
+			return binary.LittleEndian.Uint16(b)
+		}
+	case ggufTypeInt16:
+		if b := r.next(2); b != nil {
+			return int16(binary.LittleEndian.Uint16(b))
+		}
+	case ggufTypeUint32:
+		return r.uint32()
+	case ggufTypeInt32:
+		return int32(r.uint32())
+	case ggufTypeFloat32:
+		return math.Float32frombits(r.uint32())
+	case ggufTypeBool:
+		if b := r.next(1); b != nil {
+			return b[0] != 0
+		}
+	case ggufTypeString:
+		return r.string()
+	case ggufTypeArray:
+		elemType := r.uint32()
+		n := r.uint64()
+		if n > uint64(len(r.buf)) {
+			r.err = errGGUFTruncated
+			return nil
+		}
+		if elemType == ggufTypeString {
+			values := make([]string, n)
+			for i := range values {
+				values[i] = r.string()
+			}
+			return values
+		}
+		values := make([]interface{}, n)
+		for i := range values {
+			values[i] = r.value(elemType)
+		}
+		return values
+	case ggufTypeUint64:
+		return r.uint64()
+	case ggufTypeInt64:
+		return int64(r.uint64())
+	case ggufTypeFloat64:
+		return math.Float64frombits(r.uint64())
+	default:
+		r.err = fmt.Errorf("unknown metadata value type %d", typ)
+	}
+	return nil
+}
+
+// readGGUF loads a GGUF file into memory and parses its header
+func readGGUF(path string) (*ggufFile, error) {
+	buf, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return parseGGUF(buf)
+}
+
+func parseGGUF(buf []byte) (*ggufFile, error) {
+	r := &ggufReader{buf: buf}
+	if magic := r.uint32(); r.err == nil && magic != ggufMagic {
+		return nil, fmt.Errorf("not a GGUF file")
+	}
+	if version := r.uint32(); r.err == nil && version != 2 && version != 3 {
+		return nil, fmt.Errorf("unsupported GGUF version %d", version)
+	}
+	tensorCount := r.uint64()
+	metadataCount := r.uint64()
+
+	file := &ggufFile{
+		metadata: make(map[string]interface{}),
+		tensors:  make(map[string]ggufTensor),
+	}
+	for i := uint64(0); i < metadataCount && r.err == nil; i++ {
+		key := r.string()
+		file.metadata[key] = r.value(r.uint32())
+	}
+	for i := uint64(0); i < tensorCount && r.err == nil; i++ {
+		name := r.string()
+		dims := make([]uint64, r.uint32())
+		if len(dims) > 4 {
+			return nil, fmt.Errorf("tensor %s has %d dimensions", name, len(dims))
+		}
+		for j := range dims {
+			dims[j] = r.uint64()
+		}
+		file.tensors[name] = ggufTensor{dims: dims, typ: r.uint32(), offset: r.uint64()}
+	}
+	if r.err != nil {
+		return nil, fmt.Errorf("failed to read GGUF header: %w", r.err)
+	}
+
+	alignment := ggufDefaultAlignment
+	if value, ok := file.metaInt("general.alignment"); ok && value > 0 {
+		alignment = value
+	}
+	start := (r.pos + alignment - 1) / alignment * alignment
+	if start > len(buf) {
+		return nil, fmt.Errorf("failed to read GGUF header: %w", errGGUFTruncated)
+	}
+	file.data = buf[start:]
+	return file, nil
+}
+
+// metaInt returns an integer metadata value of any integer type
+func (f *ggufFile) metaInt(key string) (int, bool) {
+	switch value := f.metadata[key].(type) {
+	case uint8:
+		return int(value), true
+	case int8:
+		return int(value), true
+	case uint16:
+		return int(value), true
+	case int16:
+		return int(value), true
+	case uint32:
+		return int(value), true
+	case int32:
+		return int(value), true
+	case uint64:
+		return int(value), true
+	case int64:
+		return int(value), true
+	}
+	return 0, false
+}
+
+// metaFloat returns a floating-point metadata value
+func (f *ggufFile) metaFloat(key string) (float64, bool) {
+	switch value := f.metadata[key].(type) {
+	case float32:
+		return float64(value), true
+	case float64:
+		return value, true
+	}
+	return 0, false
+}
+
+// metaString returns a string metadata value
+func (f *ggufFile) metaString(key string) string {
+	value, _ := f.metadata[key].(string)
+	return value
+}
+
+// readTensor dequantizes a tensor to float32 and checks it has the expected
+// dimensions, innermost first
+func (f *ggufFile) readTensor(name string, dims ...int) ([]float32, error) {
+	tensor, ok := f.tensors[name]
+	if !ok {
+		return nil, fmt.Errorf("missing tensor %s", name)
+	}
+	if len(tensor.dims) < len(dims) {
+		return nil, fmt.Errorf("tensor %s has shape %v, expected %v", name, tensor.dims, dims)
+	}
+	n := 1
+	for i, dim := range tensor.dims {
+		if i < len(dims) && int(dim) != dims[i] || i >= len(dims) && dim != 1 {
+			return nil, fmt.Errorf("tensor %s has shape %v, expected %v", name, tensor.dims, dims)
+		}
+		n *= int(dim)
+	}
+
+	var size int
+	switch tensor.typ {
+	case ggmlTypeF32:
+		size = 4 * n
+	case ggmlTypeF16, ggmlTypeBF16:
+		size = 2 * n
+	case ggmlTypeQ8_0:
+		if n%ggmlQ8Block != 0 {
+			return nil, fmt.Errorf("tensor %s: %d values do not fill Q8_0 blocks", name, n)
+		}
+		size = n / ggmlQ8Block * (2 + ggmlQ8Block)
+	default:
+		return nil, fmt.Errorf("tensor %s has unsupported type %d, use an F32, F16, BF16 or Q8_0 model", name, tensor.typ)
+	}
+	if tensor.offset > uint64(len(f.data)) || uint64(size) > uint64(len(f.data))-tensor.offset {
+		return nil, fmt.Errorf("tensor %s: %w", name, errGGUFTruncated)
+	}
+	data := f.data[tensor.offset : tensor.offset+uint64(size)]
+
+	values := make([]float32, n)
+	switch tensor.typ {
+	case ggmlTypeF32:
+		for i := range values {
+			values[i] = math.Float32frombits(binary.LittleEndian.Uint32(data[4*i:]))
+		}
+	case ggmlTypeF16:
+		for i := range values {
+			values[i] = float16ToFloat32(binary.LittleEndian.Uint16(data[2*i:]))
+		}
+	case ggmlTypeBF16:
+		for i := range values {
+			values[i] = math.Float32frombits(uint32(binary.LittleEndian.Uint16(data[2*i:])) << 16)
+		}
+	case ggmlTypeQ8_0:
+		for block := 0; block < n/ggmlQ8Block; block++ {
+			b := data[block*(2+ggmlQ8Block):]
+			scale := float16ToFloat32(binary.LittleEndian.Uint16(b))
+			for i := 0; i < ggmlQ8Block; i++ {
+				values[block*ggmlQ8Block+i] = scale * float32(int8(b[2+i]))
+			}
+		}
+	}
+	return values, nil
+}
+
+// float16ToFloat32 converts an IEEE 754 half-precision value
+func float16ToFloat32(h uint16) float32 {
+	sign := uint32(h>>15) << 31
+	exponent := uint32(h>>10) & 0x1f
+	mantissa := uint32(h) & 0x3ff
+
+	switch {
+	case exponent == 0 && mantissa == 0:
+		return math.Float32frombits(sign)
+	case exponent == 0:
+		// Subnormal: normalize the mantissa
+		for mantissa&0x400 == 0 {
+			mantissa <<= 1
+			exponent--
+		}
+		exponent++
+		mantissa &= 0x3ff
+	case exponent == 0x1f:
+		return math.Float32frombits(sign | 0xff<<23 | mantissa<<13)
+	}
+	return math.Float32frombits(sign | (exponent+127-15)<<23 | mantissa<<13)
+}
@@ -0,0 +1,119 @@
+package llm
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"math"
+	"strings"
+	"unicode"
+)
+
+// defaultHashingDimensions is the vector size of HashingProvider unless
+// ProviderConfig.EmbeddingDimensions is set
+const defaultHashingDimensions = 384
+
+// embeddingOnlyProvider implements the chat methods of providers that can only embed
+type embeddingOnlyProvider struct {
+	providerType ProviderType
+}
+
+func (p embeddingOnlyProvider) Capabilities() Capabilities {
+	return Capabilities{Embeddings: true}
+}
+
+func (p embeddingOnlyProvider) GenerateCompletion(ctx context.Context, req CompletionRequest) (Message, error) {
+	return Message{}, fmt.Errorf("completions not supported by %s provider", p.providerType)
+}
+
+func (p embeddingOnlyProvider) GenerateCompletionStream(ctx context.Context, req CompletionRequest) (<-chan StreamEvent, error) {
+	return nil, fmt.Errorf("completions not supported by %s provider", p.providerType)
+}
+
+func (p embeddingOnlyProvider) GenerateStructuredOutput(ctx context.Context, req StructuredOutputRequest, result interface{}) (Usage, error) {
+	return Usage{}, fmt.Errorf("structured output not supported by %s provider", p.providerType)
+}
+
+// HashingProvider is a deterministic, dependency-free embedder based on
+// feature hashing of words. Its vectors only capture word overlap, which makes
+// it suitable for tests and offline development rather than semantic search.
+type HashingProvider struct {
+	embeddingOnlyProvider
+	dimensions int
+	model      string
+}
+
+// NewHashingProvider creates a HashingProvider producing vectors of
+// EmbeddingDimensions (384 by default)
+func NewHashingProvider(config Config) *HashingProvider {
+	return &HashingProvider{
+		embeddingOnlyProvider: embeddingOnlyProvider{providerType: ProviderHashing},
+		dimensions:            hashingDimensions(config.DefaultProvider),
+		model:                 embeddingModelName(config.DefaultProvider),
+	}
+}
+
+// EmbedText hashes the words of the text into a normalized vector
+func (p *HashingProvider) EmbedText(ctx context.Context, text string) ([]float32, Usage, error) {
+	return firstEmbedding(p.EmbedTexts(ctx, []string{text}))
+}
+
+// EmbedTexts hashes the words of every text into a normalized vector
+func (p *HashingProvider) EmbedTexts(ctx context.Context, texts []string) ([][]float32, Usage, error) {
+	usage := Usage{Provider: ProviderHashing, Model: p.model}
+	embeddings := make([][]float32, len(texts))
+	for i, text := range texts {
+		words := tokenizeWords(text)
+		usage.PromptTokens += len(words)
+
+		embedding := make([]float32, p.dimensions)
+		for _, word := range words {
+			hash := fnv.New64a()
+			hash.Write([]byte(word))
+			sum := hash.Sum64()
+
+			// The top bit picks the sign so collisions tend to cancel out
+			sign := float32(1)
+			if sum>>63 == 1 {
+				sign = -1
+			}
+			embedding[sum%uint64(p.dimensions)] += sign
+		}
+		embeddings[i] = normalize(embedding)
+	}
+	usage.TotalTokens = usage.PromptTokens
+
+	return embeddings, usage, nil
+}
+
+// hashingDimensions returns the configured vector size of a hashing provider
+func hashingDimensions(config ProviderConfig) int {
+	if config.EmbeddingDimensions > 0 {
+		return config.EmbeddingDimensions
+	}
+	return defaultHashingDimensions
+}
+
+// tokenizeWords lowercases text and splits it into words of letters and digits
+func tokenizeWords(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	})
+}
+
+// normalize scales a vector to unit length, leaving zero vectors unchanged
+func normalize(vector []float32) []float32 {
+	var sum float64
+	for _, value := range vector {
+		sum += float64(value) * float64(value)
+	}
+	if sum == 0 {
+		return vector
+	}
+
+	norm := float32(math.Sqrt(sum))
+	for i := range vector {
+		vector[i] /= norm
+	}
+	return vector
+}
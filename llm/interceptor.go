@@ -0,0 +1,146 @@
+package llm
+
+import (
+	"context"
+	"fmt"
+	"slices"
+)
+
+// Call describes a single model call made by LLMClient. Interceptors may
+// modify the request before passing the call on.
+type Call struct {
+	Operation        Operation
+	Scope            UsageScope               // Scope of the client making the call
+	Stream           bool                     // Set for completions whose response is streamed
+	Completion       *CompletionRequest       // Set for completions
+	StructuredOutput *StructuredOutputRequest // Set for structured output
+	Result           interface{}              // Target of structured output
+	Texts            []string                 // Inputs of embeddings
+}
+
+// CallResult holds the response of a model call. Only the fields of the
+// call's operation are set.
+type CallResult struct {
+	Message    *Message           // Completion response, carrying its usage
+	Stream     <-chan StreamEvent // Events of a streamed completion
+	Embeddings [][]float32
+	Usage      Usage // Usage of structured output and embeddings
+}
+
+// Invoker performs a model call
+type Invoker func(ctx context.Context, call *Call) (*CallResult, error)
+
+// Interceptor wraps model calls, e.g. for logging, redaction, metrics or
+// prompt capture. It continues the call by calling next and may modify the
+// call before and the result after it. Streamed results can be observed by
+// replacing CallResult.Stream with a channel that forwards its events.
+type Interceptor func(ctx context.Context, call *Call, next Invoker) (*CallResult, error)
+
+// Use registers interceptors around every model call of the client: each
+// tool-calling round, structured output attempt and embedding request that
+// reaches a provider. The first interceptor runs outermost. Use must not be
+// called while the client is in use; scoped clients keep the interceptors
+// registered when they were created.
+func (c *LLMClient) Use(interceptors ...Interceptor) {
+	c.interceptors = append(slices.Clip(c.interceptors), interceptors...)
+}
+
+// intercept runs a call through the interceptors and then invoke
+func (c *LLMClient) intercept(ctx context.Context, call *Call, invoke Invoker) (*CallResult, error) {
+	call.Scope = c.scope
+	for i := len(c.interceptors) - 1; i >= 0; i-- {
+		interceptor, next := c.interceptors[i], invoke
+		invoke = func(ctx context.Context, call *Call) (*CallResult, error) {
+			return interceptor(ctx, call, next)
+		}
+	}
+
+	result, err := invoke(ctx, call)
+	if result == nil {
+		result = &CallResult{}
+	}
+	return result, err
+}
+
+// complete generates a single completion with the chat providers
+func (c *LLMClient) complete(ctx context.Context, req CompletionRequest) (Message, error) {
+	call := &Call{Operation: OperationCompletion, Completion: &req}
+	result, err := c.intercept(ctx, call, func(ctx context.Context, call *Call) (*CallResult, error) {
+		providers, err := c.chatProvidersFor(completionRoute(call.Scope, *call.Completion), completionFeatures(*call.Completion, false))
+		if err != nil {
+			return nil, err
+		}
+		var message Message
+		err = c.withFallback(ctx, providers, func(provider Provider) error {
+			var err error
+			message, err = provider.GenerateCompletion(ctx, *call.Completion)
+			return err
+		})
+		return &CallResult{Message: &message}, err
+	})
+	if result.Message == nil {
+		return Message{}, err
+	}
+	return *result.Message, err
+}
+
+// openStream opens a provider stream, retrying and falling back while no
+// events have been delivered yet. Failures mid-stream are not retried.
+func (c *LLMClient) openStream(ctx context.Context, req CompletionRequest) (<-chan StreamEvent, error) {
+	call := &Call{Operation: OperationCompletion, Stream: true, Completion: &req}
+	result, err := c.intercept(ctx, call, func(ctx context.Context, call *Call) (*CallResult, error) {
+		providers, err := c.chatProvidersFor(completionRoute(call.Scope, *call.Completion), completionFeatures(*call.Completion, true))
+		if err != nil {
+			return nil, err
+		}
+		var stream <-chan StreamEvent
+		err = c.withFallback(ctx, providers, func(provider Provider) error {
+			var err error
+			stream, err = provider.GenerateCompletionStream(ctx, *call.Completion)
+			return err
+		})
+		return &CallResult{Stream: stream}, err
+	})
+	if err == nil && result.Stream == nil {
+		return nil, fmt.Errorf("interceptor returned no stream")
+	}
+	return result.Stream, err
+}
+
+// generateStructuredOutput makes a single structured output attempt with the chat providers
+func (c *LLMClient) generateStructuredOutput(ctx context.Context, req StructuredOutputRequest, result interface{}) (Usage, error) {
+	call := &Call{Operation: OperationStructuredOutput, StructuredOutput: &req, Result: result}
+	callResult, err := c.intercept(ctx, call, func(ctx context.Context, call *Call) (*CallResult, error) {
+		providers, err := c.chatProvidersFor(structuredOutputRoute(call.Scope, *call.StructuredOutput), structuredOutputFeatures(*call.StructuredOutput))
+		if err != nil {
+			return nil, err
+		}
+		var usage Usage
+		err = c.withFallback(ctx, providers, func(provider Provider) error {
+			var err error
+			usage, err = provider.GenerateStructuredOutput(ctx, *call.StructuredOutput, call.Result)
+			return err
+		})
+		return &CallResult{Usage: usage}, err
+	})
+	return callResult.Usage, err
+}
+
+// embed embeds texts with the embedding providers
+func (c *LLMClient) embed(ctx context.Context, texts []string) ([][]float32, Usage, error) {
+	call := &Call{Operation: OperationEmbedding, Texts: texts}
+	result, err := c.intercept(ctx, call, func(ctx context.Context, call *Call) (*CallResult, error) {
+		var embeddings [][]float32
+		var usage Usage
+		err := c.withFallback(ctx, c.embeddingProviders, func(provider Provider) error {
+			var err error
+			embeddings, usage, err = provider.EmbedTexts(ctx, call.Texts)
+			return err
+		})
+		return &CallResult{Embeddings: embeddings, Usage: usage}, err
+	})
+	if err == nil && len(result.Embeddings) != len(texts) {
+		return nil, result.Usage, fmt.Errorf("expected %d embeddings, got %d", len(texts), len(result.Embeddings))
+	}
+	return result.Embeddings, result.Usage, err
+}
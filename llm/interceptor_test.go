@@ -0,0 +1,174 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// echoProvider answers completions with the content of the last message
+func echoProvider(calls *int) *funcProvider {
+	return &funcProvider{
+		caps: allCapabilities,
+		complete: func(ctx context.Context, req CompletionRequest) (Message, error) {
+			*calls++
+			return NewAssistantMessage(req.Messages[len(req.Messages)-1].Content), nil
+		},
+		stream: func(ctx context.Context, req CompletionRequest) (<-chan StreamEvent, error) {
+			*calls++
+			content := req.Messages[len(req.Messages)-1].Content
+			return streamOf(
+				StreamEvent{Type: StreamEventContent, Content: content},
+				StreamEvent{Type: StreamEventDone, Message: &Message{Role: RoleAssistant, Content: content}},
+			), nil
+		},
+	}
+}
+
+func TestInterceptorOrder(t *testing.T) {
+	calls := 0
+	client := newTestClient(echoProvider(&calls))
+	var log []string
+	tag := func(name string) Interceptor {
+		return func(ctx context.Context, call *Call, next Invoker) (*CallResult, error) {
+			log = append(log, name+" before")
+			req := *call.Completion
+			req.Messages = append(slices.Clip(req.Messages), NewUserMessage(req.Messages[len(req.Messages)-1].Content+" "+name))
+			call.Completion = &req
+			result, err := next(ctx, call)
+			log = append(log, name+" after")
+			if result.Message != nil {
+				result.Message.Content += " " + name
+			}
+			return result, err
+		}
+	}
+	client.Use(tag("outer"))
+	client.Use(tag("inner"))
+
+	msg, err := client.GenerateCompletion(CompletionRequest{Messages: []Message{NewUserMessage("hi")}})
+	if err != nil {
+		t.Fatalf("GenerateCompletion: %v", err)
+	}
+	// Requests are modified outside in, results inside out
+	if msg.Content != "hi outer inner inner outer" {
+		t.Errorf("content = %q", msg.Content)
+	}
+	if want := []string{"outer before", "inner before", "inner after", "outer after"}; !slices.Equal(log, want) {
+		t.Errorf("log = %v, want %v", log, want)
+	}
+}
+
+func TestInterceptorScope(t *testing.T) {
+	calls := 0
+	client := newTestClient(echoProvider(&calls))
+	var got []UsageScope
+	client.Use(func(ctx context.Context, call *Call, next Invoker) (*CallResult, error) {
+		got = append(got, call.Scope)
+		return next(ctx, call)
+	})
+
+	scope := UsageScope{Manager: "insight"}
+	if _, err := client.WithScope(scope).GenerateCompletion(CompletionRequest{Messages: []Message{NewUserMessage("hi")}}); err != nil {
+		t.Fatalf("GenerateCompletion: %v", err)
+	}
+	if len(got) != 1 || got[0] != scope {
+		t.Errorf("scopes = %+v, want %+v", got, scope)
+	}
+}
+
+func TestInterceptorShortCircuit(t *testing.T) {
+	denied := errors.New("denied")
+	tests := []struct {
+		name    string
+		result  *CallResult
+		err     error
+		want    string
+		wantErr error
+	}{
+		{"cached response", &CallResult{Message: &Message{Role: RoleAssistant, Content: "cached"}}, nil, "cached", nil},
+		{"rejected call", nil, denied, "", denied},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calls := 0
+			client := newTestClient(echoProvider(&calls))
+			inner := false
+			client.Use(
+				func(ctx context.Context, call *Call, next Invoker) (*CallResult, error) {
+					return tt.result, tt.err
+				},
+				func(ctx context.Context, call *Call, next Invoker) (*CallResult, error) {
+					inner = true
+					return next(ctx, call)
+				},
+			)
+
+			msg, err := client.GenerateCompletion(CompletionRequest{Messages: []Message{NewUserMessage("hi")}})
+			if !errors.Is(err, tt.wantErr) || msg.Content != tt.want {
+				t.Errorf("GenerateCompletion = %q, %v, want %q, %v", msg.Content, err, tt.want, tt.wantErr)
+			}
+			if calls != 0 || inner {
+				t.Errorf("provider calls = %d, inner interceptor ran = %v, want neither", calls, inner)
+			}
+		})
+	}
+}
+
+func TestInterceptorStream(t *testing.T) {
+	calls := 0
+	client := newTestClient(echoProvider(&calls))
+	var observed []string
+	client.Use(func(ctx context.Context, call *Call, next Invoker) (*CallResult, error) {
+		if !call.Stream || call.Operation != OperationCompletion {
+			t.Errorf("call = %+v, want a streamed completion", call)
+		}
+		result, err := next(ctx, call)
+		if err != nil {
+			return result, err
+		}
+		// Observe the events by forwarding them
+		events := make(chan StreamEvent)
+		go func(stream <-chan StreamEvent) {
+			defer close(events)
+			for event := range stream {
+				observed = append(observed, string(event.Type))
+				if event.Type == StreamEventContent {
+					event.Content = strings.ToUpper(event.Content)
+				}
+				events <- event
+			}
+		}(result.Stream)
+		result.Stream = events
+		return result, nil
+	})
+
+	events, err := client.GenerateCompletionStream(CompletionRequest{Messages: []Message{NewUserMessage("hi")}})
+	if err != nil {
+		t.Fatalf("GenerateCompletionStream: %v", err)
+	}
+	content, final, err := collectStream(t, events)
+	if err != nil || content != "HI" || final == nil || final.Content != "hi" {
+		t.Errorf("stream = %q, %+v, %v", content, final, err)
+	}
+	if want := []string{"content", "done"}; !slices.Equal(observed, want) {
+		t.Errorf("observed = %v, want %v", observed, want)
+	}
+}
+
+func TestInterceptorWithoutStream(t *testing.T) {
+	calls := 0
+	client := newTestClient(echoProvider(&calls))
+	client.Use(func(ctx context.Context, call *Call, next Invoker) (*CallResult, error) {
+		return &CallResult{}, nil
+	})
+
+	if _, err := client.GenerateCompletionStream(CompletionRequest{Messages: []Message{NewUserMessage("hi")}}); err == nil {
+		t.Error("expected an error for an interceptor dropping the stream")
+	}
+	if calls != 0 {
+		t.Errorf("provider calls = %d, want none", calls)
+	}
+}
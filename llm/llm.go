@@ -2,49 +2,100 @@ package llm
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"slices"
 
 	"github.com/soralabs/zen/logger"
 )
 
 // LLMClient is the main client that manages provider interactions
 type LLMClient struct {
-	defaultProvider   Provider
-	chatProvider      Provider
-	embeddingProvider Provider
-	logger            *logger.Logger
-	ctx               context.Context
+	defaultProvider    Provider
+	chatProviders      []Provider      // Chat provider followed by its fallbacks
+	embeddingProviders []Provider      // Embedding provider followed by its fallbacks
+	providers          []Provider      // All configured providers, candidates for calls the chat providers can't serve
+	router             *router         // Chat providers of calls matching a route
+	embeddingModel     string          // Model of the primary embedding provider
+	embeddingDims      int             // Size of the primary embedding provider's vectors, zero until measured
+	embeddingCache     *embeddingCache // nil unless Config.EmbeddingCache is set
+	retry              RetryConfig
+	maxToolSteps       int
+	maxRepairs         int // Repairs of invalid structured output
+	interceptors       []Interceptor
+	cascade            *CascadeConfig // nil unless Config.Cascade is set
+	prices             PriceTable
+	usageRecorder      UsageRecorder
+	scope              UsageScope
+	logger             *logger.Logger
+	ctx                context.Context
 }
 
-func createProvider(config ProviderConfig, logger *logger.Logger, ctx context.Context) (Provider, error) {
+// createProvider creates a provider wrapped in the decorators enabled by its
+// config and the client's context window settings
+func createProvider(config ProviderConfig, client Config) (Provider, error) {
+	logger, ctx := client.Logger, client.Context
+	var provider Provider
 	switch config.Type {
 	case ProviderOpenAI:
-		return NewOpenAIProvider(Config{
+		provider = NewOpenAIProvider(Config{
 			DefaultProvider: config,
 			Logger:          logger,
 			Context:         ctx,
-		}), nil
+		})
 	case ProviderDeepseek:
-		return NewDeepseekProvider(Config{
+		provider = NewDeepseekProvider(Config{
 			DefaultProvider: config,
 			Logger:          logger,
 			Context:         ctx,
-		}), nil
+		})
+	case ProviderAnthropic:
+		provider = NewAnthropicProvider(Config{
+			DefaultProvider: config,
+			Logger:          logger,
+			Context:         ctx,
+		})
+	case ProviderGemini:
+		provider = NewGeminiProvider(Config{
+			DefaultProvider: config,
+			Logger:          logger,
+			Context:         ctx,
+		})
+	case ProviderLocal:
+		local, err := NewLocalProvider(Config{
+			DefaultProvider: config,
+			Logger:          logger,
+			Context:         ctx,
+		})
+		if err != nil {
+			return nil, err
+		}
+		provider = local
+	case ProviderHashing:
+		provider = NewHashingProvider(Config{
+			DefaultProvider: config,
+			Logger:          logger,
+			Context:         ctx,
+		})
 	default:
 		return nil, fmt.Errorf("unsupported provider type: %s", config.Type)
 	}
+
+	provider = newRateLimitedProvider(provider, config)
+	provider = newContextWindowProvider(provider, config, client.ContextWindow, mergePrices(client.Prices), logger)
+	return newRecordingProvider(provider, config)
 }
 
 // NewLLMClient creates a new LLM client with the specified providers
 func NewLLMClient(config Config) (*LLMClient, error) {
-	defaultProvider, err := createProvider(config.DefaultProvider, config.Logger, config.Context)
+	defaultProvider, err := createProvider(config.DefaultProvider, config)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create default provider: %w", err)
 	}
 
 	var chatProvider Provider = defaultProvider
 	if config.ChatProvider != nil {
-		chatProvider, err = createProvider(*config.ChatProvider, config.Logger, config.Context)
+		chatProvider, err = createProvider(*config.ChatProvider, config)
 		if err != nil {
 			return nil, fmt.Errorf("failed to create chat provider: %w", err)
 		}
@@ -52,32 +103,378 @@ func NewLLMClient(config Config) (*LLMClient, error) {
 
 	var embeddingProvider Provider = defaultProvider
 	if config.EmbeddingProvider != nil {
-		embeddingProvider, err = createProvider(*config.EmbeddingProvider, config.Logger, config.Context)
+		embeddingProvider, err = createProvider(*config.EmbeddingProvider, config)
 		if err != nil {
 			return nil, fmt.Errorf("failed to create embedding provider: %w", err)
 		}
 	}
 
+	chatProviders, err := createProviders(chatProvider, config.ChatFallbacks, config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create chat fallbacks: %w", err)
+	}
+	embeddingProviders, err := createProviders(embeddingProvider, config.EmbeddingFallbacks, config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create embedding fallbacks: %w", err)
+	}
+
+	chatConfig := config.DefaultProvider
+	if config.ChatProvider != nil {
+		chatConfig = *config.ChatProvider
+	}
+	embeddingConfig := config.DefaultProvider
+	if config.EmbeddingProvider != nil {
+		embeddingConfig = *config.EmbeddingProvider
+	}
+	if err := validateProviders(chatProviders, append([]ProviderConfig{chatConfig}, config.ChatFallbacks...), "chat", FeatureCompletion); err != nil {
+		return nil, err
+	}
+	if err := validateProviders(embeddingProviders, append([]ProviderConfig{embeddingConfig}, config.EmbeddingFallbacks...), "embedding", FeatureEmbeddings); err != nil {
+		return nil, err
+	}
+
+	chatRouter, err := newRouter(config)
+	if err != nil {
+		return nil, err
+	}
+
+	var providers []Provider
+	for _, provider := range slices.Concat(chatProviders, chatRouter.providers(), []Provider{defaultProvider}, embeddingProviders) {
+		if !slices.Contains(providers, provider) {
+			providers = append(providers, provider)
+		}
+	}
+
+	var embeddingCache *embeddingCache
+	if config.EmbeddingCache != nil {
+		embeddingCache = newEmbeddingCache(*config.EmbeddingCache, embeddingConfig)
+	}
+
+	retry := DefaultRetryConfig
+	if config.Retry != nil {
+		retry = config.Retry.withDefaults()
+	}
+
+	maxToolSteps := config.MaxToolSteps
+	if maxToolSteps <= 0 {
+		maxToolSteps = DefaultMaxToolSteps
+	}
+
+	maxRepairs := config.MaxStructuredRepairs
+	if maxRepairs <= 0 {
+		maxRepairs = DefaultMaxStructuredRepairs
+	}
+
 	return &LLMClient{
-		defaultProvider:   defaultProvider,
-		chatProvider:      chatProvider,
-		embeddingProvider: embeddingProvider,
-		logger:            config.Logger,
-		ctx:               config.Context,
+		defaultProvider:    defaultProvider,
+		chatProviders:      chatProviders,
+		embeddingProviders: embeddingProviders,
+		providers:          providers,
+		router:             chatRouter,
+		embeddingModel:     embeddingModelName(embeddingConfig),
+		embeddingDims:      embeddingDimensions(embeddingConfig),
+		embeddingCache:     embeddingCache,
+		retry:              retry,
+		maxToolSteps:       maxToolSteps,
+		maxRepairs:         maxRepairs,
+		cascade:            config.Cascade,
+		interceptors:       slices.Clip(config.Interceptors),
+		prices:             mergePrices(config.Prices),
+		usageRecorder:      config.UsageRecorder,
+		logger:             config.Logger,
+		ctx:                config.Context,
 	}, nil
 }
 
-// Implement the Provider interface by delegating to the appropriate provider
+// GenerateCompletion generates a completion with the chat provider, executing
+// any requested tools until the model produces a final message.
 func (c *LLMClient) GenerateCompletion(req CompletionRequest) (Message, error) {
-	return c.chatProvider.GenerateCompletion(c.ctx, req)
+	return c.GenerateCompletionContext(c.ctx, req)
+}
+
+// GenerateCompletionContext works like GenerateCompletion but is bound to ctx
+// instead of the client's context
+func (c *LLMClient) GenerateCompletionContext(ctx context.Context, req CompletionRequest) (Message, error) {
+	result, err := c.GenerateCompletionWithTraceContext(ctx, req)
+	if err != nil {
+		return Message{}, err
+	}
+	return result.Message, nil
+}
+
+// GenerateCompletionWithTrace works like GenerateCompletion but also returns
+// every tool call made along the way with its arguments and result.
+// The message's Usage covers all provider calls made for the completion.
+func (c *LLMClient) GenerateCompletionWithTrace(req CompletionRequest) (CompletionResult, error) {
+	return c.GenerateCompletionWithTraceContext(c.ctx, req)
+}
+
+// GenerateCompletionWithTraceContext works like GenerateCompletionWithTrace
+// but is bound to ctx instead of the client's context
+func (c *LLMClient) GenerateCompletionWithTraceContext(ctx context.Context, req CompletionRequest) (CompletionResult, error) {
+	if c.cascades(req.ModelType) {
+		return c.cascadeCompletion(ctx, req)
+	}
+	return c.generateCompletion(ctx, req)
+}
+
+// generateCompletion runs the tool loop and records its usage
+func (c *LLMClient) generateCompletion(ctx context.Context, req CompletionRequest) (CompletionResult, error) {
+	result, usage, err := c.runToolLoop(ctx, req)
+	usage = c.recordUsage(OperationCompletion, usage)
+	result.Message.Usage = &usage
+	return result, err
+}
+
+// GenerateCompletionStream streams a completion from the chat provider.
+// Requested tools are executed between provider turns and reported as
+// StreamEventToolCall and StreamEventToolResult events. The returned channel
+// is closed once the final message or an error is delivered.
+func (c *LLMClient) GenerateCompletionStream(req CompletionRequest) (<-chan StreamEvent, error) {
+	return c.GenerateCompletionStreamContext(c.ctx, req)
+}
+
+// GenerateCompletionStreamContext works like GenerateCompletionStream but is
+// bound to ctx instead of the client's context. Cancelling ctx ends the stream.
+func (c *LLMClient) GenerateCompletionStreamContext(ctx context.Context, req CompletionRequest) (<-chan StreamEvent, error) {
+	stream, err := c.openStream(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	events := make(chan StreamEvent)
+	go func() {
+		defer close(events)
+		// Drain the provider stream if we stop early so its producer can exit
+		defer func() {
+			for range stream {
+			}
+		}()
+
+		var usage Usage
+		defer func() {
+			c.recordUsage(OperationCompletion, usage)
+		}()
+		var reasoning string
+
+		messages := append([]Message(nil), req.Messages...)
+		for step := 1; ; step++ {
+			var final *Message
+			for event := range stream {
+				if event.Type == StreamEventDone {
+					final = event.Message
+					continue
+				}
+				if !sendStreamEvent(ctx, events, event) || event.Type == StreamEventError {
+					return
+				}
+			}
+			if final == nil {
+				sendStreamEvent(ctx, events, StreamEvent{Type: StreamEventError, Err: fmt.Errorf("stream ended without a response")})
+				return
+			}
+			if final.Usage != nil {
+				usage.Add(c.prices.price(*final.Usage))
+			}
+			reasoning = joinReasoning(reasoning, final.Reasoning)
+			if len(final.ToolCalls) == 0 {
+				total := usage
+				final.Usage = &total
+				final.Reasoning = reasoning
+				sendStreamEvent(ctx, events, StreamEvent{Type: StreamEventDone, Message: final})
+				return
+			}
+			if step > c.maxToolSteps {
+				sendStreamEvent(ctx, events, StreamEvent{
+					Type: StreamEventError,
+					Err:  fmt.Errorf("%w: model requested tools after %d steps", ErrMaxToolStepsExceeded, c.maxToolSteps),
+				})
+				return
+			}
+
+			for i := range final.ToolCalls {
+				if !sendStreamEvent(ctx, events, StreamEvent{Type: StreamEventToolCall, ToolCall: &final.ToolCalls[i]}) {
+					return
+				}
+			}
+			executions := executeToolCalls(ctx, req.Tools, final.ToolCalls, step)
+			for i := range executions {
+				if !sendStreamEvent(ctx, events, StreamEvent{Type: StreamEventToolResult, ToolResult: &executions[i]}) {
+					return
+				}
+			}
+
+			messages = appendToolResults(messages, *final, executions)
+			stepReq := req
+			stepReq.Messages = messages
+			// Keep the drained stream if reopening fails, the deferred drain
+			// would block forever on a nil channel
+			next, err := c.openStream(ctx, stepReq)
+			if err != nil {
+				sendStreamEvent(ctx, events, StreamEvent{Type: StreamEventError, Err: err})
+				return
+			}
+			stream = next
+		}
+	}()
+
+	return events, nil
 }
 
+// GenerateStructuredOutput fills result with JSON generated by the chat provider.
+// Replies that violate the result's schema or fail req.Validator are sent back
+// to the model together with the problems until it returns a valid result.
 func (c *LLMClient) GenerateStructuredOutput(req StructuredOutputRequest, result interface{}) error {
-	return c.chatProvider.GenerateStructuredOutput(c.ctx, req, result)
+	return c.GenerateStructuredOutputContext(c.ctx, req, result)
+}
+
+// GenerateStructuredOutputContext works like GenerateStructuredOutput but is
+// bound to ctx instead of the client's context
+func (c *LLMClient) GenerateStructuredOutputContext(ctx context.Context, req StructuredOutputRequest, result interface{}) error {
+	if c.cascades(req.ModelType) {
+		return c.cascadeStructuredOutput(ctx, req, result)
+	}
+	_, err := c.structuredOutput(ctx, req, result, c.maxRepairs)
+	return err
+}
+
+// structuredOutput generates structured output with up to maxRepairs repairs.
+// Returns the model of the last attempt.
+func (c *LLMClient) structuredOutput(ctx context.Context, req StructuredOutputRequest, result interface{}, maxRepairs int) (string, error) {
+	for repair := 0; ; repair++ {
+		usage, err := c.generateStructuredOutput(ctx, req, result)
+		c.recordUsage(OperationStructuredOutput, usage)
+		if err == nil && req.Validator != nil {
+			err = runValidator(req.Validator, result)
+		}
+
+		var validationErr *ValidationError
+		if !errors.As(err, &validationErr) || repair >= maxRepairs {
+			return usage.Model, err
+		}
+		if c.logger != nil {
+			c.logger.Warnf("Structured output rejected, requesting a repair (%d/%d): %v", repair+1, maxRepairs, err)
+		}
+		req.Messages = append(slices.Clip(req.Messages), repairMessages(validationErr)...)
+	}
 }
 
+// EmbedText generates an embedding vector with the embedding provider,
+// serving it from the embedding cache when enabled
 func (c *LLMClient) EmbedText(text string) ([]float32, error) {
-	return c.embeddingProvider.EmbedText(c.ctx, text)
+	return c.EmbedTextContext(c.ctx, text)
+}
+
+// EmbedTextContext works like EmbedText but is bound to ctx instead of the
+// client's context
+func (c *LLMClient) EmbedTextContext(ctx context.Context, text string) ([]float32, error) {
+	if c.embeddingCache != nil {
+		if embedding, ok := c.embeddingCache.get(text); ok {
+			return embedding, nil
+		}
+	}
+
+	embedding, usage, err := firstEmbedding(c.embed(ctx, []string{text}))
+	c.recordUsage(OperationEmbedding, usage)
+	if err != nil {
+		return nil, err
+	}
+	if err := c.checkEmbeddingModel(usage); err != nil {
+		return nil, err
+	}
+
+	c.cacheEmbedding(text, embedding)
+	return embedding, nil
+}
+
+// EmbedTexts generates embedding vectors for several texts with the embedding
+// provider, batching them into as few requests as the provider allows.
+// Cached and duplicate texts are only embedded once.
+// The embeddings are returned in the order of the texts.
+func (c *LLMClient) EmbedTexts(texts []string) ([][]float32, error) {
+	return c.EmbedTextsContext(c.ctx, texts)
+}
+
+// EmbedTextsContext works like EmbedTexts but is bound to ctx instead of the
+// client's context
+func (c *LLMClient) EmbedTextsContext(ctx context.Context, texts []string) ([][]float32, error) {
+	if len(texts) == 0 {
+		return nil, nil
+	}
+
+	embeddings := make([][]float32, len(texts))
+	var missing []string
+	positions := make(map[string][]int)
+	for i, text := range texts {
+		if c.embeddingCache != nil {
+			if embedding, ok := c.embeddingCache.get(text); ok {
+				embeddings[i] = embedding
+				continue
+			}
+		}
+		if _, seen := positions[text]; !seen {
+			missing = append(missing, text)
+		}
+		positions[text] = append(positions[text], i)
+	}
+	if len(missing) == 0 {
+		return embeddings, nil
+	}
+
+	generated, usage, err := c.embed(ctx, missing)
+	c.recordUsage(OperationEmbedding, usage)
+	if err != nil {
+		return nil, err
+	}
+	if err := c.checkEmbeddingModel(usage); err != nil {
+		return nil, err
+	}
+
+	for i, text := range missing {
+		for _, position := range positions[text] {
+			embeddings[position] = generated[i]
+		}
+		c.cacheEmbedding(text, generated[i])
+	}
+	return embeddings, nil
+}
+
+// EmbeddingModel returns the model embeddings are generated with. Store it
+// alongside embeddings so vectors of different models are never compared.
+func (c *LLMClient) EmbeddingModel() string {
+	return c.embeddingModel
+}
+
+// EmbeddingDimensions returns the size of the client's embeddings. Unless the
+// embedding model's size is known, it is measured by embedding a short text.
+func (c *LLMClient) EmbeddingDimensions(ctx context.Context) (int, error) {
+	if c.embeddingDims > 0 {
+		return c.embeddingDims, nil
+	}
+	embedding, err := c.EmbedTextContext(ctx, "embedding dimensions")
+	if err != nil {
+		return 0, fmt.Errorf("failed to measure embedding dimensions: %w", err)
+	}
+	return len(embedding), nil
+}
+
+// checkEmbeddingModel rejects embeddings from a fallback provider that uses a
+// different model than the primary embedding provider
+func (c *LLMClient) checkEmbeddingModel(usage Usage) error {
+	if c.embeddingModel == "" || usage.Model == "" || usage.Model == c.embeddingModel {
+		return nil
+	}
+	return fmt.Errorf("%w: expected %s, got %s", ErrEmbeddingModelMismatch, c.embeddingModel, usage.Model)
+}
+
+// cacheEmbedding stores a generated embedding in the embedding cache if enabled
+func (c *LLMClient) cacheEmbedding(text string, embedding []float32) {
+	if c.embeddingCache == nil {
+		return
+	}
+	if err := c.embeddingCache.set(text, embedding); err != nil && c.logger != nil {
+		c.logger.Warnf("Failed to persist cached embedding: %v", err)
+	}
 }
 
 // Helper functions for creating messages
@@ -95,6 +492,20 @@ func NewUserMessage(content string) Message {
 	}
 }
 
+// NewUserMessageWithImages creates a user message with text followed by
+// images referenced by URL
+func NewUserMessageWithImages(content string, imageURLs ...string) Message {
+	parts := make([]ContentPart, len(imageURLs))
+	for i, url := range imageURLs {
+		parts[i] = ImageURLPart(url)
+	}
+	return Message{
+		Role:    RoleUser,
+		Content: content,
+		Parts:   parts,
+	}
+}
+
 func NewAssistantMessage(content string) Message {
 	return Message{
 		Role:    RoleAssistant,
@@ -109,3 +520,13 @@ func NewToolMessage(content string, name string) Message {
 		Name:    name,
 	}
 }
+
+// NewToolResultMessage creates a tool message answering the tool call with the given ID
+func NewToolResultMessage(content string, name string, toolCallID string) Message {
+	return Message{
+		Role:       RoleTool,
+		Content:    content,
+		Name:       name,
+		ToolCallID: toolCallID,
+	}
+}
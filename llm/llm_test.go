@@ -0,0 +1,63 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	toolkit "github.com/soralabs/toolkit/go"
+)
+
+func TestStreamEndsWhenReopeningFails(t *testing.T) {
+	boom := errors.New("boom")
+	var mu sync.Mutex
+	streams := 0
+	provider := &funcProvider{
+		caps: allCapabilities,
+		stream: func(ctx context.Context, req CompletionRequest) (<-chan StreamEvent, error) {
+			mu.Lock()
+			defer mu.Unlock()
+			streams++
+			if streams > 1 {
+				return nil, boom
+			}
+			return streamOf(StreamEvent{Type: StreamEventDone, Message: &Message{
+				Role:      RoleAssistant,
+				ToolCalls: []ToolCall{{ID: "call_1", Name: "search", Arguments: `{"query":"go"}`}},
+			}}), nil
+		},
+	}
+	client := newTestClient(provider)
+
+	events, err := client.GenerateCompletionStream(CompletionRequest{
+		Messages: []Message{NewUserMessage("Search for go")},
+		Tools:    []toolkit.Tool{&fakeTool{name: "search", result: `{"hits":3}`}},
+	})
+	if err != nil {
+		t.Fatalf("GenerateCompletionStream: %v", err)
+	}
+
+	var types []StreamEventType
+	timeout := time.After(5 * time.Second)
+	for {
+		select {
+		case event, ok := <-events:
+			if !ok {
+				want := []StreamEventType{StreamEventToolCall, StreamEventToolResult, StreamEventError}
+				if !slices.Equal(types, want) {
+					t.Errorf("events = %v, want %v", types, want)
+				}
+				return
+			}
+			types = append(types, event.Type)
+			if event.Type == StreamEventError && !errors.Is(event.Err, boom) {
+				t.Errorf("error = %v, want boom", event.Err)
+			}
+		case <-timeout:
+			t.Fatalf("stream not closed after %v", types)
+		}
+	}
+}
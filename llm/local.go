@@ -0,0 +1,195 @@
+package llm
+
+import (
+	"bufio"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// LocalProvider embeds text fully in-process with a model loaded from disk:
+//   - A BERT sentence-embedding model in GGUF format (.gguf), such as
+//     all-MiniLM-L6-v2 or bge-small-en converted by llama.cpp, with F32, F16,
+//     BF16 or Q8_0 weights
+//   - A word-vector file in the text format used by GloVe and fastText
+//     (.txt/.vec, optionally gzipped): one word per line followed by its
+//     vector components. A text is embedded as the normalized mean of the
+//     vectors of its known words, which is fast but far less accurate.
+type LocalProvider struct {
+	embeddingOnlyProvider
+	embedder   localEmbedder
+	dimensions int
+	model      string
+}
+
+// localEmbedder is a model LocalProvider embeds texts with
+type localEmbedder interface {
+	// embed returns the normalized embedding of text and its token count
+	embed(text string) ([]float32, int)
+}
+
+// NewLocalProvider loads the model at ProviderConfig.ModelPath
+func NewLocalProvider(config Config) (*LocalProvider, error) {
+	path := config.DefaultProvider.ModelPath
+	if path == "" {
+		return nil, fmt.Errorf("local provider requires a ModelPath")
+	}
+
+	provider := &LocalProvider{
+		embeddingOnlyProvider: embeddingOnlyProvider{providerType: ProviderLocal},
+		model:                 embeddingModelName(config.DefaultProvider),
+	}
+	if strings.HasSuffix(path, ".gguf") {
+		model, err := loadBERTModel(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load local embedding model: %w", err)
+		}
+		provider.embedder = model
+		provider.dimensions = model.nEmbd
+
+		if config.Logger != nil {
+			config.Logger.Infof("Loaded local embedding model %s with %d layers of %d dimensions", path, len(model.layers), model.nEmbd)
+		}
+		return provider, nil
+	}
+
+	vectors, dimensions, err := loadWordVectors(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load local embedding model: %w", err)
+	}
+	provider.embedder = wordVectorModel{vectors: vectors, dimensions: dimensions}
+	provider.dimensions = dimensions
+
+	if config.Logger != nil {
+		config.Logger.Infof("Loaded local embedding model %s with %d words of %d dimensions", path, len(vectors), dimensions)
+	}
+	return provider, nil
+}
+
+// Dimensions returns the size of the model's embeddings
+func (p *LocalProvider) Dimensions() int {
+	return p.dimensions
+}
+
+// EmbedText embeds the text with the local model
+func (p *LocalProvider) EmbedText(ctx context.Context, text string) ([]float32, Usage, error) {
+	return firstEmbedding(p.EmbedTexts(ctx, []string{text}))
+}
+
+// EmbedTexts embeds every text with the local model. With a word-vector
+// model, texts without any known word get a zero vector.
+func (p *LocalProvider) EmbedTexts(ctx context.Context, texts []string) ([][]float32, Usage, error) {
+	usage := Usage{Provider: ProviderLocal, Model: p.model}
+	embeddings := make([][]float32, len(texts))
+	for i, text := range texts {
+		if err := ctx.Err(); err != nil {
+			return nil, usage, err
+		}
+
+		embedding, tokens := p.embedder.embed(text)
+		embeddings[i] = embedding
+		usage.PromptTokens += tokens
+	}
+	usage.TotalTokens = usage.PromptTokens
+
+	return embeddings, usage, nil
+}
+
+// wordVectorModel embeds a text as the mean of its word vectors
+type wordVectorModel struct {
+	vectors    map[string][]float32
+	dimensions int
+}
+
+func (m wordVectorModel) embed(text string) ([]float32, int) {
+	words := tokenizeWords(text)
+	embedding := make([]float32, m.dimensions)
+	known := 0
+	for _, word := range words {
+		vector, ok := m.vectors[word]
+		if !ok {
+			continue
+		}
+		for j, value := range vector {
+			embedding[j] += value
+		}
+		known++
+	}
+	if known > 0 {
+		for j := range embedding {
+			embedding[j] /= float32(known)
+		}
+	}
+	return normalize(embedding), len(words)
+}
+
+// loadWordVectors reads a word-vector file and returns the vectors by
+// lowercased word together with their dimension
+func loadWordVectors(path string) (map[string][]float32, int, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer file.Close()
+
+	var reader io.Reader = file
+	if strings.HasSuffix(path, ".gz") {
+		gzipReader, err := gzip.NewReader(file)
+		if err != nil {
+			return nil, 0, err
+		}
+		defer gzipReader.Close()
+		reader = gzipReader
+	}
+
+	scanner := bufio.NewScanner(reader)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+
+	vectors := make(map[string][]float32)
+	dimensions := 0
+	for line := 1; scanner.Scan(); line++ {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 {
+			continue
+		}
+		// fastText files start with a "<words> <dimensions>" header
+		if line == 1 && len(fields) == 2 {
+			if _, err := strconv.Atoi(fields[0]); err == nil {
+				continue
+			}
+		}
+
+		vector := make([]float32, len(fields)-1)
+		for i, field := range fields[1:] {
+			value, err := strconv.ParseFloat(field, 32)
+			if err != nil {
+				return nil, 0, fmt.Errorf("line %d: invalid vector component %q", line, field)
+			}
+			vector[i] = float32(value)
+		}
+
+		if dimensions == 0 {
+			dimensions = len(vector)
+		} else if len(vector) != dimensions {
+			return nil, 0, fmt.Errorf("line %d: expected %d dimensions, got %d", line, dimensions, len(vector))
+		}
+
+		// Keep the first (most frequent) vector when casing variants collide
+		word := strings.ToLower(fields[0])
+		if _, exists := vectors[word]; !exists {
+			vectors[word] = vector
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, 0, err
+	}
+	if dimensions == 0 {
+		return nil, 0, fmt.Errorf("no word vectors found in %s", path)
+	}
+
+	return vectors, dimensions, nil
+}
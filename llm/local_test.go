@@ -0,0 +1,420 @@
+package llm
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// ggufTestTensor is a tensor written by writeTestGGUF
+type ggufTestTensor struct {
+	name string
+	dims []uint64
+	typ  uint32
+	data []byte
+}
+
+// writeTestGGUF writes a GGUF v3 file with the given metadata, in key order,
+// and tensors
+func writeTestGGUF(t *testing.T, keys []string, metadata map[string]interface{}, tensors []ggufTestTensor) string {
+	t.Helper()
+	var buf bytes.Buffer
+	write := func(v interface{}) {
+		if err := binary.Write(&buf, binary.LittleEndian, v); err != nil {
+			t.Fatal(err)
+		}
+	}
+	writeString := func(s string) {
+		write(uint64(len(s)))
+		buf.WriteString(s)
+	}
+
+	write(uint32(ggufMagic))
+	write(uint32(3))
+	write(uint64(len(tensors)))
+	write(uint64(len(keys)))
+	for _, key := range keys {
+		writeString(key)
+		switch value := metadata[key].(type) {
+		case string:
+			write(uint32(ggufTypeString))
+			writeString(value)
+		case uint32:
+			write(uint32(ggufTypeUint32))
+			write(value)
+		case float32:
+			write(uint32(ggufTypeFloat32))
+			write(value)
+		case bool:
+			write(uint32(ggufTypeBool))
+			write(value)
+		case []string:
+			write(uint32(ggufTypeArray))
+			write(uint32(ggufTypeString))
+			write(uint64(len(value)))
+			for _, s := range value {
+				writeString(s)
+			}
+		case []int32:
+			write(uint32(ggufTypeArray))
+			write(uint32(ggufTypeInt32))
+			write(uint64(len(value)))
+			write(value)
+		default:
+			t.Fatalf("unsupported metadata value %T", value)
+		}
+	}
+
+	var offset uint64
+	for _, tensor := range tensors {
+		writeString(tensor.name)
+		write(uint32(len(tensor.dims)))
+		write(tensor.dims)
+		write(tensor.typ)
+		write(offset)
+		offset += uint64(len(tensor.data)+ggufDefaultAlignment-1) / ggufDefaultAlignment * ggufDefaultAlignment
+	}
+	for buf.Len()%ggufDefaultAlignment != 0 {
+		buf.WriteByte(0)
+	}
+	for _, tensor := range tensors {
+		buf.Write(tensor.data)
+		for buf.Len()%ggufDefaultAlignment != 0 {
+			buf.WriteByte(0)
+		}
+	}
+
+	path := filepath.Join(t.TempDir(), "model.gguf")
+	if err := os.WriteFile(path, buf.Bytes(), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func f32Tensor(name string, values []float32, dims ...uint64) ggufTestTensor {
+	data := make([]byte, 4*len(values))
+	for i, value := range values {
+		binary.LittleEndian.PutUint32(data[4*i:], math.Float32bits(value))
+	}
+	return ggufTestTensor{name: name, dims: dims, typ: ggmlTypeF32, data: data}
+}
+
+// testBERTVocab uses llama.cpp's vocabulary layout: "▁" marks word starts
+var testBERTVocab = []string{
+	"[PAD]", "[UNK]", "[CLS]", "[SEP]",
+	"▁hello", "▁world", "▁the", "▁hel", "lo", "▁.", "▁cafe", "▁un", "known", "s",
+}
+
+// writeTestBERTModel writes a tiny BERT model whose weights follow a fixed
+// formula: value i of the n-th tensor is 0.5*sin(1.3*n + 0.37*i), plus 1
+// for layer norm weights
+func writeTestBERTModel(t *testing.T, pooling uint32) string {
+	t.Helper()
+	const (
+		nEmbd  = 8
+		nHead  = 2
+		nFF    = 16
+		nCtx   = 16
+		nLayer = 2
+	)
+	nVocab := uint64(len(testBERTVocab))
+
+	var tensors []ggufTestTensor
+	add := func(name string, dims ...uint64) {
+		n := 1
+		for _, dim := range dims {
+			n *= int(dim)
+		}
+		seed := float64(len(tensors))
+		values := make([]float32, n)
+		for i := range values {
+			values[i] = float32(0.5 * math.Sin(1.3*seed+0.37*float64(i)))
+			if filepath.Ext(name) == ".weight" && len(dims) == 1 {
+				values[i]++
+			}
+		}
+		tensors = append(tensors, f32Tensor(name, values, dims...))
+	}
+
+	add("token_embd.weight", nEmbd, nVocab)
+	add("position_embd.weight", nEmbd, nCtx)
+	add("token_types.weight", nEmbd, 2)
+	add("token_embd_norm.weight", nEmbd)
+	add("token_embd_norm.bias", nEmbd)
+	for i := 0; i < nLayer; i++ {
+		prefix := fmt.Sprintf("blk.%d.", i)
+		for _, name := range []string{"attn_q", "attn_k", "attn_v", "attn_output"} {
+			add(prefix+name+".weight", nEmbd, nEmbd)
+			add(prefix+name+".bias", nEmbd)
+		}
+		add(prefix+"attn_output_norm.weight", nEmbd)
+		add(prefix+"attn_output_norm.bias", nEmbd)
+		add(prefix+"ffn_up.weight", nEmbd, nFF)
+		add(prefix+"ffn_up.bias", nFF)
+		add(prefix+"ffn_down.weight", nFF, nEmbd)
+		add(prefix+"ffn_down.bias", nEmbd)
+		add(prefix+"layer_output_norm.weight", nEmbd)
+		add(prefix+"layer_output_norm.bias", nEmbd)
+	}
+
+	keys := []string{
+		"general.architecture", "bert.context_length", "bert.embedding_length",
+		"bert.feed_forward_length", "bert.attention.head_count", "bert.block_count",
+		"bert.attention.layer_norm_epsilon", "bert.attention.causal", "bert.pooling_type",
+		"tokenizer.ggml.model", "tokenizer.ggml.tokens", "tokenizer.ggml.token_type",
+		"tokenizer.ggml.unknown_token_id", "tokenizer.ggml.cls_token_id", "tokenizer.ggml.seperator_token_id",
+	}
+	metadata := map[string]interface{}{
+		"general.architecture":              "bert",
+		"bert.context_length":               uint32(nCtx),
+		"bert.embedding_length":             uint32(nEmbd),
+		"bert.feed_forward_length":          uint32(nFF),
+		"bert.attention.head_count":         uint32(nHead),
+		"bert.block_count":                  uint32(nLayer),
+		"bert.attention.layer_norm_epsilon": float32(1e-12),
+		"bert.attention.causal":             false,
+		"bert.pooling_type":                 pooling,
+		"tokenizer.ggml.model":              "bert",
+		"tokenizer.ggml.tokens":             testBERTVocab,
+		"tokenizer.ggml.token_type":         make([]int32, nVocab),
+		"tokenizer.ggml.unknown_token_id":   uint32(1),
+		"tokenizer.ggml.cls_token_id":       uint32(2),
+		"tokenizer.ggml.seperator_token_id": uint32(3),
+	}
+	return writeTestGGUF(t, keys, metadata, tensors)
+}
+
+func TestBERTTokenize(t *testing.T) {
+	model, err := loadBERTModel(writeTestBERTModel(t, bertPoolingMean))
+	if err != nil {
+		t.Fatalf("loadBERTModel: %v", err)
+	}
+
+	tests := []struct {
+		text string
+		want []int
+	}{
+		{"Hello world.", []int{2, 4, 5, 9, 3}},
+		// Greedy longest match: "hello" is a word, "hel"+"lo" only after it
+		{"hellos", []int{2, 4, 13, 3}},
+		// Accents are stripped and case is ignored
+		{"CAFÉ", []int{2, 10, 3}},
+		{"unknowns", []int{2, 11, 12, 13, 3}},
+		// Words that cannot be split become [UNK]
+		{"xyz the", []int{2, 1, 6, 3}},
+		{"", []int{2, 3}},
+	}
+	for _, tt := range tests {
+		if got := model.vocab.tokenize(tt.text, model.nCtx); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("tokenize(%q) = %v, want %v", tt.text, got, tt.want)
+		}
+	}
+
+	// Long inputs are truncated to the context, keeping [SEP]
+	long := model.vocab.tokenize("the the the the the the the the the the the the the the the the the the", model.nCtx)
+	if len(long) != model.nCtx || long[0] != 2 || long[len(long)-1] != 3 {
+		t.Errorf("truncated tokens = %v", long)
+	}
+}
+
+func TestBERTHashVocabulary(t *testing.T) {
+	file := &ggufFile{metadata: map[string]interface{}{
+		"tokenizer.ggml.model":  "bert",
+		"tokenizer.ggml.tokens": []string{"[PAD]", "[UNK]", "[CLS]", "[SEP]", "play", "##ing", "##s", "!"},
+	}}
+	vocab, err := newWordPieceVocab(file)
+	if err != nil {
+		t.Fatalf("newWordPieceVocab: %v", err)
+	}
+	if got, want := vocab.tokenize("Playing plays!", 16), []int{2, 4, 5, 4, 6, 7, 3}; !reflect.DeepEqual(got, want) {
+		t.Errorf("tokenize = %v, want %v", got, want)
+	}
+}
+
+func TestBERTEmbedding(t *testing.T) {
+	// Reference values computed independently in float64
+	tests := []struct {
+		pooling uint32
+		text    string
+		want    []float32
+	}{
+		{bertPoolingMean, "Hello world.", []float32{-0.146396, -0.228247, 0.381961, -0.216479, 0.434385, -0.472793, 0.089240, -0.559935}},
+		{bertPoolingCLS, "Hello world.", []float32{-0.165461, -0.203768, 0.412267, -0.051045, 0.477124, -0.339906, -0.033937, -0.643581}},
+	}
+	for _, tt := range tests {
+		model, err := loadBERTModel(writeTestBERTModel(t, tt.pooling))
+		if err != nil {
+			t.Fatalf("loadBERTModel: %v", err)
+		}
+		got, tokens := model.embed(tt.text)
+		if tokens != 5 {
+			t.Errorf("tokens = %d, want 5", tokens)
+		}
+		if len(got) != len(tt.want) {
+			t.Fatalf("embedding has %d dimensions, want %d", len(got), len(tt.want))
+		}
+		for i := range got {
+			if math.Abs(float64(got[i]-tt.want[i])) > 1e-4 {
+				t.Errorf("pooling %d: embedding = %v, want %v", tt.pooling, got, tt.want)
+				break
+			}
+		}
+	}
+}
+
+func TestLocalProviderGGUF(t *testing.T) {
+	path := writeTestBERTModel(t, bertPoolingMean)
+	provider, err := NewLocalProvider(Config{DefaultProvider: ProviderConfig{Type: ProviderLocal, ModelPath: path}})
+	if err != nil {
+		t.Fatalf("NewLocalProvider: %v", err)
+	}
+	if provider.Dimensions() != 8 {
+		t.Errorf("dimensions = %d, want 8", provider.Dimensions())
+	}
+
+	embeddings, usage, err := provider.EmbedTexts(context.Background(), []string{"Hello world.", "the cafe", "Hello world."})
+	if err != nil {
+		t.Fatalf("EmbedTexts: %v", err)
+	}
+	if usage.Model != "local:model.gguf" || usage.PromptTokens != 14 {
+		t.Errorf("usage = %+v", usage)
+	}
+	if !reflect.DeepEqual(embeddings[0], embeddings[2]) {
+		t.Error("embeddings of the same text differ")
+	}
+	if reflect.DeepEqual(embeddings[0], embeddings[1]) {
+		t.Error("embeddings of different texts are equal")
+	}
+	var norm float64
+	for _, value := range embeddings[1] {
+		norm += float64(value) * float64(value)
+	}
+	if math.Abs(norm-1) > 1e-5 {
+		t.Errorf("embedding norm = %f, want 1", math.Sqrt(norm))
+	}
+}
+
+func TestLocalProviderWordVectors(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "vectors.vec")
+	if err := os.WriteFile(path, []byte("2 3\nhello 1 0 0\nworld 0 1 0\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	provider, err := NewLocalProvider(Config{DefaultProvider: ProviderConfig{Type: ProviderLocal, ModelPath: path}})
+	if err != nil {
+		t.Fatalf("NewLocalProvider: %v", err)
+	}
+
+	embedding, _, err := provider.EmbedText(context.Background(), "Hello, world")
+	if err != nil {
+		t.Fatalf("EmbedText: %v", err)
+	}
+	want := float32(1 / math.Sqrt2)
+	if math.Abs(float64(embedding[0]-want)) > 1e-6 || math.Abs(float64(embedding[1]-want)) > 1e-6 || embedding[2] != 0 {
+		t.Errorf("embedding = %v", embedding)
+	}
+}
+
+func TestGGUFQuantizedTensors(t *testing.T) {
+	// F16 values 1, -2, 0.5 and the smallest subnormal
+	f16 := []byte{0x00, 0x3c, 0x00, 0xc0, 0x00, 0x38, 0x01, 0x00}
+
+	// One Q8_0 block with scale 0.5
+	q8 := []byte{0x00, 0x38}
+	for i := 0; i < ggmlQ8Block; i++ {
+		q8 = append(q8, byte(int8(i-16)))
+	}
+
+	path := writeTestGGUF(t, nil, nil, []ggufTestTensor{
+		{name: "f16", dims: []uint64{4}, typ: ggmlTypeF16, data: f16},
+		{name: "q8", dims: []uint64{ggmlQ8Block}, typ: ggmlTypeQ8_0, data: q8},
+	})
+	file, err := readGGUF(path)
+	if err != nil {
+		t.Fatalf("readGGUF: %v", err)
+	}
+
+	values, err := file.readTensor("f16", 4)
+	if err != nil {
+		t.Fatalf("readTensor(f16): %v", err)
+	}
+	if want := []float32{1, -2, 0.5, float32(math.Ldexp(1, -24))}; !reflect.DeepEqual(values, want) {
+		t.Errorf("f16 = %v, want %v", values, want)
+	}
+
+	values, err = file.readTensor("q8", ggmlQ8Block)
+	if err != nil {
+		t.Fatalf("readTensor(q8): %v", err)
+	}
+	for i, value := range values {
+		if want := 0.5 * float32(i-16); value != want {
+			t.Fatalf("q8[%d] = %v, want %v", i, value, want)
+		}
+	}
+
+	if _, err := file.readTensor("f16", 2, 2); err == nil {
+		t.Error("expected a shape error")
+	}
+	if _, err := file.readTensor("missing", 1); err == nil {
+		t.Error("expected a missing tensor error")
+	}
+}
+
+func TestGGUFRejectsInvalidFiles(t *testing.T) {
+	if _, err := parseGGUF([]byte("not a model")); err == nil {
+		t.Error("expected an error for a non-GGUF file")
+	}
+	path := writeTestBERTModel(t, bertPoolingMean)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := parseGGUF(data[:100]); err == nil {
+		t.Error("expected an error for a truncated header")
+	}
+	truncated := filepath.Join(t.TempDir(), "truncated.gguf")
+	if err := os.WriteFile(truncated, data[:len(data)-64], 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := loadBERTModel(truncated); err == nil {
+		t.Error("expected an error for truncated tensor data")
+	}
+}
+
+func TestEmbeddingDimensions(t *testing.T) {
+	tests := []struct {
+		config ProviderConfig
+		want   int
+	}{
+		{ProviderConfig{Type: ProviderOpenAI}, 1536},
+		{ProviderConfig{Type: ProviderOpenAI, EmbeddingModel: "text-embedding-3-large", EmbeddingDimensions: 256}, 256},
+		{ProviderConfig{Type: ProviderGemini}, 768},
+		{ProviderConfig{Type: ProviderHashing}, 384},
+		{ProviderConfig{Type: ProviderDeepseek, EmbeddingModel: "unknown"}, 0},
+		{ProviderConfig{Type: ProviderLocal, ModelPath: "model.gguf"}, 0},
+	}
+	for _, tt := range tests {
+		if got := embeddingDimensions(tt.config); got != tt.want {
+			t.Errorf("embeddingDimensions(%+v) = %d, want %d", tt.config, got, tt.want)
+		}
+	}
+
+	// Local models are measured by embedding a text
+	client, err := NewLLMClient(Config{
+		DefaultProvider:   ProviderConfig{Type: ProviderOpenAI, APIKey: "test"},
+		EmbeddingProvider: &ProviderConfig{Type: ProviderLocal, ModelPath: writeTestBERTModel(t, bertPoolingMean)},
+		Context:           context.Background(),
+	})
+	if err != nil {
+		t.Fatalf("NewLLMClient: %v", err)
+	}
+	dimensions, err := client.EmbeddingDimensions(context.Background())
+	if err != nil || dimensions != 8 {
+		t.Errorf("EmbeddingDimensions = %d, %v, want 8", dimensions, err)
+	}
+}
@@ -1,5 +1,11 @@
 package llm
 
+import (
+	"encoding/base64"
+	"net/http"
+	"strings"
+)
+
 type Role string
 
 const (
@@ -10,15 +16,101 @@ const (
 )
 
 type ToolCall struct {
+	ID        string // Provider-assigned ID, echoed by the tool result message
 	Name      string
 	Arguments string
 }
 
+// ContentPartType identifies the kind of a content part
+type ContentPartType string
+
+const (
+	ContentPartText  ContentPartType = "text"
+	ContentPartImage ContentPartType = "image"
+)
+
+// ContentPart is a piece of multimodal message content. Images are referenced
+// by URL or passed inline as bytes.
+type ContentPart struct {
+	Type      ContentPartType
+	Text      string
+	ImageURL  string
+	ImageData []byte
+	MIMEType  string // Media type of ImageData, detected from the data if empty
+}
+
 type Message struct {
-	Role     Role
-	Content  string
-	Name     string
-	ToolCall *ToolCall
+	Role       Role
+	Content    string
+	Parts      []ContentPart // Multimodal content sent after Content, for vision-capable models
+	Name       string
+	ToolCalls  []ToolCall
+	ToolCallID string // ID of the tool call a RoleTool message answers
+	ToolError  bool   // Whether a RoleTool message reports a failed tool call
+	Usage      *Usage // Token usage of the call that produced this message
+	// Reasoning the model produced before answering, for models that expose it
+	Reasoning string
+	// Provider signature of Reasoning, required to send it back within a tool-calling turn
+	ReasoningSignature string
+	Cascade            *CascadeTrace // Stages of a cascaded completion, see CascadeConfig
+}
+
+// TextPart creates a text content part
+func TextPart(text string) ContentPart {
+	return ContentPart{Type: ContentPartText, Text: text}
+}
+
+// ImageURLPart creates an image content part referencing an image by URL
+func ImageURLPart(url string) ContentPart {
+	return ContentPart{Type: ContentPartImage, ImageURL: url}
+}
+
+// ImageDataPart creates an image content part from raw image bytes. The MIME
+// type may be left empty to detect it from the data.
+func ImageDataPart(data []byte, mimeType string) ContentPart {
+	return ContentPart{Type: ContentPartImage, ImageData: data, MIMEType: mimeType}
+}
+
+// Text returns the message content followed by its text parts, for providers
+// and estimates that only handle text
+func (m Message) Text() string {
+	texts := make([]string, 0, len(m.Parts)+1)
+	if m.Content != "" {
+		texts = append(texts, m.Content)
+	}
+	for _, part := range m.Parts {
+		if part.Type == ContentPartText && part.Text != "" {
+			texts = append(texts, part.Text)
+		}
+	}
+	return strings.Join(texts, "\n")
+}
+
+// imageCount returns the number of image parts of the message
+func (m Message) imageCount() int {
+	count := 0
+	for _, part := range m.Parts {
+		if part.Type == ContentPartImage {
+			count++
+		}
+	}
+	return count
+}
+
+// mediaType returns the MIME type of an inline image
+func (p ContentPart) mediaType() string {
+	if p.MIMEType != "" {
+		return p.MIMEType
+	}
+	return http.DetectContentType(p.ImageData)
+}
+
+// dataURL returns the image as a URL, encoding inline images as a data URL
+func (p ContentPart) dataURL() string {
+	if p.ImageURL != "" || len(p.ImageData) == 0 {
+		return p.ImageURL
+	}
+	return "data:" + p.mediaType() + ";base64," + base64.StdEncoding.EncodeToString(p.ImageData)
 }
 
 type ModelType string
@@ -0,0 +1,102 @@
+package llm
+
+import (
+	"strings"
+
+	"github.com/sashabaranov/go-openai"
+)
+
+// defaultModels maps capability levels to models for providers without a ModelConfig
+var defaultModels = map[ProviderType]map[ModelType]string{
+	ProviderOpenAI: {
+		ModelTypeFast:     openai.GPT4oMini,
+		ModelTypeDefault:  openai.GPT4oMini,
+		ModelTypeAdvanced: openai.GPT4o,
+	},
+	ProviderDeepseek: {
+		ModelTypeFast:     "deepseek-chat",
+		ModelTypeDefault:  "deepseek-chat",
+		ModelTypeAdvanced: "deepseek-reasoner",
+	},
+	ProviderAnthropic: {
+		ModelTypeFast:     "claude-3-5-haiku-latest",
+		ModelTypeDefault:  "claude-3-5-sonnet-latest",
+		ModelTypeAdvanced: "claude-3-5-sonnet-latest",
+	},
+	ProviderGemini: {
+		ModelTypeFast:     "gemini-2.0-flash-lite",
+		ModelTypeDefault:  "gemini-2.0-flash",
+		ModelTypeAdvanced: "gemini-2.5-pro",
+	},
+}
+
+// modelFor returns the model a provider config uses for a capability level,
+// falling back to its default model like the providers do
+func modelFor(config ProviderConfig, modelType ModelType) string {
+	models := config.ModelConfig
+	if models == nil {
+		models = defaultModels[config.Type]
+	}
+	if model, ok := models[modelType]; ok {
+		return model
+	}
+	return models[ModelTypeDefault]
+}
+
+// defaultContextLimit is assumed for models missing from the context limits
+const defaultContextLimit = 8192
+
+// DefaultContextLimits holds the context window in tokens of known models.
+// Dated model versions match the entry of their longest prefix.
+var DefaultContextLimits = map[string]int{
+	"gpt-4o":                   128000,
+	"gpt-4o-mini":              128000,
+	"gpt-4-turbo":              128000,
+	"gpt-4":                    8192,
+	"gpt-3.5-turbo":            16385,
+	"o1":                       200000,
+	"o1-mini":                  128000,
+	"o3-mini":                  200000,
+	"deepseek-chat":            64000,
+	"deepseek-reasoner":        64000,
+	"claude-3-5-sonnet-latest": 200000,
+	"claude-3-5-haiku-latest":  200000,
+	"claude-3-5-sonnet":        200000,
+	"claude-3-5-haiku":         200000,
+	"claude-3-opus":            200000,
+	"gemini-2.5-pro":           1048576,
+	"gemini-2.5-flash":         1048576,
+	"gemini-2.0-flash":         1048576,
+	"gemini-2.0-flash-lite":    1048576,
+	"gemini-1.5-pro":           2097152,
+	"gemini-1.5-flash":         1048576,
+}
+
+// DefaultEmbeddingDimensions holds the vector size of known embedding models
+var DefaultEmbeddingDimensions = map[string]int{
+	"text-embedding-ada-002": 1536,
+	"text-embedding-3-small": 1536,
+	"text-embedding-3-large": 3072,
+	"text-embedding-004":     768,
+}
+
+// contextLimit returns the context window of a model, preferring the
+// overrides over DefaultContextLimits
+func contextLimit(model string, overrides map[string]int) int {
+	for _, limits := range []map[string]int{overrides, DefaultContextLimits} {
+		if limit, ok := limits[model]; ok {
+			return limit
+		}
+
+		best, limit := "", 0
+		for prefix, value := range limits {
+			if strings.HasPrefix(model, prefix) && len(prefix) > len(best) {
+				best, limit = prefix, value
+			}
+		}
+		if best != "" {
+			return limit
+		}
+	}
+	return defaultContextLimit
+}
@@ -2,8 +2,11 @@ package llm
 
 import (
 	"context"
-	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"net/http"
+	"strings"
 
 	"github.com/soralabs/zen/logger"
 
@@ -11,11 +14,17 @@ import (
 	"github.com/sashabaranov/go-openai/jsonschema"
 )
 
+// openAIEmbeddingBatchSize is the maximum number of inputs per OpenAI embedding request
+const openAIEmbeddingBatchSize = 2048
+
 type OpenAIProvider struct {
-	client *openai.Client
-	models map[ModelType]string
-	logger *logger.Logger
-	roles  map[Role]string
+	client         *openai.Client
+	models         map[ModelType]string
+	embeddingModel openai.EmbeddingModel
+	embeddingDims  int
+	embeddingBatch int
+	logger         *logger.Logger
+	roles          map[Role]string
 }
 
 // NewOpenAIProvider creates and returns a new OpenAIProvider instance,
@@ -24,11 +33,7 @@ func NewOpenAIProvider(config Config) *OpenAIProvider {
 	// Default model mapping if not provided
 	models := config.DefaultProvider.ModelConfig
 	if models == nil {
-		models = map[ModelType]string{
-			ModelTypeFast:     openai.GPT4oMini,
-			ModelTypeDefault:  openai.GPT4oMini,
-			ModelTypeAdvanced: openai.GPT4o,
-		}
+		models = defaultModels[ProviderOpenAI]
 	}
 
 	// Role mapping
@@ -39,33 +44,69 @@ func NewOpenAIProvider(config Config) *OpenAIProvider {
 		RoleTool:      openai.ChatMessageRoleTool,
 	}
 
+	clientConfig := openai.DefaultConfig(config.DefaultProvider.APIKey)
+	if config.DefaultProvider.BaseURL != "" {
+		clientConfig.BaseURL = config.DefaultProvider.BaseURL
+	}
+	if len(config.DefaultProvider.Headers) > 0 {
+		clientConfig.HTTPClient = &http.Client{
+			Transport: &headerTransport{
+				headers: config.DefaultProvider.Headers,
+				base:    http.DefaultTransport,
+			},
+		}
+	}
+
+	embeddingModel := openai.EmbeddingModel(embeddingModelName(config.DefaultProvider))
+
+	embeddingBatch := config.DefaultProvider.EmbeddingBatchSize
+	if embeddingBatch <= 0 {
+		embeddingBatch = openAIEmbeddingBatchSize
+	}
+
 	return &OpenAIProvider{
-		client: openai.NewClient(config.DefaultProvider.APIKey),
-		models: models,
-		logger: config.Logger,
-		roles:  roles,
+		client:         openai.NewClientWithConfig(clientConfig),
+		models:         models,
+		embeddingModel: embeddingModel,
+		embeddingDims:  config.DefaultProvider.EmbeddingDimensions,
+		embeddingBatch: embeddingBatch,
+		logger:         config.Logger,
+		roles:          roles,
 	}
 }
 
-// GenerateCompletion sends a conversation to the OpenAI ChatCompletion API
-// and returns the model's text completion.
-func (p *OpenAIProvider) GenerateCompletion(ctx context.Context, req CompletionRequest) (Message, error) {
-	functions := make([]openai.FunctionDefinition, len(req.Tools))
-	for i, tool := range req.Tools {
-		schema := tool.GetSchema()
-		functions[i] = openai.FunctionDefinition{
-			Name:        tool.GetName(),
-			Description: tool.GetDescription(),
-			Parameters:  schema.Parameters,
-		}
+// headerTransport adds a fixed set of headers to every outgoing request
+type headerTransport struct {
+	headers map[string]string
+	base    http.RoundTripper
+}
+
+func (t *headerTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+	for key, value := range t.headers {
+		req.Header.Set(key, value)
 	}
+	return t.base.RoundTrip(req)
+}
 
-	resp, err := p.client.CreateChatCompletion(ctx, openai.ChatCompletionRequest{
-		Model:       p.getModel(req.ModelType),
-		Messages:    p.convertMessages(req.Messages),
-		Temperature: req.Temperature,
-		Functions:   functions,
-	})
+// Capabilities reports full chat, vision and embedding support
+func (p *OpenAIProvider) Capabilities() Capabilities {
+	return Capabilities{
+		Completion:       true,
+		Tools:            true,
+		StructuredOutput: true,
+		Embeddings:       true,
+		Vision:           true,
+		Streaming:        true,
+		MaxContextTokens: maxContextLimit(p.models, nil),
+	}
+}
+
+// GenerateCompletion sends a conversation to the OpenAI ChatCompletion API
+// and returns the model's text completion or requested tool calls.
+func (p *OpenAIProvider) GenerateCompletion(ctx context.Context, req CompletionRequest) (Message, error) {
+	chatReq := p.buildChatRequest(req)
+	resp, err := p.client.CreateChatCompletion(ctx, chatReq)
 	if err != nil {
 		return Message{}, fmt.Errorf("OpenAI API error: %w", err)
 	}
@@ -74,63 +115,121 @@ func (p *OpenAIProvider) GenerateCompletion(ctx context.Context, req CompletionR
 		return Message{}, fmt.Errorf("no completion returned")
 	}
 
-	// Handle function calls if present
-	if resp.Choices[0].Message.FunctionCall != nil {
-		call := resp.Choices[0].Message.FunctionCall
-		for _, tool := range req.Tools {
-			if tool.GetName() == call.Name {
-				// Execute the tool
-				result, err := tool.Execute(ctx, json.RawMessage(call.Arguments))
-				if err != nil {
-					return Message{}, fmt.Errorf("tool execution error: %w", err)
-				}
+	usage := p.convertUsage(chatReq.Model, resp.Usage)
+	message := Message{
+		Role:    RoleAssistant,
+		Content: resp.Choices[0].Message.Content,
+		Usage:   &usage,
+	}
+	for _, call := range resp.Choices[0].Message.ToolCalls {
+		message.ToolCalls = append(message.ToolCalls, ToolCall{
+			ID:        call.ID,
+			Name:      call.Function.Name,
+			Arguments: call.Function.Arguments,
+		})
+	}
 
-				// Create a new message array with the tool result
-				toolResultMessages := append(req.Messages,
-					Message{
-						Role:    RoleAssistant,
-						Content: "",
-						ToolCall: &ToolCall{
-							Name:      call.Name,
-							Arguments: string(call.Arguments),
-						},
-					},
-					Message{
-						Role:    RoleTool,
-						Content: string(result),
-						Name:    tool.GetName(),
-					},
-				)
-
-				// Make a follow-up completion request with the tool result
-				followUpReq := CompletionRequest{
-					Messages:    toolResultMessages,
-					ModelType:   req.ModelType,
-					Temperature: req.Temperature,
-					Tools:       req.Tools,
-				}
+	return message, nil
+}
+
+// GenerateCompletionStream sends a conversation to the OpenAI ChatCompletion API
+// and streams the completion back as it is generated. Requested tool calls
+// are returned on the final message.
+func (p *OpenAIProvider) GenerateCompletionStream(ctx context.Context, req CompletionRequest) (<-chan StreamEvent, error) {
+	chatReq := p.buildChatRequest(req)
+	chatReq.StreamOptions = &openai.StreamOptions{IncludeUsage: true}
+	stream, err := p.client.CreateChatCompletionStream(ctx, chatReq)
+	if err != nil {
+		return nil, fmt.Errorf("OpenAI API error: %w", err)
+	}
+
+	events := make(chan StreamEvent)
+	go func() {
+		defer close(events)
+		defer stream.Close()
 
-				return p.GenerateCompletion(ctx, followUpReq)
+		var content strings.Builder
+		var calls []ToolCall
+		usage := Usage{Provider: ProviderOpenAI, Model: chatReq.Model}
+		for {
+			resp, err := stream.Recv()
+			if errors.Is(err, io.EOF) {
+				break
 			}
+			if err != nil {
+				sendStreamEvent(ctx, events, StreamEvent{Type: StreamEventError, Err: fmt.Errorf("OpenAI stream error: %w", err)})
+				return
+			}
+			if resp.Usage != nil {
+				usage = p.convertUsage(chatReq.Model, *resp.Usage)
+			}
+			if len(resp.Choices) == 0 {
+				continue
+			}
+
+			delta := resp.Choices[0].Delta
+			if delta.Content != "" {
+				content.WriteString(delta.Content)
+				if !sendStreamEvent(ctx, events, StreamEvent{Type: StreamEventContent, Content: delta.Content}) {
+					return
+				}
+			}
+			for _, callDelta := range delta.ToolCalls {
+				calls = mergeToolCallDelta(calls, callDelta.Index, callDelta.ID, callDelta.Function.Name, callDelta.Function.Arguments)
+			}
+		}
+
+		message := &Message{
+			Role:      RoleAssistant,
+			Content:   content.String(),
+			ToolCalls: calls,
+			Usage:     &usage,
 		}
-		return Message{}, fmt.Errorf("function %s not found", call.Name)
+		sendStreamEvent(ctx, events, StreamEvent{Type: StreamEventDone, Message: message})
+	}()
+
+	return events, nil
+}
+
+// buildChatRequest converts a completion request into an OpenAI chat request
+func (p *OpenAIProvider) buildChatRequest(req CompletionRequest) openai.ChatCompletionRequest {
+	var tools []openai.Tool
+	for _, tool := range req.Tools {
+		schema := tool.GetSchema()
+		tools = append(tools, openai.Tool{
+			Type: openai.ToolTypeFunction,
+			Function: &openai.FunctionDefinition{
+				Name:        tool.GetName(),
+				Description: tool.GetDescription(),
+				Parameters:  schema.Parameters,
+			},
+		})
 	}
 
-	return Message{
-		Role:    RoleAssistant,
-		Content: resp.Choices[0].Message.Content,
-	}, nil
+	return openai.ChatCompletionRequest{
+		Model:       p.getModel(req.ModelType),
+		Messages:    p.convertMessages(req.Messages),
+		Temperature: req.Temperature,
+		Tools:       tools,
+	}
 }
 
 // GenerateStructuredOutput prompts the OpenAI API to return JSON data conforming
-func (p *OpenAIProvider) GenerateStructuredOutput(ctx context.Context, req StructuredOutputRequest, result interface{}) error {
+func (p *OpenAIProvider) GenerateStructuredOutput(ctx context.Context, req StructuredOutputRequest, result interface{}) (Usage, error) {
 	schema, err := jsonschema.GenerateSchemaForType(result)
 	if err != nil {
-		return fmt.Errorf("failed to generate schema: %w", err)
+		return Usage{}, fmt.Errorf("failed to generate schema: %w", err)
+	}
+	// The API schema omits jsonschema tag constraints, so replies are also
+	// checked against the full schema
+	validation, err := GenerateSchema(result)
+	if err != nil {
+		return Usage{}, fmt.Errorf("failed to generate schema: %w", err)
 	}
 
+	model := p.getModel(req.ModelType)
 	resp, err := p.client.CreateChatCompletion(ctx, openai.ChatCompletionRequest{
-		Model:    p.getModel(req.ModelType),
+		Model:    model,
 		Messages: p.convertMessages(req.Messages),
 		ResponseFormat: &openai.ChatCompletionResponseFormat{
 			Type: openai.ChatCompletionResponseFormatTypeJSONSchema,
@@ -143,31 +242,76 @@ func (p *OpenAIProvider) GenerateStructuredOutput(ctx context.Context, req Struc
 		Temperature: req.Temperature,
 	})
 	if err != nil {
-		return fmt.Errorf("OpenAI API error: %w", err)
+		return Usage{}, fmt.Errorf("OpenAI API error: %w", err)
 	}
 
+	usage := p.convertUsage(model, resp.Usage)
 	if len(resp.Choices) == 0 {
-		return fmt.Errorf("no completion returned")
+		return usage, fmt.Errorf("no completion returned")
 	}
 
-	return schema.Unmarshal(resp.Choices[0].Message.Content, result)
+	return usage, decodeStructuredOutput(resp.Choices[0].Message.Content, validation, result)
 }
 
-// EmbedText generates an embedding vector for the given text using the Ada V2 model
-func (p *OpenAIProvider) EmbedText(ctx context.Context, text string) ([]float32, error) {
-	resp, err := p.client.CreateEmbeddings(ctx, openai.EmbeddingRequest{
-		Input: []string{text},
-		Model: openai.AdaEmbeddingV2,
+// EmbedText generates an embedding vector for the given text using the
+// configured embedding model (Ada V2 by default)
+func (p *OpenAIProvider) EmbedText(ctx context.Context, text string) ([]float32, Usage, error) {
+	return firstEmbedding(p.EmbedTexts(ctx, []string{text}))
+}
+
+// embeddingBatchSize implements embeddingBatcher for the rate limiter
+func (p *OpenAIProvider) embeddingBatchSize() int {
+	return p.embeddingBatch
+}
+
+// EmbedTexts generates embedding vectors for the given texts, sending at most
+// the configured batch size (2048 inputs by default) per request
+func (p *OpenAIProvider) EmbedTexts(ctx context.Context, texts []string) ([][]float32, Usage, error) {
+	return embedInBatches(texts, p.embeddingBatch, func(batch []string) ([][]float32, Usage, error) {
+		resp, err := p.client.CreateEmbeddings(ctx, openai.EmbeddingRequest{
+			Input:      batch,
+			Model:      p.embeddingModel,
+			Dimensions: p.embeddingDims,
+		})
+		if err != nil {
+			return nil, Usage{}, fmt.Errorf("OpenAI API error: %w", err)
+		}
+
+		usage := Usage{
+			Provider:     ProviderOpenAI,
+			Model:        string(p.embeddingModel),
+			PromptTokens: resp.Usage.PromptTokens,
+			TotalTokens:  resp.Usage.TotalTokens,
+		}
+		if len(resp.Data) != len(batch) {
+			return nil, usage, fmt.Errorf("expected %d embeddings, got %d", len(batch), len(resp.Data))
+		}
+
+		embeddings := make([][]float32, len(batch))
+		for i, data := range resp.Data {
+			index := data.Index
+			if index < 0 || index >= len(batch) {
+				index = i
+			}
+			embeddings[index] = data.Embedding
+		}
+		return embeddings, usage, nil
 	})
-	if err != nil {
-		return nil, fmt.Errorf("OpenAI API error: %w", err)
-	}
+}
 
-	if len(resp.Data) == 0 {
-		return nil, fmt.Errorf("no embedding returned")
+// convertUsage maps OpenAI token usage to the internal usage format
+func (p *OpenAIProvider) convertUsage(model string, usage openai.Usage) Usage {
+	converted := Usage{
+		Provider:         ProviderOpenAI,
+		Model:            model,
+		PromptTokens:     usage.PromptTokens,
+		CompletionTokens: usage.CompletionTokens,
+		TotalTokens:      usage.TotalTokens,
 	}
-
-	return resp.Data[0].Embedding, nil
+	if usage.CompletionTokensDetails != nil {
+		converted.ReasoningTokens = usage.CompletionTokensDetails.ReasoningTokens
+	}
+	return converted
 }
 
 // getModel returns the OpenAI model identifier for the given model type.
@@ -182,22 +326,56 @@ func (p *OpenAIProvider) getModel(modelType ModelType) string {
 // convertMessages transforms internal message format to OpenAI API format.
 func (p *OpenAIProvider) convertMessages(messages []Message) []openai.ChatCompletionMessage {
 	converted := make([]openai.ChatCompletionMessage, len(messages))
-	for i, msg := range messages {
+	for i, msg := range pairToolCalls(messages) {
 		converted[i] = openai.ChatCompletionMessage{
 			Role:    p.mapRole(msg.Role),
 			Content: msg.Content,
 			Name:    msg.Name,
 		}
-		if msg.ToolCall != nil {
-			converted[i].FunctionCall = &openai.FunctionCall{
-				Name:      msg.ToolCall.Name,
-				Arguments: msg.ToolCall.Arguments,
-			}
+		// Content and MultiContent are mutually exclusive
+		if len(msg.Parts) > 0 {
+			converted[i].Content = ""
+			converted[i].MultiContent = p.convertParts(msg)
+		}
+		for _, call := range msg.ToolCalls {
+			converted[i].ToolCalls = append(converted[i].ToolCalls, openai.ToolCall{
+				ID:   call.ID,
+				Type: openai.ToolTypeFunction,
+				Function: openai.FunctionCall{
+					Name:      call.Name,
+					Arguments: call.Arguments,
+				},
+			})
+		}
+		// Tool results are identified by their call ID rather than a name
+		if msg.Role == RoleTool {
+			converted[i].Name = ""
+			converted[i].ToolCallID = msg.ToolCallID
 		}
 	}
 	return converted
 }
 
+// convertParts maps the content and parts of a message to OpenAI content parts
+func (p *OpenAIProvider) convertParts(msg Message) []openai.ChatMessagePart {
+	var parts []openai.ChatMessagePart
+	if msg.Content != "" {
+		parts = append(parts, openai.ChatMessagePart{Type: openai.ChatMessagePartTypeText, Text: msg.Content})
+	}
+	for _, part := range msg.Parts {
+		switch part.Type {
+		case ContentPartText:
+			parts = append(parts, openai.ChatMessagePart{Type: openai.ChatMessagePartTypeText, Text: part.Text})
+		case ContentPartImage:
+			parts = append(parts, openai.ChatMessagePart{
+				Type:     openai.ChatMessagePartTypeImageURL,
+				ImageURL: &openai.ChatMessageImageURL{URL: part.dataURL()},
+			})
+		}
+	}
+	return parts
+}
+
 // mapRole converts internal role types to OpenAI API role strings.
 func (p *OpenAIProvider) mapRole(role Role) string {
 	if mappedRole, ok := p.roles[role]; ok {
@@ -0,0 +1,98 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+
+	toolkit "github.com/soralabs/toolkit/go"
+)
+
+func TestOpenAIToolCallRoundTrip(t *testing.T) {
+	server := newFakeServer(t, func(w http.ResponseWriter, req capturedRequest) {
+		messages := req.Body["messages"].([]interface{})
+		if len(messages) == 1 {
+			writeJSON(w, `{
+				"model": "gpt-4o",
+				"choices": [{
+					"message": {
+						"role": "assistant",
+						"tool_calls": [
+							{"id": "call_1", "type": "function", "function": {"name": "search", "arguments": "{\"query\":\"go\"}"}},
+							{"id": "call_2", "type": "function", "function": {"name": "weather", "arguments": "{\"query\":\"Berlin\"}"}}
+						]
+					},
+					"finish_reason": "tool_calls"
+				}]
+			}`)
+			return
+		}
+		writeJSON(w, `{"model": "gpt-4o", "choices": [{"message": {"role": "assistant", "content": "Go has 3 hits, the weather is unknown."}, "finish_reason": "stop"}]}`)
+	})
+
+	client, err := NewLLMClient(Config{
+		DefaultProvider:   ProviderConfig{Type: ProviderOpenAI, APIKey: "test-key", BaseURL: server.URL},
+		EmbeddingProvider: &ProviderConfig{Type: ProviderHashing},
+		Context:           context.Background(),
+	})
+	if err != nil {
+		t.Fatalf("NewLLMClient: %v", err)
+	}
+	search := &fakeTool{name: "search", result: `{"hits":3}`}
+	weather := &fakeTool{name: "weather", err: errors.New("quota exceeded")}
+
+	result, err := client.GenerateCompletionWithTrace(CompletionRequest{
+		Messages: []Message{NewUserMessage("Search for go and check the weather")},
+		Tools:    []toolkit.Tool{search, weather},
+	})
+	if err != nil {
+		t.Fatalf("GenerateCompletionWithTrace: %v", err)
+	}
+	if result.Message.Content != "Go has 3 hits, the weather is unknown." {
+		t.Errorf("content = %q", result.Message.Content)
+	}
+	if len(result.Trace) != 2 || result.Trace[1].Error == "" {
+		t.Errorf("trace = %+v, want the weather call failed", result.Trace)
+	}
+
+	// The follow-up request replays both calls and answers each by ID,
+	// reporting the failed one as an error instead of aborting
+	if server.count() != 2 {
+		t.Fatalf("requests = %d, want 2", server.count())
+	}
+	req := server.last(t)
+	if req.Path != "/chat/completions" {
+		t.Errorf("path = %s, want /chat/completions", req.Path)
+	}
+	if got := req.Header.Get("Authorization"); got != "Bearer test-key" {
+		t.Errorf("Authorization = %q", got)
+	}
+	messages := req.Body["messages"].([]interface{})
+	if len(messages) != 4 {
+		t.Fatalf("messages = %v, want user, assistant and two tool results", messages)
+	}
+	for i, id := range []string{"call_1", "call_2"} {
+		if got := jsonPath(t, messages, fmt.Sprintf("1.tool_calls.%d.id", i)); got != id {
+			t.Errorf("assistant tool call %d id = %v, want %s", i, got, id)
+		}
+	}
+	if got := jsonPath(t, messages, "1.tool_calls.1.function.arguments"); got != `{"query":"Berlin"}` {
+		t.Errorf("assistant tool call arguments = %v", got)
+	}
+
+	found := messages[2].(map[string]interface{})
+	if found["role"] != "tool" || found["tool_call_id"] != "call_1" || found["content"] != `{"hits":3}` {
+		t.Errorf("tool message = %v", found)
+	}
+	failed := messages[3].(map[string]interface{})
+	if failed["role"] != "tool" || failed["tool_call_id"] != "call_2" || failed["content"] != "error: tool execution error: quota exceeded" {
+		t.Errorf("tool error message = %v", failed)
+	}
+	for _, msg := range []map[string]interface{}{found, failed} {
+		if _, ok := msg["name"]; ok {
+			t.Errorf("tool message has a name: %v", msg)
+		}
+	}
+}
@@ -0,0 +1,89 @@
+package llm
+
+import "strings"
+
+// ModelPrice holds the price of a model in USD per million tokens
+type ModelPrice struct {
+	PromptPerMillion     float64
+	CompletionPerMillion float64
+}
+
+// PriceTable maps model names to their prices
+type PriceTable map[string]ModelPrice
+
+// DefaultPrices holds list prices for the default models of the built-in providers.
+// Override or extend them through Config.Prices.
+var DefaultPrices = PriceTable{
+	// OpenAI
+	"gpt-4o":                 {PromptPerMillion: 2.50, CompletionPerMillion: 10.00},
+	"gpt-4o-mini":            {PromptPerMillion: 0.15, CompletionPerMillion: 0.60},
+	"o1":                     {PromptPerMillion: 15.00, CompletionPerMillion: 60.00},
+	"o1-mini":                {PromptPerMillion: 3.00, CompletionPerMillion: 12.00},
+	"text-embedding-ada-002": {PromptPerMillion: 0.10},
+	"text-embedding-3-small": {PromptPerMillion: 0.02},
+	"text-embedding-3-large": {PromptPerMillion: 0.13},
+
+	// Deepseek
+	"deepseek-chat":     {PromptPerMillion: 0.27, CompletionPerMillion: 1.10},
+	"deepseek-reasoner": {PromptPerMillion: 0.55, CompletionPerMillion: 2.19},
+
+	// Anthropic
+	"claude-3-5-haiku-latest":  {PromptPerMillion: 0.80, CompletionPerMillion: 4.00},
+	"claude-3-5-sonnet-latest": {PromptPerMillion: 3.00, CompletionPerMillion: 15.00},
+
+	// Gemini
+	"gemini-2.0-flash-lite": {PromptPerMillion: 0.075, CompletionPerMillion: 0.30},
+	"gemini-2.0-flash":      {PromptPerMillion: 0.10, CompletionPerMillion: 0.40},
+	"gemini-2.5-pro":        {PromptPerMillion: 1.25, CompletionPerMillion: 10.00},
+}
+
+// Cost returns the cost in USD of the given usage. Models are matched exactly
+// first and then by the longest known prefix, so dated snapshots such as
+// "gpt-4o-2024-08-06" use the price of "gpt-4o". Unknown models cost nothing.
+func (t PriceTable) Cost(usage Usage) float64 {
+	price, ok := t.lookup(usage.Model)
+	if !ok {
+		return 0
+	}
+	return float64(usage.PromptTokens)*price.PromptPerMillion/1e6 +
+		float64(usage.CompletionTokens)*price.CompletionPerMillion/1e6
+}
+
+// price fills in the cost of usage that is not priced yet. Sums of priced
+// usages keep their cost, as their model is only that of the first one.
+func (t PriceTable) price(usage Usage) Usage {
+	if usage.Cost == 0 {
+		usage.Cost = t.Cost(usage)
+	}
+	return usage
+}
+
+// lookup finds the price of a model by exact name or longest prefix
+func (t PriceTable) lookup(model string) (ModelPrice, bool) {
+	if price, ok := t[model]; ok {
+		return price, true
+	}
+
+	var best string
+	for name := range t {
+		if strings.HasPrefix(model, name) && len(name) > len(best) {
+			best = name
+		}
+	}
+	if best == "" {
+		return ModelPrice{}, false
+	}
+	return t[best], true
+}
+
+// mergePrices returns the default prices overridden by the given prices
+func mergePrices(overrides PriceTable) PriceTable {
+	prices := make(PriceTable, len(DefaultPrices)+len(overrides))
+	for model, price := range DefaultPrices {
+		prices[model] = price
+	}
+	for model, price := range overrides {
+		prices[model] = price
+	}
+	return prices
+}
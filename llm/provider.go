@@ -7,9 +7,15 @@ import (
 )
 
 type Provider interface {
+	// Capabilities reports the features the provider supports, which the
+	// client checks its configuration and routes calls against
+	Capabilities() Capabilities
 	GenerateCompletion(ctx context.Context, req CompletionRequest) (Message, error)
-	GenerateStructuredOutput(ctx context.Context, req StructuredOutputRequest, result interface{}) error
-	EmbedText(ctx context.Context, text string) ([]float32, error)
+	GenerateCompletionStream(ctx context.Context, req CompletionRequest) (<-chan StreamEvent, error)
+	GenerateStructuredOutput(ctx context.Context, req StructuredOutputRequest, result interface{}) (Usage, error)
+	EmbedText(ctx context.Context, text string) ([]float32, Usage, error)
+	// EmbedTexts embeds all texts, splitting them into requests of the provider's batch limit
+	EmbedTexts(ctx context.Context, texts []string) ([][]float32, Usage, error)
 }
 
 type CompletionRequest struct {
@@ -25,4 +31,9 @@ type StructuredOutputRequest struct {
 	Temperature  float32
 	SchemaName   string
 	StrictSchema bool
+	// Validator checks the decoded result beyond its schema, e.g. that
+	// referenced IDs exist. Its error is sent back to the model for a repair.
+	Validator func(result interface{}) error
+	// Cascade is filled with the stages of the call if it is cascaded, see CascadeConfig
+	Cascade *CascadeTrace
 }
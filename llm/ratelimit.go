@@ -0,0 +1,325 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// ErrRateLimited is returned when a call exceeds a client-side rate limit
+// with the RateLimitFailFast policy. It is not retried, but the client still
+// falls back to the next configured provider.
+var ErrRateLimited = errors.New("client-side rate limit exceeded")
+
+// RateLimitPolicy decides what happens to calls that exceed a rate limit
+type RateLimitPolicy string
+
+const (
+	RateLimitWait     RateLimitPolicy = "wait"      // Queue the call until capacity is available
+	RateLimitFailFast RateLimitPolicy = "fail_fast" // Return ErrRateLimited immediately
+)
+
+// RateLimit configures token-bucket limits for a provider or model type.
+// Zero values leave the respective dimension unlimited.
+type RateLimit struct {
+	RequestsPerMinute int
+	TokensPerMinute   int             // Prompt tokens are estimated up front and settled against reported usage
+	Policy            RateLimitPolicy // Defaults to RateLimitWait
+}
+
+// tokenBucket refills continuously up to its capacity. Waiting callers take
+// tokens on credit, which keeps queued calls in arrival order.
+type tokenBucket struct {
+	mu       sync.Mutex
+	capacity float64
+	tokens   float64
+	rate     float64 // tokens per second
+	last     time.Time
+}
+
+func newTokenBucket(perMinute int) *tokenBucket {
+	if perMinute <= 0 {
+		return nil
+	}
+	return &tokenBucket{
+		capacity: float64(perMinute),
+		tokens:   float64(perMinute),
+		rate:     float64(perMinute) / 60,
+		last:     time.Now(),
+	}
+}
+
+func (b *tokenBucket) refill() {
+	now := time.Now()
+	b.tokens += now.Sub(b.last).Seconds() * b.rate
+	if b.tokens > b.capacity {
+		b.tokens = b.capacity
+	}
+	b.last = now
+}
+
+// take removes n tokens and returns how long the caller has to wait until
+// they are covered. Without credit it fails instead of returning a wait.
+// Requests larger than the capacity are clamped so they can still pass.
+func (b *tokenBucket) take(n float64, credit bool) (time.Duration, bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.refill()
+
+	if n > b.capacity {
+		n = b.capacity
+	}
+	if b.tokens >= n {
+		b.tokens -= n
+		return 0, true
+	}
+	if !credit {
+		return 0, false
+	}
+	wait := time.Duration((n - b.tokens) / b.rate * float64(time.Second))
+	b.tokens -= n
+	return wait, true
+}
+
+// adjust returns n tokens to the bucket, or takes them if n is negative
+func (b *tokenBucket) adjust(n float64) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.refill()
+
+	b.tokens += n
+	if b.tokens > b.capacity {
+		b.tokens = b.capacity
+	}
+}
+
+// rateLimiter combines the request and token buckets of one RateLimit
+type rateLimiter struct {
+	requests *tokenBucket
+	tokens   *tokenBucket
+	policy   RateLimitPolicy
+}
+
+func newRateLimiter(limit RateLimit) *rateLimiter {
+	return &rateLimiter{
+		requests: newTokenBucket(limit.RequestsPerMinute),
+		tokens:   newTokenBucket(limit.TokensPerMinute),
+		policy:   limit.Policy,
+	}
+}
+
+// acquire reserves one request and the estimated tokens, waiting for
+// capacity unless the policy is RateLimitFailFast
+func (l *rateLimiter) acquire(ctx context.Context, tokens int) error {
+	credit := l.policy != RateLimitFailFast
+
+	var wait time.Duration
+	if l.requests != nil {
+		d, ok := l.requests.take(1, credit)
+		if !ok {
+			return ErrRateLimited
+		}
+		wait = d
+	}
+	if l.tokens != nil && tokens > 0 {
+		d, ok := l.tokens.take(float64(tokens), credit)
+		if !ok {
+			if l.requests != nil {
+				l.requests.adjust(1)
+			}
+			return ErrRateLimited
+		}
+		if d > wait {
+			wait = d
+		}
+	}
+	if wait == 0 {
+		return nil
+	}
+
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		l.release(tokens)
+		return ctx.Err()
+	}
+}
+
+// release returns a reservation that was not used
+func (l *rateLimiter) release(tokens int) {
+	if l.requests != nil {
+		l.requests.adjust(1)
+	}
+	if l.tokens != nil && tokens > 0 {
+		l.tokens.adjust(float64(tokens))
+	}
+}
+
+// settle corrects the token reservation with the usage the provider reported
+func (l *rateLimiter) settle(estimated int, usage Usage) {
+	if l.tokens == nil || usage.TotalTokens == 0 {
+		return
+	}
+	l.tokens.adjust(float64(estimated - usage.TotalTokens))
+}
+
+// rateLimitedProvider applies client-side rate limits to a provider
+type rateLimitedProvider struct {
+	Provider
+	limiter       *rateLimiter
+	modelLimiters map[ModelType]*rateLimiter
+}
+
+// newRateLimitedProvider wraps the provider if the config sets any rate limits
+func newRateLimitedProvider(provider Provider, config ProviderConfig) Provider {
+	if config.RateLimit == nil && len(config.ModelRateLimits) == 0 {
+		return provider
+	}
+
+	limited := &rateLimitedProvider{
+		Provider:      provider,
+		modelLimiters: make(map[ModelType]*rateLimiter, len(config.ModelRateLimits)),
+	}
+	if config.RateLimit != nil {
+		limited.limiter = newRateLimiter(*config.RateLimit)
+	}
+	for modelType, limit := range config.ModelRateLimits {
+		limited.modelLimiters[modelType] = newRateLimiter(limit)
+	}
+	return limited
+}
+
+// limitersFor returns the limiters that apply to a call with the given model type.
+// Embeddings have no model type and only use the provider limit.
+func (p *rateLimitedProvider) limitersFor(modelType ModelType, embedding bool) []*rateLimiter {
+	var limiters []*rateLimiter
+	if p.limiter != nil {
+		limiters = append(limiters, p.limiter)
+	}
+	if embedding {
+		return limiters
+	}
+	if modelType == "" {
+		modelType = ModelTypeDefault
+	}
+	if limiter, ok := p.modelLimiters[modelType]; ok {
+		limiters = append(limiters, limiter)
+	}
+	return limiters
+}
+
+// acquire reserves capacity on every limiter, releasing earlier reservations if one fails
+func (p *rateLimitedProvider) acquire(ctx context.Context, limiters []*rateLimiter, tokens int) error {
+	for i, limiter := range limiters {
+		if err := limiter.acquire(ctx, tokens); err != nil {
+			for _, acquired := range limiters[:i] {
+				acquired.release(tokens)
+			}
+			return err
+		}
+	}
+	return nil
+}
+
+func settleLimiters(limiters []*rateLimiter, estimated int, usage Usage) {
+	for _, limiter := range limiters {
+		limiter.settle(estimated, usage)
+	}
+}
+
+func (p *rateLimitedProvider) GenerateCompletion(ctx context.Context, req CompletionRequest) (Message, error) {
+	limiters := p.limitersFor(req.ModelType, false)
+	estimated := EstimateTokens(req.Messages)
+	if err := p.acquire(ctx, limiters, estimated); err != nil {
+		return Message{}, err
+	}
+
+	message, err := p.Provider.GenerateCompletion(ctx, req)
+	if message.Usage != nil {
+		settleLimiters(limiters, estimated, *message.Usage)
+	}
+	return message, err
+}
+
+func (p *rateLimitedProvider) GenerateCompletionStream(ctx context.Context, req CompletionRequest) (<-chan StreamEvent, error) {
+	limiters := p.limitersFor(req.ModelType, false)
+	estimated := EstimateTokens(req.Messages)
+	if err := p.acquire(ctx, limiters, estimated); err != nil {
+		return nil, err
+	}
+
+	stream, err := p.Provider.GenerateCompletionStream(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	events := make(chan StreamEvent)
+	go func() {
+		defer close(events)
+		for event := range stream {
+			if event.Type == StreamEventDone && event.Message != nil && event.Message.Usage != nil {
+				settleLimiters(limiters, estimated, *event.Message.Usage)
+			}
+			if !sendStreamEvent(ctx, events, event) {
+				return
+			}
+		}
+	}()
+	return events, nil
+}
+
+func (p *rateLimitedProvider) GenerateStructuredOutput(ctx context.Context, req StructuredOutputRequest, result interface{}) (Usage, error) {
+	limiters := p.limitersFor(req.ModelType, false)
+	estimated := EstimateTokens(req.Messages)
+	if err := p.acquire(ctx, limiters, estimated); err != nil {
+		return Usage{}, err
+	}
+
+	usage, err := p.Provider.GenerateStructuredOutput(ctx, req, result)
+	settleLimiters(limiters, estimated, usage)
+	return usage, err
+}
+
+func (p *rateLimitedProvider) EmbedText(ctx context.Context, text string) ([]float32, Usage, error) {
+	limiters := p.limitersFor("", true)
+	estimated := estimateTextTokens(text)
+	if err := p.acquire(ctx, limiters, estimated); err != nil {
+		return nil, Usage{}, err
+	}
+
+	embedding, usage, err := p.Provider.EmbedText(ctx, text)
+	settleLimiters(limiters, estimated, usage)
+	return embedding, usage, err
+}
+
+// EmbedTexts takes one request for every batch the provider sends, with the
+// tokens of its texts
+func (p *rateLimitedProvider) EmbedTexts(ctx context.Context, texts []string) ([][]float32, Usage, error) {
+	batcher, ok := p.Provider.(embeddingBatcher)
+	if !ok {
+		return p.embedBatch(ctx, texts)
+	}
+	return embedInBatches(texts, batcher.embeddingBatchSize(), func(batch []string) ([][]float32, Usage, error) {
+		return p.embedBatch(ctx, batch)
+	})
+}
+
+// embedBatch embeds texts the provider sends in a single request
+func (p *rateLimitedProvider) embedBatch(ctx context.Context, texts []string) ([][]float32, Usage, error) {
+	limiters := p.limitersFor("", true)
+	estimated := 0
+	for _, text := range texts {
+		estimated += estimateTextTokens(text)
+	}
+	if err := p.acquire(ctx, limiters, estimated); err != nil {
+		return nil, Usage{}, err
+	}
+
+	embeddings, usage, err := p.Provider.EmbedTexts(ctx, texts)
+	settleLimiters(limiters, estimated, usage)
+	return embeddings, usage, err
+}
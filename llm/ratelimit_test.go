@@ -0,0 +1,178 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+// batchingProvider embeds texts in batches like the HTTP providers, counting
+// the requests it sends
+type batchingProvider struct {
+	funcProvider
+	batchSize int
+
+	mu       sync.Mutex
+	requests int
+}
+
+func newBatchingProvider(batchSize int) *batchingProvider {
+	p := &batchingProvider{batchSize: batchSize}
+	p.caps = allCapabilities
+	p.embed = func(ctx context.Context, texts []string) ([][]float32, Usage, error) {
+		return embedInBatches(texts, p.batchSize, func(batch []string) ([][]float32, Usage, error) {
+			p.mu.Lock()
+			p.requests++
+			p.mu.Unlock()
+			return make([][]float32, len(batch)), Usage{TotalTokens: len(batch)}, nil
+		})
+	}
+	return p
+}
+
+func (p *batchingProvider) embeddingBatchSize() int { return p.batchSize }
+
+func numberedTexts(n int) []string {
+	texts := make([]string, n)
+	for i := range texts {
+		texts[i] = fmt.Sprintf("text %d", i)
+	}
+	return texts
+}
+
+func TestRateLimitFailFast(t *testing.T) {
+	provider := newRateLimitedProvider(
+		&funcProvider{caps: allCapabilities, complete: func(ctx context.Context, req CompletionRequest) (Message, error) {
+			return NewAssistantMessage("ok"), nil
+		}},
+		ProviderConfig{RateLimit: &RateLimit{RequestsPerMinute: 2, Policy: RateLimitFailFast}},
+	)
+	req := CompletionRequest{Messages: []Message{NewUserMessage("hi")}}
+	for i := 0; i < 2; i++ {
+		if _, err := provider.GenerateCompletion(context.Background(), req); err != nil {
+			t.Fatalf("call %d: %v", i+1, err)
+		}
+	}
+	if _, err := provider.GenerateCompletion(context.Background(), req); !errors.Is(err, ErrRateLimited) {
+		t.Errorf("third call: err = %v, want ErrRateLimited", err)
+	}
+}
+
+func TestRateLimitTokenBudget(t *testing.T) {
+	limiter := newRateLimiter(RateLimit{RequestsPerMinute: 10, TokensPerMinute: 100, Policy: RateLimitFailFast})
+	if err := limiter.acquire(context.Background(), 80); err != nil {
+		t.Fatalf("acquire: %v", err)
+	}
+
+	// A call over the token budget gives its request back
+	if err := limiter.acquire(context.Background(), 50); !errors.Is(err, ErrRateLimited) {
+		t.Fatalf("acquire over budget: err = %v, want ErrRateLimited", err)
+	}
+	if tokens := limiter.requests.tokens; tokens < 8.9 || tokens > 9.1 {
+		t.Errorf("requests left = %v, want 9", tokens)
+	}
+
+	// The estimate is settled against the reported usage
+	limiter.settle(80, Usage{TotalTokens: 30})
+	if err := limiter.acquire(context.Background(), 50); err != nil {
+		t.Errorf("acquire after settling: %v", err)
+	}
+}
+
+func TestRateLimitWaitCancelled(t *testing.T) {
+	limiter := newRateLimiter(RateLimit{RequestsPerMinute: 1})
+	if err := limiter.acquire(context.Background(), 0); err != nil {
+		t.Fatalf("acquire: %v", err)
+	}
+
+	// The next request has to wait about a minute
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if err := limiter.acquire(ctx, 0); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("acquire: err = %v, want context.DeadlineExceeded", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("cancelled wait took %v", elapsed)
+	}
+	// The cancelled reservation is returned, leaving the bucket as it was
+	if tokens := limiter.requests.tokens; tokens < -0.1 || tokens > 0.1 {
+		t.Errorf("requests left = %v, want 0", tokens)
+	}
+}
+
+func TestModelRateLimits(t *testing.T) {
+	provider := newRateLimitedProvider(
+		&funcProvider{caps: allCapabilities, complete: func(ctx context.Context, req CompletionRequest) (Message, error) {
+			return NewAssistantMessage("ok"), nil
+		}},
+		ProviderConfig{ModelRateLimits: map[ModelType]RateLimit{
+			ModelTypeAdvanced: {RequestsPerMinute: 1, Policy: RateLimitFailFast},
+		}},
+	).(*rateLimitedProvider)
+
+	advanced := CompletionRequest{Messages: []Message{NewUserMessage("hi")}, ModelType: ModelTypeAdvanced}
+	if _, err := provider.GenerateCompletion(context.Background(), advanced); err != nil {
+		t.Fatalf("advanced call: %v", err)
+	}
+	if _, err := provider.GenerateCompletion(context.Background(), advanced); !errors.Is(err, ErrRateLimited) {
+		t.Errorf("second advanced call: err = %v, want ErrRateLimited", err)
+	}
+	for i := 0; i < 3; i++ {
+		if _, err := provider.GenerateCompletion(context.Background(), CompletionRequest{Messages: []Message{NewUserMessage("hi")}}); err != nil {
+			t.Errorf("default call %d: %v", i+1, err)
+		}
+	}
+	if limiters := provider.limitersFor("", true); len(limiters) != 0 {
+		t.Errorf("embeddings use %d limiters, want none", len(limiters))
+	}
+}
+
+func TestRateLimitEmbeddingBatches(t *testing.T) {
+	inner := newBatchingProvider(2)
+	provider := newRateLimitedProvider(inner, ProviderConfig{RateLimit: &RateLimit{RequestsPerMinute: 3, Policy: RateLimitFailFast}})
+
+	// Five texts take three requests
+	if _, _, err := provider.EmbedTexts(context.Background(), numberedTexts(5)); err != nil {
+		t.Fatalf("EmbedTexts: %v", err)
+	}
+	if inner.requests != 3 {
+		t.Errorf("requests = %d, want 3", inner.requests)
+	}
+	if _, _, err := provider.EmbedTexts(context.Background(), numberedTexts(1)); !errors.Is(err, ErrRateLimited) {
+		t.Errorf("EmbedTexts past the limit: err = %v, want ErrRateLimited", err)
+	}
+}
+
+func TestRateLimitStreamStopsWhenCancelled(t *testing.T) {
+	provider := newRateLimitedProvider(
+		&funcProvider{caps: allCapabilities, stream: func(ctx context.Context, req CompletionRequest) (<-chan StreamEvent, error) {
+			return streamOf(
+				StreamEvent{Type: StreamEventContent, Content: "a"},
+				StreamEvent{Type: StreamEventContent, Content: "b"},
+				StreamEvent{Type: StreamEventDone, Message: &Message{Content: "ab"}},
+			), nil
+		}},
+		ProviderConfig{RateLimit: &RateLimit{RequestsPerMinute: 10}},
+	)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	events, err := provider.GenerateCompletionStream(ctx, CompletionRequest{Messages: []Message{NewUserMessage("hi")}})
+	if err != nil {
+		t.Fatalf("GenerateCompletionStream: %v", err)
+	}
+
+	// The consumer walks away; the forwarder exits without delivering more
+	cancel()
+	time.Sleep(20 * time.Millisecond)
+	received := 0
+	for range events {
+		received++
+	}
+	if received != 0 {
+		t.Errorf("received %d events after cancelling", received)
+	}
+}
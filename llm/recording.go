@@ -0,0 +1,457 @@
+package llm
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sync"
+)
+
+// ErrNoRecording is returned in replay mode for requests missing from the fixture
+var ErrNoRecording = errors.New("no recorded response for request")
+
+// RecordingMode selects whether a recording provider records or replays
+type RecordingMode string
+
+const (
+	// RecordingModeRecord calls the provider and writes every request and response to the fixture
+	RecordingModeRecord RecordingMode = "record"
+	// RecordingModeReplay serves responses from the fixture without calling the provider
+	RecordingModeReplay RecordingMode = "replay"
+)
+
+// RecordingConfig enables recording or replaying of a provider's calls.
+// Providers may share a fixture path, but a process either records or
+// replays it.
+type RecordingConfig struct {
+	Mode RecordingMode
+	Path string // Fixture file, e.g. testdata/engine.json
+}
+
+// recordingOperation identifies the kind of recorded call
+type recordingOperation string
+
+const (
+	recordingCompletion       recordingOperation = "completion"
+	recordingStructuredOutput recordingOperation = "structured_output"
+	recordingEmbedding        recordingOperation = "embedding"
+)
+
+// recordedRequest is the normalized form of a request that is hashed into its
+// key. It includes the provider and the model the request resolves to, so
+// providers sharing a fixture and model changes never replay each other's calls.
+type recordedRequest struct {
+	Operation    recordingOperation `json:"operation"`
+	Provider     ProviderType       `json:"provider"`
+	Model        string             `json:"model,omitempty"`
+	Messages     []recordedMessage  `json:"messages,omitempty"`
+	Tools        []recordedTool     `json:"tools,omitempty"`
+	ModelType    ModelType          `json:"model_type,omitempty"`
+	Temperature  float32            `json:"temperature,omitempty"`
+	SchemaName   string             `json:"schema_name,omitempty"`
+	StrictSchema bool               `json:"strict_schema,omitempty"`
+	ResultType   string             `json:"result_type,omitempty"`
+	Texts        []string           `json:"texts,omitempty"`
+}
+
+// recordedMessage holds the parts of a message that are sent to providers.
+// It leaves out usage, reasoning and other fields that vary between runs or
+// are added over time, so fixtures stay valid.
+type recordedMessage struct {
+	Role       Role               `json:"role"`
+	Content    string             `json:"content,omitempty"`
+	Parts      []recordedPart     `json:"parts,omitempty"`
+	Name       string             `json:"name,omitempty"`
+	ToolCalls  []recordedToolCall `json:"tool_calls,omitempty"`
+	ToolCallID string             `json:"tool_call_id,omitempty"`
+	ToolError  bool               `json:"tool_error,omitempty"`
+}
+
+type recordedPart struct {
+	Type      ContentPartType `json:"type"`
+	Text      string          `json:"text,omitempty"`
+	ImageURL  string          `json:"image_url,omitempty"`
+	ImageData []byte          `json:"image_data,omitempty"`
+	MIMEType  string          `json:"mime_type,omitempty"`
+}
+
+type recordedToolCall struct {
+	ID        string `json:"id"`
+	Name      string `json:"name"`
+	Arguments string `json:"arguments"`
+}
+
+type recordedTool struct {
+	Name        string          `json:"name"`
+	Description string          `json:"description"`
+	Parameters  json.RawMessage `json:"parameters,omitempty"`
+}
+
+// recording is a single recorded call
+type recording struct {
+	Key        string          `json:"key"`
+	Request    recordedRequest `json:"request"`
+	Message    *Message        `json:"message,omitempty"`
+	Result     json.RawMessage `json:"result,omitempty"`
+	Embeddings [][]float32     `json:"embeddings,omitempty"`
+	Usage      Usage           `json:"usage"`
+}
+
+// fixture holds the recordings of one fixture file
+type fixture struct {
+	mu         sync.Mutex
+	path       string
+	mode       RecordingMode
+	recordings []recording
+	byKey      map[string][]int
+}
+
+var (
+	fixturesMu sync.Mutex
+	fixtures   = make(map[string]*fixture)
+)
+
+// openFixture returns the fixture for a path, shared by all providers in the
+// process. In record mode the file starts empty; in replay mode it must exist.
+// A path cannot be recorded and replayed within the same process.
+func openFixture(config RecordingConfig) (*fixture, error) {
+	path, err := filepath.Abs(config.Path)
+	if err != nil {
+		return nil, err
+	}
+
+	fixturesMu.Lock()
+	defer fixturesMu.Unlock()
+	if f, ok := fixtures[path]; ok {
+		if f.mode != config.Mode {
+			return nil, fmt.Errorf("fixture %s is already open in %s mode", path, f.mode)
+		}
+		return f, nil
+	}
+
+	f := &fixture{
+		path:  path,
+		mode:  config.Mode,
+		byKey: make(map[string][]int),
+	}
+	if config.Mode == RecordingModeReplay {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read fixture: %w", err)
+		}
+		if err := json.Unmarshal(data, &f.recordings); err != nil {
+			return nil, fmt.Errorf("failed to parse fixture: %w", err)
+		}
+		for i, rec := range f.recordings {
+			f.byKey[rec.Key] = append(f.byKey[rec.Key], i)
+		}
+	}
+
+	fixtures[path] = f
+	return f, nil
+}
+
+// add appends a recording and rewrites the fixture file
+func (f *fixture) add(rec recording) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.byKey[rec.Key] = append(f.byKey[rec.Key], len(f.recordings))
+	f.recordings = append(f.recordings, rec)
+
+	data, err := json.MarshalIndent(f.recordings, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode fixture: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(f.path), 0o755); err != nil {
+		return fmt.Errorf("failed to create fixture directory: %w", err)
+	}
+
+	// Write atomically so an interrupted run never leaves a truncated fixture
+	tmp := f.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return fmt.Errorf("failed to write fixture: %w", err)
+	}
+	return os.Rename(tmp, f.path)
+}
+
+// get returns the nth recording for a key, or the last one if there are fewer
+func (f *fixture) get(key string, request recordedRequest, n int) (recording, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	indexes := f.byKey[key]
+	if len(indexes) == 0 {
+		return recording{}, fmt.Errorf("%w: %s %s (re-record %s)", ErrNoRecording, request.Operation, key[:12], f.path)
+	}
+	return f.recordings[indexes[min(n, len(indexes)-1)]], nil
+}
+
+// recordingProvider records or replays the calls of a provider. Identical
+// requests are replayed in the order they were recorded, repeating the last
+// response. The order is tracked per provider, so clients sharing a fixture
+// replay independently.
+type recordingProvider struct {
+	Provider
+	config  ProviderConfig
+	mode    RecordingMode
+	fixture *fixture
+
+	mu       sync.Mutex
+	replayed map[string]int // Replayed recordings per key
+}
+
+// newRecordingProvider wraps the provider if the config enables recording
+func newRecordingProvider(provider Provider, config ProviderConfig) (Provider, error) {
+	if config.Recording == nil {
+		return provider, nil
+	}
+
+	switch config.Recording.Mode {
+	case RecordingModeRecord, RecordingModeReplay:
+	default:
+		return nil, fmt.Errorf("unsupported recording mode: %s", config.Recording.Mode)
+	}
+
+	fixture, err := openFixture(*config.Recording)
+	if err != nil {
+		return nil, err
+	}
+	return &recordingProvider{
+		Provider: provider,
+		config:   config,
+		mode:     config.Recording.Mode,
+		fixture:  fixture,
+		replayed: make(map[string]int),
+	}, nil
+}
+
+// next returns the next recording for a key
+func (p *recordingProvider) next(key string, request recordedRequest) (recording, error) {
+	p.mu.Lock()
+	n := p.replayed[key]
+	p.replayed[key] = n + 1
+	p.mu.Unlock()
+	return p.fixture.get(key, request, n)
+}
+
+// requestKey hashes a normalized request
+func requestKey(request recordedRequest) (string, error) {
+	data, err := json.Marshal(request)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode request: %w", err)
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// recordedMessages normalizes messages for hashing
+func recordedMessages(messages []Message) []recordedMessage {
+	recorded := make([]recordedMessage, len(messages))
+	for i, message := range messages {
+		recorded[i] = recordedMessage{
+			Role:       message.Role,
+			Content:    message.Content,
+			Name:       message.Name,
+			ToolCallID: message.ToolCallID,
+			ToolError:  message.ToolError,
+		}
+		for _, part := range message.Parts {
+			recorded[i].Parts = append(recorded[i].Parts, recordedPart{
+				Type:      part.Type,
+				Text:      part.Text,
+				ImageURL:  part.ImageURL,
+				ImageData: part.ImageData,
+				MIMEType:  part.MIMEType,
+			})
+		}
+		for _, call := range message.ToolCalls {
+			recorded[i].ToolCalls = append(recorded[i].ToolCalls, recordedToolCall{ID: call.ID, Name: call.Name, Arguments: call.Arguments})
+		}
+	}
+	return recorded
+}
+
+func (p *recordingProvider) completionRequest(req CompletionRequest) recordedRequest {
+	tools := make([]recordedTool, len(req.Tools))
+	for i, tool := range req.Tools {
+		tools[i] = recordedTool{
+			Name:        tool.GetName(),
+			Description: tool.GetDescription(),
+			Parameters:  tool.GetSchema().Parameters,
+		}
+	}
+	return recordedRequest{
+		Operation:   recordingCompletion,
+		Provider:    p.config.Type,
+		Model:       modelFor(p.config, req.ModelType),
+		Messages:    recordedMessages(req.Messages),
+		Tools:       tools,
+		ModelType:   req.ModelType,
+		Temperature: req.Temperature,
+	}
+}
+
+func (p *recordingProvider) GenerateCompletion(ctx context.Context, req CompletionRequest) (Message, error) {
+	request := p.completionRequest(req)
+	key, err := requestKey(request)
+	if err != nil {
+		return Message{}, err
+	}
+
+	if p.mode == RecordingModeReplay {
+		rec, err := p.next(key, request)
+		if err != nil {
+			return Message{}, err
+		}
+		if rec.Message == nil {
+			return Message{}, fmt.Errorf("recording %s holds no message", key[:12])
+		}
+		return *rec.Message, nil
+	}
+
+	message, err := p.Provider.GenerateCompletion(ctx, req)
+	if err != nil {
+		return message, err
+	}
+	if err := p.fixture.add(recording{Key: key, Request: request, Message: &message, Usage: usageOf(message)}); err != nil {
+		return message, err
+	}
+	return message, nil
+}
+
+// GenerateCompletionStream records the final message of the stream. Replayed
+// streams deliver the recorded content as a single content event.
+func (p *recordingProvider) GenerateCompletionStream(ctx context.Context, req CompletionRequest) (<-chan StreamEvent, error) {
+	request := p.completionRequest(req)
+	key, err := requestKey(request)
+	if err != nil {
+		return nil, err
+	}
+
+	if p.mode == RecordingModeReplay {
+		rec, err := p.next(key, request)
+		if err != nil {
+			return nil, err
+		}
+		if rec.Message == nil {
+			return nil, fmt.Errorf("recording %s holds no message", key[:12])
+		}
+
+		events := make(chan StreamEvent)
+		go func() {
+			defer close(events)
+			message := *rec.Message
+			if message.Content != "" {
+				if !sendStreamEvent(ctx, events, StreamEvent{Type: StreamEventContent, Content: message.Content}) {
+					return
+				}
+			}
+			sendStreamEvent(ctx, events, StreamEvent{Type: StreamEventDone, Message: &message})
+		}()
+		return events, nil
+	}
+
+	stream, err := p.Provider.GenerateCompletionStream(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	events := make(chan StreamEvent)
+	go func() {
+		defer close(events)
+		for event := range stream {
+			if event.Type == StreamEventDone && event.Message != nil {
+				message := *event.Message
+				if err := p.fixture.add(recording{Key: key, Request: request, Message: &message, Usage: usageOf(message)}); err != nil {
+					event = StreamEvent{Type: StreamEventError, Err: err}
+				}
+			}
+			if !sendStreamEvent(ctx, events, event) {
+				return
+			}
+		}
+	}()
+	return events, nil
+}
+
+func (p *recordingProvider) GenerateStructuredOutput(ctx context.Context, req StructuredOutputRequest, result interface{}) (Usage, error) {
+	request := recordedRequest{
+		Operation:    recordingStructuredOutput,
+		Provider:     p.config.Type,
+		Model:        modelFor(p.config, req.ModelType),
+		Messages:     recordedMessages(req.Messages),
+		ModelType:    req.ModelType,
+		Temperature:  req.Temperature,
+		SchemaName:   req.SchemaName,
+		StrictSchema: req.StrictSchema,
+		ResultType:   reflect.TypeOf(result).String(),
+	}
+	key, err := requestKey(request)
+	if err != nil {
+		return Usage{}, err
+	}
+
+	if p.mode == RecordingModeReplay {
+		rec, err := p.next(key, request)
+		if err != nil {
+			return Usage{}, err
+		}
+		return rec.Usage, json.Unmarshal(rec.Result, result)
+	}
+
+	usage, err := p.Provider.GenerateStructuredOutput(ctx, req, result)
+	if err != nil {
+		return usage, err
+	}
+	data, err := json.Marshal(result)
+	if err != nil {
+		return usage, fmt.Errorf("failed to encode structured output: %w", err)
+	}
+	return usage, p.fixture.add(recording{Key: key, Request: request, Result: data, Usage: usage})
+}
+
+func (p *recordingProvider) EmbedText(ctx context.Context, text string) ([]float32, Usage, error) {
+	return firstEmbedding(p.EmbedTexts(ctx, []string{text}))
+}
+
+func (p *recordingProvider) EmbedTexts(ctx context.Context, texts []string) ([][]float32, Usage, error) {
+	request := recordedRequest{
+		Operation: recordingEmbedding,
+		Provider:  p.config.Type,
+		Model:     embeddingModelName(p.config),
+		Texts:     texts,
+	}
+	key, err := requestKey(request)
+	if err != nil {
+		return nil, Usage{}, err
+	}
+
+	if p.mode == RecordingModeReplay {
+		rec, err := p.next(key, request)
+		if err != nil {
+			return nil, Usage{}, err
+		}
+		return rec.Embeddings, rec.Usage, nil
+	}
+
+	embeddings, usage, err := p.Provider.EmbedTexts(ctx, texts)
+	if err != nil {
+		return embeddings, usage, err
+	}
+	return embeddings, usage, p.fixture.add(recording{Key: key, Request: request, Embeddings: embeddings, Usage: usage})
+}
+
+// usageOf returns the usage of a message, if any
+func usageOf(message Message) Usage {
+	if message.Usage == nil {
+		return Usage{}
+	}
+	return *message.Usage
+}
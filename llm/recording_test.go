@@ -0,0 +1,157 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// stubProvider answers completions with a fixed message
+type stubProvider struct {
+	Provider
+	content string
+	calls   int
+}
+
+func (p *stubProvider) GenerateCompletion(ctx context.Context, req CompletionRequest) (Message, error) {
+	p.calls++
+	return NewAssistantMessage(p.content), nil
+}
+
+// copyFixture copies a recorded fixture to a new path
+func copyFixture(t *testing.T, path string) string {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("ReadFile: %v", err)
+	}
+	copied := filepath.Join(t.TempDir(), "replay.json")
+	if err := os.WriteFile(copied, data, 0o644); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+	return copied
+}
+
+func TestRecordingKeyIncludesProviderAndModel(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "fixture.json")
+	req := CompletionRequest{Messages: []Message{NewUserMessage("hello")}, ModelType: ModelTypeDefault}
+
+	config := ProviderConfig{Type: ProviderOpenAI, Recording: &RecordingConfig{Mode: RecordingModeRecord, Path: path}}
+	recorder, err := newRecordingProvider(&stubProvider{content: "recorded"}, config)
+	if err != nil {
+		t.Fatalf("newRecordingProvider: %v", err)
+	}
+	if _, err := recorder.GenerateCompletion(context.Background(), req); err != nil {
+		t.Fatalf("record: %v", err)
+	}
+
+	// A path cannot be replayed in the process that records it, so replay a copy
+	replayPath := copyFixture(t, path)
+
+	replay := func(config ProviderConfig) (Message, error) {
+		config.Recording = &RecordingConfig{Mode: RecordingModeReplay, Path: replayPath}
+		stub := &stubProvider{content: "live"}
+		provider, err := newRecordingProvider(stub, config)
+		if err != nil {
+			t.Fatalf("newRecordingProvider: %v", err)
+		}
+		message, err := provider.GenerateCompletion(context.Background(), req)
+		if stub.calls != 0 {
+			t.Errorf("replay called the provider")
+		}
+		return message, err
+	}
+
+	message, err := replay(ProviderConfig{Type: ProviderOpenAI})
+	if err != nil || message.Content != "recorded" {
+		t.Errorf("replay = %q, %v, want the recorded message", message.Content, err)
+	}
+	if _, err := replay(ProviderConfig{Type: ProviderDeepseek}); !errors.Is(err, ErrNoRecording) {
+		t.Errorf("replay with another provider: err = %v, want ErrNoRecording", err)
+	}
+	otherModel := ProviderConfig{Type: ProviderOpenAI, ModelConfig: map[ModelType]string{ModelTypeDefault: "gpt-4o"}}
+	if _, err := replay(otherModel); !errors.Is(err, ErrNoRecording) {
+		t.Errorf("replay with another model: err = %v, want ErrNoRecording", err)
+	}
+}
+
+func TestRecordingKeyIgnoresUnsentFields(t *testing.T) {
+	plain := NewAssistantMessage("hi")
+	plain.ToolCalls = []ToolCall{{ID: "call_1", Name: "search", Arguments: "{}"}}
+	annotated := plain
+	annotated.Usage = &Usage{TotalTokens: 12}
+	annotated.Reasoning = "thinking"
+	annotated.ReasoningSignature = "signature"
+	annotated.Cascade = &CascadeTrace{FastModel: "fast"}
+
+	key := func(message Message) string {
+		key, err := requestKey(recordedRequest{Operation: recordingCompletion, Messages: recordedMessages([]Message{message})})
+		if err != nil {
+			t.Fatalf("requestKey: %v", err)
+		}
+		return key
+	}
+	if key(plain) != key(annotated) {
+		t.Errorf("usage, reasoning or cascade changed the key")
+	}
+	otherCall := plain
+	otherCall.ToolCalls = []ToolCall{{ID: "call_2", Name: "search", Arguments: "{}"}}
+	if key(plain) == key(otherCall) {
+		t.Errorf("tool call IDs are not part of the key")
+	}
+}
+
+func TestRecordingModeMismatch(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "fixture.json")
+	record := ProviderConfig{Type: ProviderOpenAI, Recording: &RecordingConfig{Mode: RecordingModeRecord, Path: path}}
+	if _, err := newRecordingProvider(&stubProvider{}, record); err != nil {
+		t.Fatalf("newRecordingProvider: %v", err)
+	}
+	replay := ProviderConfig{Type: ProviderOpenAI, Recording: &RecordingConfig{Mode: RecordingModeReplay, Path: path}}
+	if _, err := newRecordingProvider(&stubProvider{}, replay); err == nil {
+		t.Errorf("replaying a path recorded in the same process succeeded")
+	}
+}
+
+func TestReplayOrderPerProvider(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "fixture.json")
+	req := CompletionRequest{Messages: []Message{NewUserMessage("again")}}
+	stub := &stubProvider{}
+	recorder, err := newRecordingProvider(stub, ProviderConfig{Type: ProviderOpenAI, Recording: &RecordingConfig{Mode: RecordingModeRecord, Path: path}})
+	if err != nil {
+		t.Fatalf("newRecordingProvider: %v", err)
+	}
+	for _, content := range []string{"first", "second"} {
+		stub.content = content
+		if _, err := recorder.GenerateCompletion(context.Background(), req); err != nil {
+			t.Fatalf("record: %v", err)
+		}
+	}
+
+	config := ProviderConfig{Type: ProviderOpenAI, Recording: &RecordingConfig{Mode: RecordingModeReplay, Path: copyFixture(t, path)}}
+	replay := func(provider Provider) string {
+		message, err := provider.GenerateCompletion(context.Background(), req)
+		if err != nil {
+			t.Fatalf("replay: %v", err)
+		}
+		return message.Content
+	}
+	first, err := newRecordingProvider(&stubProvider{}, config)
+	if err != nil {
+		t.Fatalf("newRecordingProvider: %v", err)
+	}
+	if got := []string{replay(first), replay(first), replay(first)}; got[0] != "first" || got[1] != "second" || got[2] != "second" {
+		t.Errorf("replayed %v, want first, second and the last repeated", got)
+	}
+
+	// Another client sharing the fixture starts from the first recording
+	second, err := newRecordingProvider(&stubProvider{}, config)
+	if err != nil {
+		t.Fatalf("newRecordingProvider: %v", err)
+	}
+	if got := replay(second); got != "first" {
+		t.Errorf("second provider replayed %q, want first", got)
+	}
+}
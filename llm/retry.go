@@ -0,0 +1,124 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+	"time"
+)
+
+// RetryConfig controls how failed provider calls are retried
+type RetryConfig struct {
+	MaxAttempts    int           // Attempts per provider including the first, defaults to 3. Set to 1 to disable retries
+	InitialBackoff time.Duration // Delay before the first retry, defaults to 500ms
+	MaxBackoff     time.Duration // Upper bound for a single delay, defaults to 30s
+	Multiplier     float64       // Growth factor between retries, defaults to 2
+}
+
+// DefaultRetryConfig is used when Config.Retry is nil
+var DefaultRetryConfig = RetryConfig{
+	MaxAttempts:    3,
+	InitialBackoff: 500 * time.Millisecond,
+	MaxBackoff:     30 * time.Second,
+	Multiplier:     2,
+}
+
+// withDefaults fills unset fields from DefaultRetryConfig
+func (r RetryConfig) withDefaults() RetryConfig {
+	if r.MaxAttempts <= 0 {
+		r.MaxAttempts = DefaultRetryConfig.MaxAttempts
+	}
+	if r.InitialBackoff <= 0 {
+		r.InitialBackoff = DefaultRetryConfig.InitialBackoff
+	}
+	if r.MaxBackoff <= 0 {
+		r.MaxBackoff = DefaultRetryConfig.MaxBackoff
+	}
+	if r.Multiplier < 1 {
+		r.Multiplier = DefaultRetryConfig.Multiplier
+	}
+	return r
+}
+
+// backoff returns the jittered delay before the given retry (1-based).
+// Half of the exponential delay is fixed and the other half is random.
+func (r RetryConfig) backoff(retry int) time.Duration {
+	delay := float64(r.InitialBackoff) * math.Pow(r.Multiplier, float64(retry-1))
+	if delay > float64(r.MaxBackoff) {
+		delay = float64(r.MaxBackoff)
+	}
+	half := int64(delay / 2)
+	if half <= 0 {
+		return time.Duration(delay)
+	}
+	return time.Duration(half + rand.Int63n(half+1))
+}
+
+// withRetry calls fn until it succeeds, fails with a non-retryable error,
+// runs out of attempts or the context is done
+func (c *LLMClient) withRetry(ctx context.Context, fn func() error) error {
+	for attempt := 1; ; attempt++ {
+		err := fn()
+		if err == nil {
+			return nil
+		}
+		kind := ClassifyError(err)
+		if !kind.Retryable() || attempt >= c.retry.MaxAttempts || ctx.Err() != nil {
+			return err
+		}
+
+		delay := c.retry.backoff(attempt)
+		if c.logger != nil {
+			c.logger.Warnf("LLM call failed (%s), retrying in %s (attempt %d/%d): %v", kind, delay, attempt+1, c.retry.MaxAttempts, err)
+		}
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return err
+		}
+	}
+}
+
+// withFallback runs fn against each provider in order, with retries, until
+// one succeeds. Returns the errors of all providers if every one fails.
+func (c *LLMClient) withFallback(ctx context.Context, providers []Provider, fn func(Provider) error) error {
+	var errs []error
+	for i, provider := range providers {
+		err := c.withRetry(ctx, func() error { return fn(provider) })
+		if err == nil {
+			return nil
+		}
+		errs = append(errs, err)
+		// Invalid structured output is repaired with the same provider
+		var validationErr *ValidationError
+		if ctx.Err() != nil || errors.As(err, &validationErr) {
+			break
+		}
+		if i < len(providers)-1 && c.logger != nil {
+			c.logger.Warnf("LLM provider %d failed, falling back to the next provider: %v", i+1, err)
+		}
+	}
+
+	if len(errs) == 1 {
+		return errs[0]
+	}
+	return fmt.Errorf("all %d providers failed: %w", len(errs), errors.Join(errs...))
+}
+
+// createProviders creates the primary provider followed by its fallbacks
+func createProviders(primary Provider, fallbacks []ProviderConfig, config Config) ([]Provider, error) {
+	providers := []Provider{primary}
+	for i, fallback := range fallbacks {
+		provider, err := createProvider(fallback, config)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create fallback provider %d: %w", i+1, err)
+		}
+		providers = append(providers, provider)
+	}
+	return providers, nil
+}
@@ -0,0 +1,201 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/sashabaranov/go-openai"
+)
+
+func TestClassifyError(t *testing.T) {
+	tests := []struct {
+		name      string
+		err       error
+		want      ErrorKind
+		retryable bool
+	}{
+		{"rate limit", newStatusError(ProviderOpenAI, http.StatusTooManyRequests, "slow down"), ErrorKindRateLimit, true},
+		{"request timeout", newStatusError(ProviderOpenAI, http.StatusRequestTimeout, ""), ErrorKindTimeout, true},
+		{"gateway timeout", newStatusError(ProviderOpenAI, http.StatusGatewayTimeout, ""), ErrorKindTimeout, true},
+		{"server error", newStatusError(ProviderOpenAI, http.StatusBadGateway, ""), ErrorKindServer, true},
+		{"bad request", newStatusError(ProviderOpenAI, http.StatusBadRequest, "bad"), ErrorKindInvalidRequest, false},
+		{"unauthorized", newStatusError(ProviderOpenAI, http.StatusUnauthorized, ""), ErrorKindInvalidRequest, false},
+		{"wrapped provider error", fmt.Errorf("call failed: %w", newStatusError(ProviderGemini, http.StatusServiceUnavailable, "")), ErrorKindServer, true},
+		{"openai API error", &openai.APIError{HTTPStatusCode: http.StatusTooManyRequests}, ErrorKindRateLimit, true},
+		{"openai request error", &openai.RequestError{HTTPStatusCode: http.StatusNotFound}, ErrorKindInvalidRequest, false},
+		{"deadline", fmt.Errorf("request: %w", context.DeadlineExceeded), ErrorKindTimeout, true},
+		{"cancelled", context.Canceled, ErrorKindUnknown, false},
+		{"connection refused", &net.OpError{Op: "dial", Err: errors.New("connection refused")}, ErrorKindServer, true},
+		{"network timeout", &net.DNSError{IsTimeout: true}, ErrorKindTimeout, true},
+		{"client-side rate limit", ErrRateLimited, ErrorKindUnknown, false},
+		{"other", errors.New("boom"), ErrorKindUnknown, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			kind := ClassifyError(tt.err)
+			if kind != tt.want || kind.Retryable() != tt.retryable {
+				t.Errorf("ClassifyError = %s (retryable %v), want %s (retryable %v)", kind, kind.Retryable(), tt.want, tt.retryable)
+			}
+		})
+	}
+}
+
+// failingProvider fails its first failures completions with err, appending
+// its name to calls on every attempt
+type failingProvider struct {
+	funcProvider
+	name     string
+	failures int
+	err      error
+}
+
+func newFailingProvider(name string, failures int, err error, mu *sync.Mutex, calls *[]string) *failingProvider {
+	p := &failingProvider{name: name, failures: failures, err: err}
+	p.caps = allCapabilities
+	p.complete = func(ctx context.Context, req CompletionRequest) (Message, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		*calls = append(*calls, p.name)
+		if p.failures != 0 {
+			p.failures--
+			return Message{}, p.err
+		}
+		return NewAssistantMessage(p.name), nil
+	}
+	return p
+}
+
+func newRetryClient(providers ...Provider) *LLMClient {
+	client := newTestClient(providers...)
+	client.retry = RetryConfig{MaxAttempts: 3, InitialBackoff: time.Millisecond, MaxBackoff: time.Millisecond, Multiplier: 2}
+	return client
+}
+
+var (
+	serverErr  = newStatusError(ProviderOpenAI, http.StatusInternalServerError, "unavailable")
+	invalidErr = newStatusError(ProviderOpenAI, http.StatusBadRequest, "bad request")
+)
+
+func TestRetryAttempts(t *testing.T) {
+	tests := []struct {
+		name      string
+		failures  int
+		err       error
+		wantCalls int
+		wantErr   bool
+	}{
+		{"succeeds after retries", 2, serverErr, 3, false},
+		{"gives up after the last attempt", -1, serverErr, 3, true},
+		{"permanent errors are not retried", -1, invalidErr, 1, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var mu sync.Mutex
+			var calls []string
+			client := newRetryClient(newFailingProvider("a", tt.failures, tt.err, &mu, &calls))
+
+			_, err := client.GenerateCompletion(CompletionRequest{Messages: []Message{NewUserMessage("hi")}})
+			if (err != nil) != tt.wantErr {
+				t.Errorf("err = %v, want error %v", err, tt.wantErr)
+			}
+			if tt.wantErr && !errors.Is(err, tt.err) {
+				t.Errorf("err = %v, want %v", err, tt.err)
+			}
+			if len(calls) != tt.wantCalls {
+				t.Errorf("calls = %d, want %d", len(calls), tt.wantCalls)
+			}
+		})
+	}
+}
+
+func TestRetryBackoffCancelled(t *testing.T) {
+	var mu sync.Mutex
+	var calls []string
+	client := newRetryClient(newFailingProvider("a", -1, serverErr, &mu, &calls))
+	client.retry.InitialBackoff = time.Hour
+	client.retry.MaxBackoff = time.Hour
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	_, err := client.GenerateCompletionContext(ctx, CompletionRequest{Messages: []Message{NewUserMessage("hi")}})
+	if !errors.Is(err, serverErr) {
+		t.Errorf("err = %v, want the provider error", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("cancelled backoff took %v", elapsed)
+	}
+	if len(calls) != 1 {
+		t.Errorf("calls = %d, want 1", len(calls))
+	}
+}
+
+func TestFallbackOrder(t *testing.T) {
+	var mu sync.Mutex
+	var calls []string
+	client := newRetryClient(
+		newFailingProvider("a", -1, serverErr, &mu, &calls),
+		newFailingProvider("b", -1, ErrRateLimited, &mu, &calls),
+		newFailingProvider("c", 0, nil, &mu, &calls),
+		newFailingProvider("d", 0, nil, &mu, &calls),
+	)
+
+	msg, err := client.GenerateCompletion(CompletionRequest{Messages: []Message{NewUserMessage("hi")}})
+	if err != nil {
+		t.Fatalf("GenerateCompletion: %v", err)
+	}
+	if msg.Content != "c" {
+		t.Errorf("answered by %s, want c", msg.Content)
+	}
+	// Retryable failures are retried before falling back, others are not
+	if want := []string{"a", "a", "a", "b", "c"}; !slices.Equal(calls, want) {
+		t.Errorf("calls = %v, want %v", calls, want)
+	}
+}
+
+func TestFallbackAllFail(t *testing.T) {
+	var mu sync.Mutex
+	var calls []string
+	client := newRetryClient(
+		newFailingProvider("a", -1, invalidErr, &mu, &calls),
+		newFailingProvider("b", -1, ErrRateLimited, &mu, &calls),
+	)
+
+	_, err := client.GenerateCompletion(CompletionRequest{Messages: []Message{NewUserMessage("hi")}})
+	if !errors.Is(err, invalidErr) || !errors.Is(err, ErrRateLimited) {
+		t.Errorf("err = %v, want the errors of both providers", err)
+	}
+	if want := []string{"a", "b"}; !slices.Equal(calls, want) {
+		t.Errorf("calls = %v, want %v", calls, want)
+	}
+}
+
+func TestFallbackSkippedForValidationErrors(t *testing.T) {
+	invalid := &funcProvider{caps: allCapabilities, structured: func(ctx context.Context, req StructuredOutputRequest, result interface{}) (Usage, error) {
+		return Usage{}, &ValidationError{Problems: []string{"wrong"}}
+	}}
+	fallbackCalls := 0
+	fallback := &funcProvider{caps: allCapabilities, structured: func(ctx context.Context, req StructuredOutputRequest, result interface{}) (Usage, error) {
+		fallbackCalls++
+		return Usage{}, nil
+	}}
+	client := newRetryClient(invalid, fallback)
+	client.maxRepairs = 0
+
+	var result sentiment
+	err := client.GenerateStructuredOutput(StructuredOutputRequest{Messages: []Message{NewUserMessage("Rate")}}, &result)
+	var validationErr *ValidationError
+	if !errors.As(err, &validationErr) {
+		t.Errorf("err = %v, want a ValidationError", err)
+	}
+	if fallbackCalls != 0 {
+		t.Errorf("fallback called %d times", fallbackCalls)
+	}
+}
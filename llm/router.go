@@ -0,0 +1,139 @@
+package llm
+
+import (
+	"fmt"
+)
+
+// RouteMatch holds the conditions of a route. Zero fields match any call.
+type RouteMatch struct {
+	Manager    string    // UsageScope.Manager of the calling client, see LLMClient.WithScope
+	Operation  Operation // OperationCompletion or OperationStructuredOutput
+	ModelType  ModelType
+	SchemaName string // StructuredOutputRequest.SchemaName
+	MinTokens  int    // Estimated prompt tokens, inclusive
+	MaxTokens  int    // Estimated prompt tokens, inclusive
+}
+
+// Route sends the chat calls matching its conditions to a provider, e.g. a
+// cheap model for background managers and a premium one for replies
+type Route struct {
+	Match     RouteMatch
+	Provider  ProviderConfig
+	Model     string // Overrides the provider's model for every model type
+	Fallbacks []ProviderConfig
+}
+
+// routeRequest holds the attributes of a call that routes match against
+type routeRequest struct {
+	scope      UsageScope
+	operation  Operation
+	modelType  ModelType
+	schemaName string
+	tokens     int
+}
+
+func completionRoute(scope UsageScope, req CompletionRequest) routeRequest {
+	return routeRequest{
+		scope:     scope,
+		operation: OperationCompletion,
+		modelType: req.ModelType,
+		tokens:    EstimateTokens(req.Messages) + estimateToolTokens(req.Tools),
+	}
+}
+
+func structuredOutputRoute(scope UsageScope, req StructuredOutputRequest) routeRequest {
+	return routeRequest{
+		scope:      scope,
+		operation:  OperationStructuredOutput,
+		modelType:  req.ModelType,
+		schemaName: req.SchemaName,
+		tokens:     EstimateTokens(req.Messages),
+	}
+}
+
+// matches reports whether the request meets all conditions
+func (m RouteMatch) matches(req routeRequest) bool {
+	modelType := req.modelType
+	if modelType == "" {
+		modelType = ModelTypeDefault
+	}
+	switch {
+	case m.Manager != "" && m.Manager != req.scope.Manager:
+		return false
+	case m.Operation != "" && m.Operation != req.operation:
+		return false
+	case m.ModelType != "" && m.ModelType != modelType:
+		return false
+	case m.SchemaName != "" && m.SchemaName != req.schemaName:
+		return false
+	case m.MinTokens > 0 && req.tokens < m.MinTokens:
+		return false
+	case m.MaxTokens > 0 && req.tokens > m.MaxTokens:
+		return false
+	}
+	return true
+}
+
+// router resolves the provider chain of a chat call
+type router struct {
+	routes []Route
+	chains [][]Provider // Provider and fallbacks of each route
+}
+
+// newRouter creates the providers of every route. Embedding routes are
+// rejected, as all embeddings have to share the embedding provider's model.
+func newRouter(config Config) (*router, error) {
+	r := &router{routes: config.Routes}
+	for i, route := range config.Routes {
+		if route.Match.Operation == OperationEmbedding {
+			return nil, fmt.Errorf("route %d: embeddings cannot be routed, use EmbeddingProvider", i+1)
+		}
+
+		providerConfig := route.Provider
+		if route.Model != "" {
+			providerConfig.ModelConfig = map[ModelType]string{
+				ModelTypeFast:     route.Model,
+				ModelTypeDefault:  route.Model,
+				ModelTypeAdvanced: route.Model,
+			}
+		}
+		provider, err := createProvider(providerConfig, config)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create provider of route %d: %w", i+1, err)
+		}
+		chain, err := createProviders(provider, route.Fallbacks, config)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create fallbacks of route %d: %w", i+1, err)
+		}
+
+		feature := FeatureCompletion
+		if route.Match.Operation == OperationStructuredOutput {
+			feature = FeatureStructuredOutput
+		}
+		role := fmt.Sprintf("route %d", i+1)
+		if err := validateProviders(chain, append([]ProviderConfig{providerConfig}, route.Fallbacks...), role, feature); err != nil {
+			return nil, err
+		}
+		r.chains = append(r.chains, chain)
+	}
+	return r, nil
+}
+
+// providers returns the providers of every route
+func (r *router) providers() []Provider {
+	var providers []Provider
+	for _, chain := range r.chains {
+		providers = append(providers, chain...)
+	}
+	return providers
+}
+
+// route returns the provider chain of the first matching route
+func (r *router) route(req routeRequest) ([]Provider, bool) {
+	for i, route := range r.routes {
+		if route.Match.matches(req) {
+			return r.chains[i], true
+		}
+	}
+	return nil, false
+}
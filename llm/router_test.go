@@ -0,0 +1,170 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestRouteMatch(t *testing.T) {
+	insight := UsageScope{Manager: "insight"}
+	tests := []struct {
+		name  string
+		match RouteMatch
+		req   routeRequest
+		want  bool
+	}{
+		{"empty match", RouteMatch{}, routeRequest{operation: OperationCompletion}, true},
+		{"manager", RouteMatch{Manager: "insight"}, routeRequest{scope: insight}, true},
+		{"other manager", RouteMatch{Manager: "insight"}, routeRequest{scope: UsageScope{Manager: "personality"}}, false},
+		{"operation", RouteMatch{Operation: OperationStructuredOutput}, routeRequest{operation: OperationStructuredOutput}, true},
+		{"other operation", RouteMatch{Operation: OperationStructuredOutput}, routeRequest{operation: OperationCompletion}, false},
+		{"model type", RouteMatch{ModelType: ModelTypeFast}, routeRequest{modelType: ModelTypeFast}, true},
+		{"other model type", RouteMatch{ModelType: ModelTypeFast}, routeRequest{modelType: ModelTypeAdvanced}, false},
+		{"unset model type is default", RouteMatch{ModelType: ModelTypeDefault}, routeRequest{}, true},
+		{"schema name", RouteMatch{SchemaName: "insights"}, routeRequest{schemaName: "insights"}, true},
+		{"other schema name", RouteMatch{SchemaName: "insights"}, routeRequest{schemaName: "facts"}, false},
+		{"min tokens inclusive", RouteMatch{MinTokens: 100}, routeRequest{tokens: 100}, true},
+		{"below min tokens", RouteMatch{MinTokens: 100}, routeRequest{tokens: 99}, false},
+		{"max tokens inclusive", RouteMatch{MaxTokens: 100}, routeRequest{tokens: 100}, true},
+		{"above max tokens", RouteMatch{MaxTokens: 100}, routeRequest{tokens: 101}, false},
+		{"all conditions", RouteMatch{Manager: "insight", ModelType: ModelTypeFast, MaxTokens: 100}, routeRequest{scope: insight, modelType: ModelTypeFast, tokens: 50}, true},
+		{"one condition fails", RouteMatch{Manager: "insight", ModelType: ModelTypeFast, MaxTokens: 100}, routeRequest{scope: insight, modelType: ModelTypeDefault, tokens: 50}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.match.matches(tt.req); got != tt.want {
+				t.Errorf("matches = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRouteModelOverride(t *testing.T) {
+	server := newFakeServer(t, func(w http.ResponseWriter, req capturedRequest) {
+		writeJSON(w, `{"choices": [{"message": {"role": "assistant", "content": "Done."}, "finish_reason": "stop"}]}`)
+	})
+	provider := ProviderConfig{
+		Type:        ProviderDeepseek,
+		APIKey:      "test-key",
+		BaseURL:     server.URL,
+		ModelConfig: map[ModelType]string{ModelTypeFast: "fast", ModelTypeDefault: "default", ModelTypeAdvanced: "advanced"},
+	}
+	client, err := NewLLMClient(Config{
+		DefaultProvider:   provider,
+		EmbeddingProvider: &ProviderConfig{Type: ProviderHashing},
+		Routes: []Route{
+			{Match: RouteMatch{Manager: "insight"}, Provider: provider, Model: "cheap"},
+			{Match: RouteMatch{ModelType: ModelTypeAdvanced}, Provider: provider},
+		},
+		Context: context.Background(),
+	})
+	if err != nil {
+		t.Fatalf("NewLLMClient: %v", err)
+	}
+
+	tests := []struct {
+		name      string
+		manager   string
+		modelType ModelType
+		want      string
+	}{
+		{"route model overrides every model type", "insight", ModelTypeAdvanced, "cheap"},
+		{"route without model keeps the model type", "", ModelTypeAdvanced, "advanced"},
+		{"unrouted call", "", ModelTypeFast, "fast"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := client.WithScope(UsageScope{Manager: tt.manager}).GenerateCompletion(CompletionRequest{
+				Messages:  []Message{NewUserMessage("hi")},
+				ModelType: tt.modelType,
+			})
+			if err != nil {
+				t.Fatalf("GenerateCompletion: %v", err)
+			}
+			if model := server.last(t).Body["model"]; model != tt.want {
+				t.Errorf("model = %v, want %s", model, tt.want)
+			}
+		})
+	}
+}
+
+func TestEmbeddingRoutesRejected(t *testing.T) {
+	_, err := NewLLMClient(Config{
+		DefaultProvider:   ProviderConfig{Type: ProviderDeepseek, APIKey: "test-key"},
+		EmbeddingProvider: &ProviderConfig{Type: ProviderHashing},
+		Routes:            []Route{{Match: RouteMatch{Operation: OperationEmbedding}, Provider: ProviderConfig{Type: ProviderHashing}}},
+		Context:           context.Background(),
+	})
+	if err == nil || !strings.Contains(err.Error(), "cannot be routed") {
+		t.Errorf("err = %v, want embedding routes rejected", err)
+	}
+}
+
+func TestRouteCapabilityFallback(t *testing.T) {
+	var calls []string
+	provider := func(name string, caps Capabilities) *funcProvider {
+		return &funcProvider{caps: caps, complete: func(ctx context.Context, req CompletionRequest) (Message, error) {
+			calls = append(calls, name)
+			return Message{}, newStatusError(ProviderOpenAI, http.StatusInternalServerError, name+" is down")
+		}}
+	}
+	textOnly := allCapabilities
+	textOnly.Vision = false
+	textOnly.Tools = false
+
+	routeText := provider("route text", textOnly)
+	routeVision := provider("route vision", allCapabilities)
+	routeVisionFallback := provider("route vision fallback", allCapabilities)
+	chatText := provider("chat text", textOnly)
+	otherVision := provider("other vision", allCapabilities)
+
+	client := newTestClient(chatText)
+	client.providers = []Provider{chatText, routeText, routeVision, routeVisionFallback, otherVision}
+	client.router = &router{
+		routes: []Route{
+			{Match: RouteMatch{Manager: "mixed"}},
+			{Match: RouteMatch{Manager: "text"}},
+		},
+		chains: [][]Provider{
+			{routeText, routeVision, routeVisionFallback},
+			{routeText},
+		},
+	}
+
+	image := NewUserMessageWithImages("What is this?", "https://example.com/cat.png")
+	tests := []struct {
+		name     string
+		manager  string
+		messages []Message
+		want     []string
+	}{
+		{"whole route chain", "mixed", []Message{NewUserMessage("hi")}, []string{"route text", "route vision", "route vision fallback"}},
+		{"capable providers of the route in order", "mixed", []Message{image}, []string{"route vision", "route vision fallback"}},
+		{"other providers when the route is not capable", "text", []Message{image}, []string{"route vision", "route vision fallback", "other vision"}},
+		{"unrouted chat providers", "", []Message{NewUserMessage("hi")}, []string{"chat text"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calls = nil
+			_, err := client.WithScope(UsageScope{Manager: tt.manager}).GenerateCompletion(CompletionRequest{Messages: tt.messages})
+			if err == nil {
+				t.Fatal("expected every provider to fail")
+			}
+			if !slices.Equal(calls, tt.want) {
+				t.Errorf("calls = %v, want %v", calls, tt.want)
+			}
+		})
+	}
+
+	// Without any capable provider the call fails before reaching one
+	calls = nil
+	client.providers = []Provider{chatText, routeText}
+	_, err := client.WithScope(UsageScope{Manager: "text"}).GenerateCompletion(CompletionRequest{Messages: []Message{image}})
+	if !errors.Is(err, ErrUnsupportedFeature) || len(calls) != 0 {
+		t.Errorf("err = %v, calls = %v, want ErrUnsupportedFeature without calls", err, calls)
+	}
+}
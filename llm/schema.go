@@ -0,0 +1,296 @@
+package llm
+
+import (
+	"encoding"
+	"fmt"
+	"maps"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+var textMarshalerType = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+
+// Schema is a JSON Schema generated from a Go type with GenerateSchema
+type Schema struct {
+	Type                 string             `json:"type,omitempty"`
+	Description          string             `json:"description,omitempty"`
+	Enum                 []string           `json:"enum,omitempty"`
+	Minimum              *float64           `json:"minimum,omitempty"`
+	Maximum              *float64           `json:"maximum,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+	AdditionalProperties any                `json:"additionalProperties,omitempty"`
+}
+
+// ValidationError lists every way a structured output violates its schema
+// or the request's Validator
+type ValidationError struct {
+	Problems []string
+	Content  string // The rejected reply, sent back to the model for a repair
+}
+
+func (e *ValidationError) Error() string {
+	return "structured output does not match schema: " + strings.Join(e.Problems, "; ")
+}
+
+// GenerateSchema builds a JSON Schema for the type of v from its struct tags:
+//   - json names the property; fields with omitempty are optional
+//   - jsonschema accepts required, enum=<value> (repeatable), minimum=<n> and maximum=<n>
+//   - description documents the property
+//
+// Fields of embedded structs without a json name are promoted into the
+// parent, as encoding/json does.
+func GenerateSchema(v interface{}) (*Schema, error) {
+	return schemaForType(reflect.TypeOf(v))
+}
+
+func schemaForType(t reflect.Type) (*Schema, error) {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	// Types such as time.Time encode themselves as JSON strings
+	if reflect.PointerTo(t).Implements(textMarshalerType) {
+		return &Schema{Type: "string"}, nil
+	}
+
+	switch t.Kind() {
+	case reflect.String:
+		return &Schema{Type: "string"}, nil
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}, nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return &Schema{Type: "integer"}, nil
+	case reflect.Float32, reflect.Float64:
+		return &Schema{Type: "number"}, nil
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			// Byte slices are encoded as base64 strings
+			return &Schema{Type: "string"}, nil
+		}
+		items, err := schemaForType(t.Elem())
+		if err != nil {
+			return nil, err
+		}
+		return &Schema{Type: "array", Items: items}, nil
+	case reflect.Map:
+		if t.Key().Kind() != reflect.String {
+			return nil, fmt.Errorf("unsupported map key type %s", t.Key())
+		}
+		values, err := schemaForType(t.Elem())
+		if err != nil {
+			return nil, err
+		}
+		return &Schema{Type: "object", AdditionalProperties: values}, nil
+	case reflect.Interface:
+		return &Schema{}, nil
+	case reflect.Struct:
+		return schemaForStruct(t)
+	default:
+		return nil, fmt.Errorf("unsupported type %s", t)
+	}
+}
+
+func schemaForStruct(t reflect.Type) (*Schema, error) {
+	schema := &Schema{
+		Type:                 "object",
+		Properties:           make(map[string]*Schema),
+		AdditionalProperties: false,
+	}
+
+	var embedded []*Schema
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name, options, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "-" {
+			continue
+		}
+
+		// Like encoding/json, promote the fields of untagged embedded structs
+		if field.Anonymous && name == "" {
+			fieldType := field.Type
+			if fieldType.Kind() == reflect.Ptr {
+				fieldType = fieldType.Elem()
+			}
+			if fieldType.Kind() == reflect.Struct && !reflect.PointerTo(fieldType).Implements(textMarshalerType) {
+				promoted, err := schemaForStruct(fieldType)
+				if err != nil {
+					return nil, fmt.Errorf("field %s: %w", field.Name, err)
+				}
+				embedded = append(embedded, promoted)
+				continue
+			}
+		}
+		if !field.IsExported() {
+			continue
+		}
+
+		if name == "" {
+			name = field.Name
+		}
+		required := !strings.Contains(options, "omitempty")
+
+		property, err := schemaForType(field.Type)
+		if err != nil {
+			return nil, fmt.Errorf("field %s: %w", field.Name, err)
+		}
+		property.Description = field.Tag.Get("description")
+
+		for _, rule := range strings.Split(field.Tag.Get("jsonschema"), ",") {
+			key, value, _ := strings.Cut(rule, "=")
+			switch key {
+			case "required":
+				required = true
+			case "enum":
+				property.Enum = append(property.Enum, value)
+			case "minimum", "maximum":
+				bound, err := strconv.ParseFloat(value, 64)
+				if err != nil {
+					return nil, fmt.Errorf("field %s: invalid %s %q", field.Name, key, value)
+				}
+				if key == "minimum" {
+					property.Minimum = &bound
+				} else {
+					property.Maximum = &bound
+				}
+			}
+		}
+
+		schema.Properties[name] = property
+		if required {
+			schema.Required = append(schema.Required, name)
+		}
+	}
+
+	// Fields of the struct itself take precedence over promoted ones
+	for _, promoted := range embedded {
+		for _, name := range slices.Sorted(maps.Keys(promoted.Properties)) {
+			if _, exists := schema.Properties[name]; exists {
+				continue
+			}
+			schema.Properties[name] = promoted.Properties[name]
+			if slices.Contains(promoted.Required, name) {
+				schema.Required = append(schema.Required, name)
+			}
+		}
+	}
+
+	return schema, nil
+}
+
+// Validate checks decoded JSON against the schema and returns a problem
+// description for every violation, prefixed with its JSON path
+func (s *Schema) Validate(data interface{}) []string {
+	return s.validate(data, "$", nil)
+}
+
+func (s *Schema) validate(data interface{}, path string, problems []string) []string {
+	switch s.Type {
+	case "object":
+		object, ok := data.(map[string]interface{})
+		if !ok {
+			return append(problems, fmt.Sprintf("%s: expected an object, got %s", path, describeJSON(data)))
+		}
+		for _, name := range s.Required {
+			if _, exists := object[name]; !exists {
+				problems = append(problems, fmt.Sprintf("%s: missing required property %q", path, name))
+			}
+		}
+		names := make([]string, 0, len(object))
+		for name := range object {
+			names = append(names, name)
+		}
+		slices.Sort(names)
+		for _, name := range names {
+			value := object[name]
+			if property, ok := s.Properties[name]; ok {
+				problems = property.validate(value, path+"."+name, problems)
+				continue
+			}
+			switch additional := s.AdditionalProperties.(type) {
+			case bool:
+				if !additional {
+					problems = append(problems, fmt.Sprintf("%s: unexpected property %q", path, name))
+				}
+			case *Schema:
+				problems = additional.validate(value, path+"."+name, problems)
+			}
+		}
+		return problems
+
+	case "array":
+		array, ok := data.([]interface{})
+		if !ok {
+			return append(problems, fmt.Sprintf("%s: expected an array, got %s", path, describeJSON(data)))
+		}
+		if s.Items != nil {
+			for i, item := range array {
+				problems = s.Items.validate(item, fmt.Sprintf("%s[%d]", path, i), problems)
+			}
+		}
+		return problems
+
+	case "string":
+		value, ok := data.(string)
+		if !ok {
+			return append(problems, fmt.Sprintf("%s: expected a string, got %s", path, describeJSON(data)))
+		}
+		if len(s.Enum) > 0 && !slices.Contains(s.Enum, value) {
+			problems = append(problems, fmt.Sprintf("%s: %q is not one of %s", path, value, strings.Join(s.Enum, ", ")))
+		}
+		return problems
+
+	case "number", "integer":
+		value, ok := data.(float64)
+		if !ok {
+			expected := "a number"
+			if s.Type == "integer" {
+				expected = "an integer"
+			}
+			return append(problems, fmt.Sprintf("%s: expected %s, got %s", path, expected, describeJSON(data)))
+		}
+		if s.Type == "integer" && value != float64(int64(value)) {
+			problems = append(problems, fmt.Sprintf("%s: expected an integer, got %v", path, value))
+		}
+		if s.Minimum != nil && value < *s.Minimum {
+			problems = append(problems, fmt.Sprintf("%s: %v is below the minimum of %v", path, value, *s.Minimum))
+		}
+		if s.Maximum != nil && value > *s.Maximum {
+			problems = append(problems, fmt.Sprintf("%s: %v is above the maximum of %v", path, value, *s.Maximum))
+		}
+		return problems
+
+	case "boolean":
+		if _, ok := data.(bool); !ok {
+			return append(problems, fmt.Sprintf("%s: expected a boolean, got %s", path, describeJSON(data)))
+		}
+		return problems
+
+	default:
+		return problems
+	}
+}
+
+// describeJSON names the JSON type of a decoded value for problem descriptions
+func describeJSON(data interface{}) string {
+	switch data.(type) {
+	case nil:
+		return "null"
+	case map[string]interface{}:
+		return "an object"
+	case []interface{}:
+		return "an array"
+	case string:
+		return "a string"
+	case float64:
+		return "a number"
+	case bool:
+		return "a boolean"
+	default:
+		return fmt.Sprintf("%T", data)
+	}
+}
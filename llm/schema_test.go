@@ -0,0 +1,171 @@
+package llm
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+type schemaTestResult struct {
+	Name  string            `json:"name"`
+	Mood  string            `json:"mood" jsonschema:"enum=happy,enum=sad"`
+	Count int               `json:"count" jsonschema:"minimum=0,maximum=10"`
+	Score float64           `json:"score,omitempty"`
+	Tags  []string          `json:"tags,omitempty"`
+	Extra map[string]int    `json:"extra,omitempty"`
+	Note  string            `json:"note,omitempty" jsonschema:"required"`
+	Items []schemaTestEntry `json:"items,omitempty"`
+}
+
+type schemaTestEntry struct {
+	ID string `json:"id"`
+}
+
+func TestSchemaValidate(t *testing.T) {
+	schema, err := GenerateSchema(schemaTestResult{})
+	if err != nil {
+		t.Fatalf("GenerateSchema: %v", err)
+	}
+
+	tests := []struct {
+		name string
+		json string
+		want []string
+	}{
+		{
+			name: "valid",
+			json: `{"name": "a", "mood": "happy", "count": 3, "note": "", "score": 1.5, "tags": ["x"], "extra": {"k": 1}, "items": [{"id": "1"}]}`,
+		},
+		{
+			name: "missing required properties",
+			json: `{"name": "a", "count": 1}`,
+			want: []string{`$: missing required property "mood"`, `$: missing required property "note"`},
+		},
+		{
+			name: "value outside the enum",
+			json: `{"name": "a", "mood": "angry", "count": 1, "note": ""}`,
+			want: []string{`$.mood: "angry" is not one of happy, sad`},
+		},
+		{
+			name: "unexpected property",
+			json: `{"name": "a", "mood": "sad", "count": 1, "note": "", "color": "red"}`,
+			want: []string{`$: unexpected property "color"`},
+		},
+		{
+			name: "fraction for an integer",
+			json: `{"name": "a", "mood": "sad", "count": 1.5, "note": ""}`,
+			want: []string{`$.count: expected an integer, got 1.5`},
+		},
+		{
+			name: "integer for a number",
+			json: `{"name": "a", "mood": "sad", "count": 1, "note": "", "score": 2}`,
+		},
+		{
+			name: "bounds",
+			json: `{"name": "a", "mood": "sad", "count": 11, "note": ""}`,
+			want: []string{`$.count: 11 is above the maximum of 10`},
+		},
+		{
+			name: "wrong types",
+			json: `{"name": 1, "mood": "sad", "count": "1", "note": "", "tags": "x"}`,
+			want: []string{`$.count: expected an integer, got a string`, `$.name: expected a string, got a number`, `$.tags: expected an array, got a string`},
+		},
+		{
+			name: "nested values",
+			json: `{"name": "a", "mood": "sad", "count": 1, "note": "", "extra": {"k": "v"}, "items": [{"id": "1"}, {}]}`,
+			want: []string{`$.extra.k: expected an integer, got a string`, `$.items[1]: missing required property "id"`},
+		},
+		{
+			name: "not an object",
+			json: `[]`,
+			want: []string{`$: expected an object, got an array`},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var data interface{}
+			if err := json.Unmarshal([]byte(tt.json), &data); err != nil {
+				t.Fatalf("Unmarshal: %v", err)
+			}
+			if got := schema.Validate(data); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Validate = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDecodeStructuredOutput(t *testing.T) {
+	schema, err := GenerateSchema(schemaTestEntry{})
+	if err != nil {
+		t.Fatalf("GenerateSchema: %v", err)
+	}
+
+	var result schemaTestEntry
+	if err := decodeStructuredOutput("```json\n{\"id\": \"1\"}\n```", schema, &result); err != nil || result.ID != "1" {
+		t.Errorf("fenced JSON: result = %+v, err = %v", result, err)
+	}
+
+	var validationErr *ValidationError
+	err = decodeStructuredOutput(`{"id": 1}`, schema, &result)
+	if !errors.As(err, &validationErr) || validationErr.Content != `{"id": 1}` || len(validationErr.Problems) != 1 {
+		t.Errorf("invalid value: err = %v", err)
+	}
+	err = decodeStructuredOutput(`not json`, schema, &result)
+	if !errors.As(err, &validationErr) || validationErr.Content != "not json" {
+		t.Errorf("invalid JSON: err = %v", err)
+	}
+}
+
+type schemaTestBase struct {
+	ID      string `json:"id"`
+	Created string `json:"created,omitempty"`
+}
+
+type schemaTestAudit struct {
+	By string `json:"by"`
+}
+
+type schemaTestEmbedding struct {
+	schemaTestBase
+	*schemaTestAudit
+	Entry   schemaTestEntry `json:"entry"` // Tagged, so nested
+	Created int             `json:"created"`
+}
+
+func TestGenerateSchemaEmbeddedStructs(t *testing.T) {
+	schema, err := GenerateSchema(schemaTestEmbedding{})
+	if err != nil {
+		t.Fatalf("GenerateSchema: %v", err)
+	}
+
+	types := make(map[string]string)
+	for name, property := range schema.Properties {
+		types[name] = property.Type
+	}
+	want := map[string]string{"id": "string", "by": "string", "entry": "object", "created": "integer"}
+	if !reflect.DeepEqual(types, want) {
+		t.Errorf("properties = %v, want %v", types, want)
+	}
+	if !reflect.DeepEqual(schema.Required, []string{"entry", "created", "id", "by"}) {
+		t.Errorf("required = %v", schema.Required)
+	}
+
+	// What encoding/json produces matches the schema
+	data, err := json.Marshal(schemaTestEmbedding{
+		schemaTestBase:  schemaTestBase{ID: "1"},
+		schemaTestAudit: &schemaTestAudit{By: "me"},
+		Entry:           schemaTestEntry{ID: "2"},
+		Created:         3,
+	})
+	if err != nil {
+		t.Fatalf("Marshal: %v", err)
+	}
+	var decoded interface{}
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatalf("Unmarshal: %v", err)
+	}
+	if problems := schema.Validate(decoded); len(problems) > 0 {
+		t.Errorf("encoded %s violates the schema: %v", data, problems)
+	}
+}
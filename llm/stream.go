@@ -0,0 +1,95 @@
+package llm
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// StreamEventType identifies the kind of event delivered on a completion stream
+type StreamEventType string
+
+const (
+	// StreamEventContent carries a content delta in Content
+	StreamEventContent StreamEventType = "content"
+	// StreamEventToolCall reports a tool call requested by the model in ToolCall
+	StreamEventToolCall StreamEventType = "tool_call"
+	// StreamEventToolResult reports the outcome of an executed tool call in ToolResult
+	StreamEventToolResult StreamEventType = "tool_result"
+	// StreamEventDone carries the final assembled message in Message
+	StreamEventDone StreamEventType = "done"
+	// StreamEventError reports a failure in Err; no further events follow
+	StreamEventError StreamEventType = "error"
+)
+
+// StreamEvent is a single event on a completion stream.
+// The stream channel is closed after a StreamEventDone or StreamEventError event.
+type StreamEvent struct {
+	Type       StreamEventType
+	Content    string
+	ToolCall   *ToolCall
+	ToolResult *ToolExecution
+	Message    *Message
+	Err        error
+}
+
+// sendStreamEvent delivers an event unless the context is cancelled first.
+// Returns false if the consumer is gone and the producer should stop.
+func sendStreamEvent(ctx context.Context, events chan<- StreamEvent, event StreamEvent) bool {
+	select {
+	case events <- event:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// readSSE reads a server-sent events body and invokes fn with the event name
+// and data of every event. Reading stops at EOF, on a "[DONE]" data line,
+// or when fn returns an error.
+func readSSE(body io.Reader, fn func(event, data string) error) error {
+	scanner := bufio.NewScanner(body)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+
+	var event string
+	var data strings.Builder
+	flush := func() error {
+		defer func() {
+			event = ""
+			data.Reset()
+		}()
+		if data.Len() == 0 {
+			return nil
+		}
+		return fn(event, data.String())
+	}
+
+	for scanner.Scan() {
+		line := scanner.Text()
+		switch {
+		case line == "":
+			if err := flush(); err != nil {
+				return err
+			}
+		case strings.HasPrefix(line, ":"):
+			// comment / keep-alive
+		case strings.HasPrefix(line, "event:"):
+			event = strings.TrimSpace(strings.TrimPrefix(line, "event:"))
+		case strings.HasPrefix(line, "data:"):
+			value := strings.TrimSpace(strings.TrimPrefix(line, "data:"))
+			if value == "[DONE]" {
+				return flush()
+			}
+			if data.Len() > 0 {
+				data.WriteString("\n")
+			}
+			data.WriteString(value)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("failed to read event stream: %w", err)
+	}
+	return flush()
+}
@@ -0,0 +1,61 @@
+package llm
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/soralabs/zen/internal/utils"
+)
+
+// DefaultMaxStructuredRepairs is the number of times invalid structured output
+// is sent back to the model when Config.MaxStructuredRepairs is not set
+const DefaultMaxStructuredRepairs = 2
+
+// decodeStructuredOutput strips Markdown code fences from content, validates it
+// against the schema and unmarshals it into result. Violations are returned as
+// a ValidationError.
+func decodeStructuredOutput(content string, schema *Schema, result interface{}) error {
+	var data interface{}
+	if err := utils.SmartUnmarshal([]byte(content), &data); err != nil {
+		return &ValidationError{Problems: []string{fmt.Sprintf("invalid JSON: %v", err)}, Content: content}
+	}
+	if problems := schema.Validate(data); len(problems) > 0 {
+		return &ValidationError{Problems: problems, Content: content}
+	}
+	return utils.SmartUnmarshal([]byte(content), result)
+}
+
+// runValidator runs a request's Validator on the decoded result and turns its
+// error into a ValidationError
+func runValidator(validator func(result interface{}) error, result interface{}) error {
+	err := validator(result)
+	if err == nil {
+		return nil
+	}
+
+	var validationErr *ValidationError
+	if !errors.As(err, &validationErr) {
+		validationErr = &ValidationError{Problems: []string{err.Error()}}
+	}
+	if validationErr.Content == "" {
+		content, err := json.Marshal(result)
+		if err != nil {
+			return fmt.Errorf("failed to encode structured output: %w", err)
+		}
+		validationErr.Content = string(content)
+	}
+	return validationErr
+}
+
+// repairMessages returns the rejected reply followed by a request to correct it
+func repairMessages(err *ValidationError) []Message {
+	var messages []Message
+	if err.Content != "" {
+		messages = append(messages, NewAssistantMessage(err.Content))
+	}
+	return append(messages, NewUserMessage(fmt.Sprintf(
+		"Your JSON was rejected:\n- %s\n\nRespond with the corrected JSON object only.",
+		strings.Join(err.Problems, "\n- "))))
+}
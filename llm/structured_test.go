@@ -0,0 +1,125 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// scriptedStructuredProvider answers structured output attempts with the
+// given replies in order, decoding them like the HTTP providers
+func scriptedStructuredProvider(replies ...string) (*funcProvider, func() [][]Message) {
+	var mu sync.Mutex
+	var requests [][]Message
+	provider := &funcProvider{
+		caps: allCapabilities,
+		structured: func(ctx context.Context, req StructuredOutputRequest, result interface{}) (Usage, error) {
+			mu.Lock()
+			attempt := len(requests)
+			requests = append(requests, req.Messages)
+			mu.Unlock()
+
+			schema, err := GenerateSchema(result)
+			if err != nil {
+				return Usage{}, err
+			}
+			reply := replies[min(attempt, len(replies)-1)]
+			return Usage{Model: "test", PromptTokens: 10, TotalTokens: 10}, decodeStructuredOutput(reply, schema, result)
+		},
+	}
+	return provider, func() [][]Message {
+		mu.Lock()
+		defer mu.Unlock()
+		return requests
+	}
+}
+
+type sentiment struct {
+	Label string `json:"label" jsonschema:"enum=positive,enum=negative"`
+	Score int    `json:"score"`
+}
+
+func TestStructuredOutputRepairs(t *testing.T) {
+	provider, requests := scriptedStructuredProvider(
+		`{"label": "great", "score": 1}`,
+		`{"label": "positive", "score": 50}`,
+		`{"label": "positive", "score": 5}`,
+	)
+	client := newTestClient(provider)
+	recorder := &usageLog{}
+	client.usageRecorder = recorder
+
+	var result sentiment
+	err := client.GenerateStructuredOutput(StructuredOutputRequest{
+		Messages: []Message{NewUserMessage("Rate: I love it")},
+		Validator: func(interface{}) error {
+			if result.Score > 10 {
+				return errors.New("score must be at most 10")
+			}
+			return nil
+		},
+	}, &result)
+	if err != nil {
+		t.Fatalf("GenerateStructuredOutput: %v", err)
+	}
+	if result != (sentiment{Label: "positive", Score: 5}) {
+		t.Errorf("result = %+v", result)
+	}
+
+	// Each repair sends back the rejected reply and its problems
+	sent := requests()
+	if len(sent) != 3 {
+		t.Fatalf("attempts = %d, want 3", len(sent))
+	}
+	first := sent[1]
+	if len(first) != 3 || first[1].Role != RoleAssistant || first[1].Content != `{"label": "great", "score": 1}` {
+		t.Errorf("first repair messages = %+v", first)
+	}
+	if first[2].Role != RoleUser || !strings.Contains(first[2].Content, `$.label: "great" is not one of positive, negative`) {
+		t.Errorf("first repair request = %q", first[2].Content)
+	}
+	second := sent[2]
+	if len(second) != 5 || !strings.Contains(second[3].Content, `"score":50`) || !strings.Contains(second[4].Content, "score must be at most 10") {
+		t.Errorf("second repair messages = %+v", second)
+	}
+	if len(recorder.records) != 3 {
+		t.Errorf("usage records = %d, want one per attempt", len(recorder.records))
+	}
+}
+
+func TestStructuredOutputGivesUp(t *testing.T) {
+	provider, requests := scriptedStructuredProvider(`{"label": "great", "score": 1}`)
+	client := newTestClient(provider)
+
+	var result sentiment
+	err := client.GenerateStructuredOutput(StructuredOutputRequest{
+		Messages: []Message{NewUserMessage("Rate: I love it")},
+	}, &result)
+
+	var validationErr *ValidationError
+	if !errors.As(err, &validationErr) || !strings.Contains(validationErr.Error(), `"great" is not one of`) {
+		t.Errorf("err = %v, want the last ValidationError", err)
+	}
+	if attempts := len(requests()); attempts != 1+DefaultMaxStructuredRepairs {
+		t.Errorf("attempts = %d, want %d", attempts, 1+DefaultMaxStructuredRepairs)
+	}
+}
+
+func TestStructuredOutputProviderErrorNotRepaired(t *testing.T) {
+	attempts := 0
+	provider := &funcProvider{caps: allCapabilities, structured: func(ctx context.Context, req StructuredOutputRequest, result interface{}) (Usage, error) {
+		attempts++
+		return Usage{}, errors.New("bad request")
+	}}
+	client := newTestClient(provider)
+
+	var result sentiment
+	if err := client.GenerateStructuredOutput(StructuredOutputRequest{Messages: []Message{NewUserMessage("Rate")}}, &result); err == nil {
+		t.Fatal("GenerateStructuredOutput succeeded")
+	}
+	if attempts != 1 {
+		t.Errorf("attempts = %d, want 1", attempts)
+	}
+}
@@ -0,0 +1,213 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+
+	toolkit "github.com/soralabs/toolkit/go"
+)
+
+// DefaultMaxToolSteps is the number of tool-calling rounds allowed per completion
+// when Config.MaxToolSteps is not set
+const DefaultMaxToolSteps = 5
+
+// ErrMaxToolStepsExceeded is returned when the model keeps requesting tools
+// after the configured number of tool-calling rounds
+var ErrMaxToolStepsExceeded = errors.New("maximum tool steps exceeded")
+
+// ToolExecution records a single tool call made while generating a completion
+type ToolExecution struct {
+	Step      int           // Tool-calling round the call was made in, starting at 1
+	CallID    string        // ID of the tool call
+	Name      string        // Name of the called tool
+	Arguments string        // Raw JSON arguments supplied by the model
+	Result    string        // Raw tool output, empty if the call failed
+	Error     string        // Error message if the call failed
+	Duration  time.Duration // Time spent executing the tool
+}
+
+// CompletionResult holds the final message of a completion together with
+// the trace of every tool call made to produce it
+type CompletionResult struct {
+	Message Message
+	Trace   []ToolExecution
+
+	// The request's messages followed by the tool-calling rounds
+	rounds []Message
+}
+
+// runToolLoop drives a completion through repeated tool-calling rounds until
+// the model returns a message without tool calls or maxSteps is exceeded.
+// Returns the accumulated usage of all provider calls, each priced at the
+// rate of its own model. The final message
+// carries the reasoning of all rounds.
+func (c *LLMClient) runToolLoop(ctx context.Context, req CompletionRequest) (CompletionResult, Usage, error) {
+	var result CompletionResult
+	var usage Usage
+	var reasoning string
+	messages := append([]Message(nil), req.Messages...)
+
+	for step := 1; ; step++ {
+		stepReq := req
+		stepReq.Messages = messages
+
+		message, err := c.complete(ctx, stepReq)
+		if message.Usage != nil {
+			usage.Add(c.prices.price(*message.Usage))
+		}
+		result.rounds = messages
+		if err != nil {
+			return result, usage, err
+		}
+		reasoning = joinReasoning(reasoning, message.Reasoning)
+		if len(message.ToolCalls) == 0 {
+			result.Message = message
+			result.Message.Reasoning = reasoning
+			return result, usage, nil
+		}
+		if step > c.maxToolSteps {
+			result.Message = message
+			result.Message.Reasoning = reasoning
+			return result, usage, fmt.Errorf("%w: model requested tools after %d steps", ErrMaxToolStepsExceeded, c.maxToolSteps)
+		}
+
+		executions := executeToolCalls(ctx, req.Tools, message.ToolCalls, step)
+		result.Trace = append(result.Trace, executions...)
+		messages = appendToolResults(messages, message, executions)
+	}
+}
+
+// joinReasoning appends the reasoning of a tool-calling round to that of the
+// earlier rounds
+func joinReasoning(earlier, reasoning string) string {
+	if earlier == "" || reasoning == "" {
+		return earlier + reasoning
+	}
+	return earlier + "\n\n" + reasoning
+}
+
+// executeToolCalls runs all tool calls of a single round in parallel and
+// returns their executions in call order
+func executeToolCalls(ctx context.Context, tools []toolkit.Tool, calls []ToolCall, step int) []ToolExecution {
+	executions := make([]ToolExecution, len(calls))
+
+	var wg sync.WaitGroup
+	for i, call := range calls {
+		wg.Add(1)
+		go func(i int, call ToolCall) {
+			defer wg.Done()
+			executions[i] = executeToolCall(ctx, tools, call, step)
+		}(i, call)
+	}
+	wg.Wait()
+
+	return executions
+}
+
+// executeToolCall runs the tool matching the call name and records the outcome
+func executeToolCall(ctx context.Context, tools []toolkit.Tool, call ToolCall, step int) (execution ToolExecution) {
+	execution = ToolExecution{
+		Step:      step,
+		CallID:    call.ID,
+		Name:      call.Name,
+		Arguments: call.Arguments,
+	}
+
+	start := time.Now()
+	defer func() {
+		execution.Duration = time.Since(start)
+	}()
+
+	for _, tool := range tools {
+		if tool.GetName() == call.Name {
+			output, err := tool.Execute(ctx, json.RawMessage(call.Arguments))
+			if err != nil {
+				execution.Error = fmt.Sprintf("tool execution error: %v", err)
+				return execution
+			}
+			execution.Result = string(output)
+			return execution
+		}
+	}
+
+	execution.Error = fmt.Sprintf("function %s not found", call.Name)
+	return execution
+}
+
+// appendToolResults appends the assistant's tool calls and one tool message
+// per execution to the conversation. Failed calls are reported back to the
+// model so it can recover.
+func appendToolResults(messages []Message, assistant Message, executions []ToolExecution) []Message {
+	messages = append(messages, assistant)
+	for _, execution := range executions {
+		result := NewToolResultMessage(execution.Result, execution.Name, execution.CallID)
+		if execution.Error != "" {
+			result.Content = fmt.Sprintf("error: %s", execution.Error)
+			result.ToolError = true
+		}
+		messages = append(messages, result)
+	}
+	return messages
+}
+
+// mergeToolCallDelta adds a streamed tool call fragment to the calls. The
+// first fragment of a call carries its ID and name, later ones the arguments.
+// Fragments without a valid index continue the last call unless they start a
+// new one, and indexes past the end start a new call.
+func mergeToolCallDelta(calls []ToolCall, index *int, id, name, arguments string) []ToolCall {
+	i := len(calls) - 1
+	if index != nil && *index >= 0 {
+		i = min(*index, len(calls))
+	} else if id != "" {
+		i = len(calls)
+	}
+	i = max(i, 0)
+	for len(calls) <= i {
+		calls = append(calls, ToolCall{})
+	}
+
+	if id != "" {
+		calls[i].ID = id
+	}
+	calls[i].Name += name
+	calls[i].Arguments += arguments
+	return calls
+}
+
+// pairToolCalls returns the messages with IDs filled in for tool calls and
+// tool results that lack them, e.g. history recorded without call IDs. A tool
+// result without an ID answers the next unanswered call of the preceding
+// assistant message.
+func pairToolCalls(messages []Message) []Message {
+	paired := make([]Message, len(messages))
+	var pending []string
+	for i, msg := range messages {
+		switch {
+		case len(msg.ToolCalls) > 0:
+			calls := make([]ToolCall, len(msg.ToolCalls))
+			pending = pending[:0]
+			for j, call := range msg.ToolCalls {
+				if call.ID == "" {
+					call.ID = fmt.Sprintf("call_%d_%d", i, j)
+				}
+				calls[j] = call
+				pending = append(pending, call.ID)
+			}
+			msg.ToolCalls = calls
+		case msg.Role == RoleTool:
+			if msg.ToolCallID == "" && len(pending) > 0 {
+				msg.ToolCallID = pending[0]
+			}
+			if k := slices.Index(pending, msg.ToolCallID); k >= 0 {
+				pending = slices.Delete(pending, k, k+1)
+			}
+		}
+		paired[i] = msg
+	}
+	return paired
+}
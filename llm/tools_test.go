@@ -0,0 +1,82 @@
+package llm
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestMergeToolCallDelta(t *testing.T) {
+	index := func(i int) *int { return &i }
+
+	type delta struct {
+		index               *int
+		id, name, arguments string
+	}
+	tests := []struct {
+		name   string
+		deltas []delta
+		want   []ToolCall
+	}{
+		{
+			name: "indexed fragments",
+			deltas: []delta{
+				{index: index(0), id: "call_a", name: "search"},
+				{index: index(1), id: "call_b", name: "post"},
+				{index: index(0), arguments: `{"q":`},
+				{index: index(0), arguments: `"go"}`},
+				{index: index(1), arguments: `{}`},
+			},
+			want: []ToolCall{
+				{ID: "call_a", Name: "search", Arguments: `{"q":"go"}`},
+				{ID: "call_b", Name: "post", Arguments: `{}`},
+			},
+		},
+		{
+			name: "fragments without index continue the last call",
+			deltas: []delta{
+				{id: "call_a", name: "search"},
+				{arguments: `{"q":"go"}`},
+				{id: "call_b", name: "post", arguments: `{}`},
+			},
+			want: []ToolCall{
+				{ID: "call_a", Name: "search", Arguments: `{"q":"go"}`},
+				{ID: "call_b", Name: "post", Arguments: `{}`},
+			},
+		},
+		{
+			name: "first fragment without index or id",
+			deltas: []delta{
+				{name: "search"},
+				{arguments: `{}`},
+			},
+			want: []ToolCall{{Name: "search", Arguments: `{}`}},
+		},
+		{
+			name: "negative index",
+			deltas: []delta{
+				{index: index(-1), id: "call_a", name: "search"},
+				{index: index(-1), arguments: `{}`},
+			},
+			want: []ToolCall{{ID: "call_a", Name: "search", Arguments: `{}`}},
+		},
+		{
+			name: "index past the end",
+			deltas: []delta{
+				{index: index(5), id: "call_a", name: "search", arguments: `{}`},
+			},
+			want: []ToolCall{{ID: "call_a", Name: "search", Arguments: `{}`}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var calls []ToolCall
+			for _, d := range tt.deltas {
+				calls = mergeToolCallDelta(calls, d.index, d.id, d.name, d.arguments)
+			}
+			if !reflect.DeepEqual(calls, tt.want) {
+				t.Errorf("got %+v, want %+v", calls, tt.want)
+			}
+		})
+	}
+}
@@ -10,15 +10,29 @@ import (
 type ProviderType string
 
 const (
-	ProviderOpenAI   ProviderType = "openai"
-	ProviderDeepseek ProviderType = "deepseek"
+	ProviderOpenAI    ProviderType = "openai"
+	ProviderDeepseek  ProviderType = "deepseek"
+	ProviderAnthropic ProviderType = "anthropic"
+	ProviderGemini    ProviderType = "gemini"
+	ProviderLocal     ProviderType = "local"   // Embedding-only, in-process model loaded from ModelPath
+	ProviderHashing   ProviderType = "hashing" // Embedding-only, deterministic feature hashing for tests
 )
 
 // ProviderConfig holds the configuration for a specific provider
 type ProviderConfig struct {
-	Type        ProviderType
-	APIKey      string
-	ModelConfig map[ModelType]string // Maps capability levels to specific model names
+	Type                ProviderType
+	APIKey              string
+	BaseURL             string                  // Overrides the provider's API endpoint, e.g. for OpenAI-compatible servers
+	Headers             map[string]string       // Extra headers sent with every request
+	ModelConfig         map[ModelType]string    // Maps capability levels to specific model names
+	EmbeddingModel      string                  // Model used for embeddings, defaults to the provider's embedding model
+	ModelPath           string                  // Model file of the local provider
+	EmbeddingDimensions int                     // Output dimension for models that support shortening, e.g. text-embedding-3-*
+	EmbeddingBatchSize  int                     // Inputs per embedding request, defaults to the provider's limit
+	RateLimit           *RateLimit              // Limits all calls to this provider
+	ModelRateLimits     map[ModelType]RateLimit // Additional limits per capability level
+	Recording           *RecordingConfig        // Records calls to or replays them from a fixture file
+	ThinkingBudget      int                     // Tokens Anthropic models may spend on extended thinking, disabled if zero
 }
 
 // Config holds the configuration for the LLM client
@@ -26,7 +40,24 @@ type Config struct {
 	DefaultProvider ProviderConfig
 	// Specific providers for different capabilities
 	EmbeddingProvider *ProviderConfig // If nil, uses DefaultProvider
-	ChatProvider      *ProviderConfig // If nil, uses DefaultProvider
-	Logger            *logger.Logger
-	Context           context.Context
+	ChatProvider      *ProviderConfig // Serves chat calls no route matches. If nil, uses DefaultProvider
+	// Routes send chat calls to providers and models by caller, model type,
+	// schema or size. The first matching route wins.
+	Routes []Route
+	// Providers tried in order when the chat or embedding provider keeps failing.
+	// Embedding fallbacks must use the same embedding model, otherwise their
+	// results are rejected with ErrEmbeddingModelMismatch.
+	ChatFallbacks        []ProviderConfig
+	EmbeddingFallbacks   []ProviderConfig
+	EmbeddingCache       *EmbeddingCacheConfig // If set, embeddings are cached by content hash
+	Retry                *RetryConfig          // If nil, uses DefaultRetryConfig
+	ContextWindow        *ContextWindowConfig  // If set, requests are shortened to fit the model's context window
+	MaxToolSteps         int                   // Tool-calling rounds per completion, defaults to DefaultMaxToolSteps
+	MaxStructuredRepairs int                   // Repairs of structured output that fails validation, defaults to DefaultMaxStructuredRepairs
+	Prices               PriceTable            // Overrides and additions to DefaultPrices
+	UsageRecorder        UsageRecorder         // If set, receives a record of every call's token usage and cost
+	Interceptors         []Interceptor         // Wrap every model call, the first runs outermost
+	Cascade              *CascadeConfig        // If set, advanced calls are first answered by the fast model
+	Logger               *logger.Logger
+	Context              context.Context
 }
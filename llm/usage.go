@@ -0,0 +1,94 @@
+package llm
+
+import (
+	"time"
+
+	"github.com/soralabs/zen/id"
+)
+
+// Operation identifies the kind of LLM call a usage record belongs to
+type Operation string
+
+const (
+	OperationCompletion       Operation = "completion"
+	OperationStructuredOutput Operation = "structured_output"
+	OperationEmbedding        Operation = "embedding"
+)
+
+// Usage holds the token counts reported by a provider for a single call.
+// Reasoning tokens are a subset of completion tokens.
+type Usage struct {
+	Provider         ProviderType
+	Model            string
+	PromptTokens     int
+	CompletionTokens int
+	ReasoningTokens  int
+	TotalTokens      int
+	Cost             float64 // Cost in USD, filled in by LLMClient from the price table
+}
+
+// Add accumulates the token counts and cost of another usage. The provider
+// and model of the first usage are kept, so usages of different models must
+// be priced before they are added.
+func (u *Usage) Add(other Usage) {
+	if u.Provider == "" {
+		u.Provider = other.Provider
+	}
+	if u.Model == "" {
+		u.Model = other.Model
+	}
+	u.PromptTokens += other.PromptTokens
+	u.CompletionTokens += other.CompletionTokens
+	u.ReasoningTokens += other.ReasoningTokens
+	u.TotalTokens += other.TotalTokens
+	u.Cost += other.Cost
+}
+
+// UsageScope attributes LLM usage to the session, actor and manager that caused it
+type UsageScope struct {
+	SessionID id.ID
+	ActorID   id.ID
+	Manager   string
+}
+
+// UsageRecord is a single accounted LLM call
+type UsageRecord struct {
+	Scope     UsageScope
+	Operation Operation
+	Usage     Usage
+	CreatedAt time.Time
+}
+
+// UsageRecorder persists usage records, e.g. stores.UsageStore
+type UsageRecorder interface {
+	RecordUsage(record UsageRecord) error
+}
+
+// WithScope returns a client that shares this client's providers and
+// configuration but attributes all recorded usage to the given scope.
+func (c *LLMClient) WithScope(scope UsageScope) *LLMClient {
+	scoped := *c
+	scoped.scope = scope
+	return &scoped
+}
+
+// recordUsage prices the usage unless it is the sum of priced calls and hands
+// it to the configured recorder. Returns the priced usage.
+func (c *LLMClient) recordUsage(operation Operation, usage Usage) Usage {
+	usage = c.prices.price(usage)
+	if c.usageRecorder == nil || usage.PromptTokens+usage.CompletionTokens == 0 {
+		return usage
+	}
+
+	err := c.usageRecorder.RecordUsage(UsageRecord{
+		Scope:     c.scope,
+		Operation: operation,
+		Usage:     usage,
+		CreatedAt: time.Now(),
+	})
+	if err != nil && c.logger != nil {
+		c.logger.Warnf("Failed to record LLM usage: %v", err)
+	}
+
+	return usage
+}
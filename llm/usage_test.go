@@ -0,0 +1,79 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"math"
+	"sync"
+	"testing"
+
+	toolkit "github.com/soralabs/toolkit/go"
+)
+
+var testPrices = PriceTable{
+	"cheap":   {PromptPerMillion: 1},
+	"premium": {PromptPerMillion: 10},
+}
+
+// millionTokens is the usage of a call with a million prompt tokens
+func millionTokens(model string) *Usage {
+	return &Usage{Model: model, PromptTokens: 1000000, TotalTokens: 1000000}
+}
+
+func TestToolRoundsPricedPerModel(t *testing.T) {
+	// The second round falls back from the premium to the cheap provider
+	var mu sync.Mutex
+	premiumCalls := 0
+	premium := &funcProvider{caps: allCapabilities, complete: func(ctx context.Context, req CompletionRequest) (Message, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		premiumCalls++
+		if premiumCalls > 1 {
+			return Message{}, errors.New("unavailable")
+		}
+		return Message{
+			Role:      RoleAssistant,
+			ToolCalls: []ToolCall{{ID: "call_1", Name: "search", Arguments: `{"query":"go"}`}},
+			Usage:     millionTokens("premium"),
+		}, nil
+	}}
+	cheap := &funcProvider{caps: allCapabilities, complete: func(ctx context.Context, req CompletionRequest) (Message, error) {
+		return Message{Role: RoleAssistant, Content: "Done.", Usage: millionTokens("cheap")}, nil
+	}}
+	client := newTestClient(premium, cheap)
+	client.prices = testPrices
+	recorder := &usageLog{}
+	client.usageRecorder = recorder
+
+	msg, err := client.GenerateCompletion(CompletionRequest{
+		Messages: []Message{NewUserMessage("Search for go")},
+		Tools:    []toolkit.Tool{&fakeTool{name: "search", result: `{}`}},
+	})
+	if err != nil {
+		t.Fatalf("GenerateCompletion: %v", err)
+	}
+	if msg.Usage == nil || math.Abs(msg.Usage.Cost-11) > 1e-9 || msg.Usage.TotalTokens != 2000000 {
+		t.Errorf("usage = %+v, want 11 USD for both rounds", msg.Usage)
+	}
+	if len(recorder.records) != 1 || math.Abs(recorder.records[0].Usage.Cost-11) > 1e-9 {
+		t.Errorf("records = %+v", recorder.records)
+	}
+}
+
+func TestSummaryUsagePricedAtFastModel(t *testing.T) {
+	provider := &contextWindowProvider{prices: testPrices}
+	usage := provider.withSummaryUsage(*millionTokens("premium"), *millionTokens("cheap"))
+	if math.Abs(usage.Cost-11) > 1e-9 || usage.Model != "premium" || usage.PromptTokens != 2000000 {
+		t.Errorf("usage = %+v, want the call at 10 USD and the summary at 1 USD", usage)
+	}
+
+	// Without a summary the call stays unpriced for the client
+	if unpriced := provider.withSummaryUsage(*millionTokens("premium"), Usage{}); unpriced.Cost != 0 {
+		t.Errorf("usage without summary = %+v", unpriced)
+	}
+
+	// The client keeps the cost of priced sums
+	if got := testPrices.price(*usage); got.Cost != usage.Cost {
+		t.Errorf("repriced = %v, want %v", got.Cost, usage.Cost)
+	}
+}
@@ -7,6 +7,7 @@ import (
 
 	"github.com/soralabs/zen/db"
 	"github.com/soralabs/zen/id"
+	"github.com/soralabs/zen/llm"
 	"github.com/soralabs/zen/stores"
 )
 
@@ -308,7 +309,7 @@ func (im *InsightManager) getInsightData(message *db.Fragment) (*cachedInsightDa
 
 	// Get similar insights if message has an embedding
 	if len(message.Embedding.Slice()) > 0 {
-		similarInsights, err := im.FragmentStore.SearchSimilar(message.Embedding, message.SessionID, 3)
+		similarInsights, err := im.FragmentStore.SearchSimilar(message.Embedding, message.EmbeddingModel, message.SessionID, 3)
 		if err != nil {
 			return data, fmt.Errorf("failed to get similar insights: %w", err)
 		}
@@ -331,6 +332,36 @@ func formatInsight(id id.ID, content string, category string, confidence float64
 	)
 }
 
+// validateInsightResponse rejects insights about actors that did not take part
+// in the conversation and outdated IDs that do not belong to an existing insight
+func validateInsightResponse(response *InsightResponse, messages []db.Fragment, existingInsights []db.Fragment) error {
+	actorIDs := make(map[id.ID]bool, len(messages))
+	for _, message := range messages {
+		actorIDs[message.ActorID] = true
+	}
+	insightIDs := make(map[id.ID]bool, len(existingInsights))
+	for _, insight := range existingInsights {
+		insightIDs[insight.ID] = true
+	}
+
+	var problems []string
+	for i, insight := range response.NewInsights {
+		if !actorIDs[id.ID(insight.ActorID)] {
+			problems = append(problems, fmt.Sprintf("$.new_insights[%d].actor_id: %q is not the ID of an actor in the conversation", i, insight.ActorID))
+		}
+	}
+	for i, outdatedID := range response.OutdatedInsightIDs {
+		if !insightIDs[outdatedID] {
+			problems = append(problems, fmt.Sprintf("$.outdated_insight_ids[%d]: %q is not the ID of an existing insight", i, outdatedID))
+		}
+	}
+
+	if len(problems) > 0 {
+		return &llm.ValidationError{Problems: problems}
+	}
+	return nil
+}
+
 // removeInsightByID removes an insight from a slice by its ID
 // Used when insights become outdated or irrelevant
 func removeInsightByID(insights []db.Fragment, idToRemove id.ID) []db.Fragment {
@@ -2,6 +2,7 @@ package insight
 
 import (
 	"fmt"
+	"slices"
 	"time"
 
 	"github.com/soralabs/zen/cache"
@@ -83,7 +84,8 @@ func (im *InsightManager) Process(currentState *state.State) error {
 	}
 
 	// Include current message in history
-	messageHistory := formatMessageHistory(append(recentMessages, *currentState.Input))
+	conversation := append(recentMessages, *currentState.Input)
+	messageHistory := formatMessageHistory(conversation)
 
 	// Get existing session insights
 	sessionInsights, err := im.FragmentStore.SearchByFilter(stores.FragmentFilter{
@@ -161,19 +163,33 @@ GUIDELINES:
 			existingInsights)),
 	}
 
+	llmClient := im.LLM.WithScope(llm.UsageScope{
+		SessionID: currentState.Input.SessionID,
+		ActorID:   currentState.Input.ActorID,
+		Manager:   string(im.GetID()),
+	})
+
 	var result InsightResponse
+	var cascade llm.CascadeTrace
 	// Generate insights using LLM
-	err = im.LLM.GenerateStructuredOutput(llm.StructuredOutputRequest{
+	err = llmClient.GenerateStructuredOutputContext(currentState.Context(), llm.StructuredOutputRequest{
 		Messages:     messages,
 		ModelType:    llm.ModelTypeAdvanced,
 		SchemaName:   "insight_extraction",
 		StrictSchema: true,
+		// Insights must reference actors and existing insights of this session
+		Validator: func(interface{}) error {
+			return validateInsightResponse(&result, conversation, slices.Concat(sessionInsights, actorInsights))
+		},
+		Cascade: &cascade,
 	}, &result)
 	if err != nil {
 		return fmt.Errorf("failed to generate insights: %w", err)
 	}
 
-	// Store new insights and update embeddings
+	// Build fragments for new insights
+	var insightTypes []string
+	var insightFragments []*db.Fragment
 	for _, insight := range result.NewInsights {
 		// Get actor information first
 		actor, err := im.ActorStore.GetByID(id.ID(insight.ActorID))
@@ -182,7 +198,8 @@ GUIDELINES:
 			continue
 		}
 
-		insightFragment := &db.Fragment{
+		insightTypes = append(insightTypes, insight.Type)
+		insightFragments = append(insightFragments, &db.Fragment{
 			ID:        id.New(),
 			ActorID:   id.ID(insight.ActorID),
 			SessionID: currentState.Input.SessionID,
@@ -195,24 +212,40 @@ GUIDELINES:
 				"timestamp":      time.Now().Unix(),
 			},
 			Actor: actor, // Set the actor explicitly
-		}
+		})
+	}
 
-		// Generate embedding for semantic search
-		embedding, err := im.LLM.EmbedText(insight.Content)
-		if err != nil {
-			im.Logger.Warnf("Failed to generate embedding for insight: %v", err)
-			continue
+	// Keep both answers of a cascaded call for auditing
+	if cascade != (llm.CascadeTrace{}) {
+		for _, insightFragment := range insightFragments {
+			insightFragment.Metadata["cascade"] = cascade.Metadata()
 		}
-		insightFragment.Embedding = pgvector.NewVector(embedding)
+	}
+
+	// Generate embeddings for semantic search in a single batch
+	contents := make([]string, len(insightFragments))
+	for i, insightFragment := range insightFragments {
+		contents[i] = insightFragment.Content
+	}
+	embeddings, err := llmClient.EmbedTextsContext(currentState.Context(), contents)
+	if err != nil {
+		im.Logger.Warnf("Failed to generate embeddings for insights: %v", err)
+		insightFragments = nil
+	}
+
+	// Store new insights and update embeddings
+	for i, insightFragment := range insightFragments {
+		insightFragment.Embedding = pgvector.NewVector(embeddings[i])
+		insightFragment.EmbeddingModel = llmClient.EmbeddingModel()
 
 		if err := im.FragmentStore.Create(insightFragment); err != nil {
 			return fmt.Errorf("failed to store new insight: %w", err)
 		}
 
 		// Update cached data with new insight
-		if insight.Type == string(SessionInsights) {
+		if insightTypes[i] == string(SessionInsights) {
 			insightData.SessionInsights = append(insightData.SessionInsights, *insightFragment)
-		} else if insight.Type == string(ActorInsights) {
+		} else if insightTypes[i] == string(ActorInsights) {
 			insightData.ActorInsights = append(insightData.ActorInsights, *insightFragment)
 		}
 	}
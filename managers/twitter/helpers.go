@@ -1,6 +1,7 @@
 package twitter_manager
 
 import (
+	"context"
 	"fmt"
 	"strings"
 
@@ -8,6 +9,7 @@ import (
 	"github.com/soralabs/zen/db"
 	"github.com/soralabs/zen/id"
 	"github.com/soralabs/zen/internal/utils"
+	"github.com/soralabs/zen/llm"
 	"github.com/soralabs/zen/pkg/twitter"
 	"github.com/soralabs/zen/state"
 	"golang.org/x/sync/errgroup"
@@ -20,7 +22,7 @@ import (
 // 3. Reconstructs the conversation hierarchy through reply chain traversal
 // 4. Persists tweets as message fragments with associated metadata and embeddings
 // 5. Manages user entity creation/updates for conversation participants
-func (tm *TwitterManager) storeTweetThread(currentTweet *twitter.ParsedTweet) error {
+func (tm *TwitterManager) storeTweetThread(ctx context.Context, currentTweet *twitter.ParsedTweet) error {
 	tm.Logger.WithFields(map[string]interface{}{
 		"tweet_id":        currentTweet.TweetID,
 		"conversation_id": currentTweet.TweetConversationID,
@@ -84,10 +86,26 @@ func (tm *TwitterManager) storeTweetThread(currentTweet *twitter.ParsedTweet) er
 		"conversation_chain": strings.Join(chainInfo, " -> "),
 	}).Infof("Storing tweet thread")
 
+	llmClient := tm.LLM.WithScope(llm.UsageScope{
+		SessionID: id.FromString(conversationID),
+		Manager:   string(tm.GetID()),
+	})
+
+	// Embed the whole chain in a single batch
+	texts := make([]string, len(conversationChain))
+	for i, tweet := range conversationChain {
+		texts[i] = tweet.TweetText
+	}
+	embeddings, err := llmClient.EmbedTextsContext(ctx, texts)
+	if err != nil {
+		return fmt.Errorf("failed to embed tweet texts: %w", err)
+	}
+
 	// Store tweets in parallel
 	var errGroup errgroup.Group
-	for _, tweet := range conversationChain {
+	for i, tweet := range conversationChain {
 		tweet := tweet // Create new variable for goroutine
+		embedding := embeddings[i]
 		errGroup.Go(func() error {
 			// Determine if this tweet is from the agent
 			isAgentTweet := strings.ToLower(tweet.UserName) == strings.ToLower(tm.twitterUsername)
@@ -103,13 +121,8 @@ func (tm *TwitterManager) storeTweetThread(currentTweet *twitter.ParsedTweet) er
 				}
 			}
 
-			embedding, err := tm.LLM.EmbedText(tweet.TweetText)
-			if err != nil {
-				return fmt.Errorf("failed to embed tweet text: %w", err)
-			}
-
 			// store tweet as a fragment
-			tweetFragment, err := utils.CreateTweetFragment(tweet, userID, embedding)
+			tweetFragment, err := utils.CreateTweetFragment(tweet, userID, embedding, llmClient.EmbeddingModel())
 			if err != nil {
 				return fmt.Errorf("failed to create tweet fragment: %w", err)
 			}
@@ -67,7 +67,7 @@ func (tm *TwitterManager) Process(state *state.State) error {
 	tm.Logger.Infof("Metadata: %v", metadata)
 
 	// Store the tweet thread for context
-	if err := tm.storeTweetThread(&metadata); err != nil {
+	if err := tm.storeTweetThread(state.Context(), &metadata); err != nil {
 		return fmt.Errorf("failed to store tweet thread: %w", err)
 	}
 
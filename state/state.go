@@ -4,6 +4,8 @@ package state
 // in the agent system. It handles both structured manager data and custom runtime data,
 // while providing methods for state manipulation and template-based prompt generation.
 
+import "context"
+
 // AddManagerData adds a slice of StateData entries to the state's manager data store.
 // If the manager data map hasn't been initialized, it creates a new one.
 func (s *State) AddManagerData(data []StateData) *State {
@@ -52,3 +54,19 @@ func (s *State) Reset() {
 	s.managerData = make(map[StateDataKey]interface{})
 	s.customData = make(map[string]interface{})
 }
+
+// Context returns the context of the turn, which managers pass to the LLM
+// client and other calls made for this state. Defaults to context.Background().
+func (s *State) Context() context.Context {
+	if s.ctx == nil {
+		return context.Background()
+	}
+	return s.ctx
+}
+
+// SetContext binds the state to the context of its turn, so that cancelling
+// it or reaching its deadline aborts the work done for this state
+func (s *State) SetContext(ctx context.Context) *State {
+	s.ctx = ctx
+	return s
+}
@@ -1,6 +1,7 @@
 package state
 
 import (
+	"context"
 	"html/template"
 
 	"github.com/soralabs/zen/db"
@@ -41,6 +42,9 @@ type State struct {
 	// Custom data storage for arbitrary key-value pairs
 	// Used for platform-specific or temporary data storage
 	customData map[string]interface{}
+
+	// Context of the turn this state belongs to, bounds the LLM calls made for it
+	ctx context.Context
 }
 
 // NewState creates and initializes a new State instance with empty data stores
@@ -0,0 +1,45 @@
+package stores
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/soralabs/zen/db"
+
+	"github.com/pgvector/pgvector-go"
+	"gorm.io/gorm"
+)
+
+// NewEmbeddingCacheStore returns a new EmbeddingCacheStore initialized with the provided context and DB connection.
+// The store implements llm.EmbeddingStore and can be passed as llm.EmbeddingCacheConfig.Store.
+func NewEmbeddingCacheStore(ctx context.Context, db *gorm.DB) *EmbeddingCacheStore {
+	return &EmbeddingCacheStore{
+		Store: Store{
+			db:  db,
+			ctx: ctx,
+		},
+	}
+}
+
+// GetEmbedding looks up a cached embedding by key
+func (e *EmbeddingCacheStore) GetEmbedding(key string) ([]float32, bool, error) {
+	var entry db.EmbeddingCache
+	err := e.db.WithContext(e.ctx).Where("key = ?", key).First(&entry).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	return entry.Embedding.Slice(), true, nil
+}
+
+// SetEmbedding stores an embedding under the given key, replacing any existing entry
+func (e *EmbeddingCacheStore) SetEmbedding(key string, embedding []float32) error {
+	return e.db.WithContext(e.ctx).Save(&db.EmbeddingCache{
+		Key:       key,
+		Embedding: pgvector.NewVector(embedding),
+		CreatedAt: time.Now(),
+	}).Error
+}
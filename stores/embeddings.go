@@ -0,0 +1,124 @@
+package stores
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/soralabs/zen/db"
+
+	"gorm.io/gorm"
+)
+
+// ErrEmbeddingMismatch is returned when an embedding does not match the model
+// or dimension of the fragment table it is stored in or compared against
+var ErrEmbeddingMismatch = errors.New("embedding does not match fragment table")
+
+// EmbeddingConfig returns the embedding model and dimension of the fragment table
+func (f *FragmentStore) EmbeddingConfig() (db.FragmentTableConfig, error) {
+	f.configMu.Lock()
+	defer f.configMu.Unlock()
+
+	if f.tableConfig != nil {
+		return *f.tableConfig, nil
+	}
+
+	config := db.DefaultFragmentTableConfig(f.fragmentTable)
+	err := f.db.WithContext(f.ctx).
+		Where("fragment_table = ?", f.fragmentTable).
+		First(&config).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return db.FragmentTableConfig{}, fmt.Errorf("failed to load fragment table config: %w", err)
+	}
+
+	f.tableConfig = &config
+	return config, nil
+}
+
+// checkEmbedding verifies a fragment's embedding fits the table and records
+// the table's model on fragments that don't name one
+func (f *FragmentStore) checkEmbedding(fragment *db.Fragment) error {
+	dimensions := len(fragment.Embedding.Slice())
+	if dimensions == 0 {
+		return nil
+	}
+
+	config, err := f.EmbeddingConfig()
+	if err != nil {
+		return err
+	}
+	if dimensions != config.EmbeddingDimensions {
+		return fmt.Errorf("%w: %s expects %d dimensions, got %d", ErrEmbeddingMismatch, f.fragmentTable, config.EmbeddingDimensions, dimensions)
+	}
+	if fragment.EmbeddingModel == "" {
+		fragment.EmbeddingModel = config.EmbeddingModel
+	} else if fragment.EmbeddingModel != config.EmbeddingModel {
+		return fmt.Errorf("%w: %s expects model %s, got %s", ErrEmbeddingMismatch, f.fragmentTable, config.EmbeddingModel, fragment.EmbeddingModel)
+	}
+	return nil
+}
+
+// checkQueryEmbedding verifies a query embedding can be compared with the table's embeddings
+func (f *FragmentStore) checkQueryEmbedding(dimensions int, model string) (db.FragmentTableConfig, error) {
+	config, err := f.EmbeddingConfig()
+	if err != nil {
+		return config, err
+	}
+	if dimensions != config.EmbeddingDimensions {
+		return config, fmt.Errorf("%w: %s expects %d dimensions, got %d", ErrEmbeddingMismatch, f.fragmentTable, config.EmbeddingDimensions, dimensions)
+	}
+	if model != "" && model != config.EmbeddingModel {
+		return config, fmt.Errorf("%w: %s expects model %s, got %s", ErrEmbeddingMismatch, f.fragmentTable, config.EmbeddingModel, model)
+	}
+	return config, nil
+}
+
+// Reembed regenerates the embeddings of all fragments that are missing one or
+// were embedded with a model other than the table's, e.g. after changing the
+// table's FragmentTableConfig. embedTexts is typically llm.LLMClient.EmbedTexts
+// and embeddingModel its EmbeddingModel. Returns the number of updated fragments.
+func (f *FragmentStore) Reembed(embedTexts func(texts []string) ([][]float32, error), embeddingModel string, batchSize int) (int, error) {
+	config, err := f.EmbeddingConfig()
+	if err != nil {
+		return 0, err
+	}
+	if embeddingModel != config.EmbeddingModel {
+		return 0, fmt.Errorf("%w: %s expects model %s, got %s", ErrEmbeddingMismatch, f.fragmentTable, config.EmbeddingModel, embeddingModel)
+	}
+	if batchSize <= 0 {
+		batchSize = 100
+	}
+
+	updated := 0
+	for {
+		var fragments []db.Fragment
+		err := f.db.WithContext(f.ctx).
+			Table(string(f.fragmentTable)).
+			Select("id", "content").
+			Where("embedding IS NULL OR embedding_model IS DISTINCT FROM ?", config.EmbeddingModel).
+			Order("created_at").
+			Limit(batchSize).
+			Find(&fragments).Error
+		if err != nil {
+			return updated, fmt.Errorf("failed to load fragments to re-embed: %w", err)
+		}
+		if len(fragments) == 0 {
+			return updated, nil
+		}
+
+		contents := make([]string, len(fragments))
+		for i, fragment := range fragments {
+			contents[i] = fragment.Content
+		}
+		embeddings, err := embedTexts(contents)
+		if err != nil {
+			return updated, fmt.Errorf("failed to embed fragments: %w", err)
+		}
+
+		for i, fragment := range fragments {
+			if err := f.UpdateEmbedding(fragment.ID, embeddings[i], config.EmbeddingModel); err != nil {
+				return updated, fmt.Errorf("failed to update embedding: %w", err)
+			}
+			updated++
+		}
+	}
+}
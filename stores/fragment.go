@@ -32,6 +32,10 @@ func NewFragmentStore(ctx context.Context, db *gorm.DB, fragmentTable db.Fragmen
 }
 
 func (f *FragmentStore) Create(fragment *db.Fragment) error {
+	if err := f.checkEmbedding(fragment); err != nil {
+		return err
+	}
+
 	err := f.db.WithContext(f.ctx).
 		Table(string(f.fragmentTable)).
 		Create(fragment).Error
@@ -53,6 +57,10 @@ func (f *FragmentStore) Create(fragment *db.Fragment) error {
 }
 
 func (f *FragmentStore) Upsert(fragment *db.Fragment) error {
+	if err := f.checkEmbedding(fragment); err != nil {
+		return err
+	}
+
 	err := f.db.WithContext(f.ctx).
 		Table(string(f.fragmentTable)).
 		Save(fragment).Error
@@ -114,12 +122,22 @@ func (f *FragmentStore) GetBySession(sessionID id.ID, limit int) ([]db.Fragment,
 	return fragments, err
 }
 
-func (f *FragmentStore) SearchSimilar(embedding pgvector.Vector, sessionID id.ID, limit int) ([]db.Fragment, error) {
+// SearchSimilar returns the fragments of a session closest to the embedding.
+// The embedding must come from the table's embedding model; pass that model
+// as embeddingModel to have it verified. Fragments embedded with another
+// model are never compared.
+func (f *FragmentStore) SearchSimilar(embedding pgvector.Vector, embeddingModel string, sessionID id.ID, limit int) ([]db.Fragment, error) {
+	config, err := f.checkQueryEmbedding(len(embedding.Slice()), embeddingModel)
+	if err != nil {
+		return nil, err
+	}
+
 	var fragments []db.Fragment
-	err := f.db.WithContext(f.ctx).
+	err = f.db.WithContext(f.ctx).
 		Table(string(f.fragmentTable)).
 		Select("*, (embedding <=> ?) as similarity", embedding).
 		Where(string(f.fragmentTable)+".session_id = ?", sessionID).
+		Where(string(f.fragmentTable)+".embedding_model = ?", config.EmbeddingModel).
 		Order("similarity").
 		Limit(limit).
 		Preload("Actor").
@@ -191,11 +209,18 @@ func (f *FragmentStore) UpdateContent(fragmentID id.ID, content string) error {
 	return nil
 }
 
-func (f *FragmentStore) UpdateEmbedding(fragmentID id.ID, embedding []float32) error {
+func (f *FragmentStore) UpdateEmbedding(fragmentID id.ID, embedding []float32, embeddingModel string) error {
+	if _, err := f.checkQueryEmbedding(len(embedding), embeddingModel); err != nil {
+		return err
+	}
+
 	err := f.db.WithContext(f.ctx).
 		Table(string(f.fragmentTable)).
 		Where("id = ?", fragmentID).
-		Update("embedding", embedding).Error
+		Updates(map[string]interface{}{
+			"embedding":       pgvector.NewVector(embedding),
+			"embedding_model": embeddingModel,
+		}).Error
 	if err != nil {
 		return err
 	}
@@ -283,7 +308,12 @@ func (f *FragmentStore) SearchByFilter(filter FragmentFilter) ([]db.Fragment, er
 
 	// Apply embedding similarity if provided
 	if filter.Embedding != nil {
-		query = query.Select("*, ("+string(f.fragmentTable)+".embedding <=> ?) as similarity", *filter.Embedding).
+		config, err := f.checkQueryEmbedding(len(filter.Embedding.Slice()), filter.EmbeddingModel)
+		if err != nil {
+			return nil, err
+		}
+		query = query.Where(string(f.fragmentTable)+".embedding_model = ?", config.EmbeddingModel).
+			Select("*, ("+string(f.fragmentTable)+".embedding <=> ?) as similarity", *filter.Embedding).
 			Order("similarity")
 	} else {
 		query = query.Order(string(f.fragmentTable) + ".created_at DESC")
@@ -2,6 +2,7 @@ package stores
 
 import (
 	"context"
+	"sync"
 	"time"
 
 	"github.com/soralabs/zen/cache"
@@ -25,6 +26,9 @@ type FragmentStore struct {
 	Store
 	fragmentTable db.FragmentTable
 	cache         *cache.Cache
+
+	configMu    sync.Mutex
+	tableConfig *db.FragmentTableConfig // Loaded on first use
 }
 
 type FragmentFilter struct {
@@ -34,13 +38,52 @@ type FragmentFilter struct {
 	StartTime *time.Time
 	EndTime   *time.Time
 	Embedding *pgvector.Vector
-	Limit     int
+	// Model the query embedding was generated with. If set, it must match the table's model.
+	EmbeddingModel string
+	Limit          int
 }
 
 type ActorStore struct {
 	Store
 }
 
+type UsageStore struct {
+	Store
+}
+
+type EmbeddingCacheStore struct {
+	Store
+}
+
+// UsageGroupBy selects the dimension usage is aggregated over
+type UsageGroupBy string
+
+const (
+	UsageGroupByManager UsageGroupBy = "manager"
+	UsageGroupBySession UsageGroupBy = "session_id"
+	UsageGroupByActor   UsageGroupBy = "actor_id"
+	UsageGroupByModel   UsageGroupBy = "model"
+)
+
+type UsageFilter struct {
+	SessionID *id.ID
+	ActorID   *id.ID
+	Manager   *string
+	StartTime *time.Time
+	EndTime   *time.Time
+}
+
+// UsageSummary holds aggregated usage for one group
+type UsageSummary struct {
+	Group            string
+	Calls            int64
+	PromptTokens     int64
+	CompletionTokens int64
+	ReasoningTokens  int64
+	TotalTokens      int64
+	Cost             float64
+}
+
 type MetadataCondition struct {
 	Key      string
 	Value    interface{}
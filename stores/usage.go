@@ -0,0 +1,94 @@
+package stores
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/soralabs/zen/db"
+	"github.com/soralabs/zen/id"
+	"github.com/soralabs/zen/llm"
+
+	"gorm.io/gorm"
+)
+
+// NewUsageStore returns a new UsageStore initialized with the provided context and DB connection.
+// The store implements llm.UsageRecorder and can be passed as llm.Config.UsageRecorder.
+func NewUsageStore(ctx context.Context, db *gorm.DB) *UsageStore {
+	return &UsageStore{
+		Store: Store{
+			db:  db,
+			ctx: ctx,
+		},
+	}
+}
+
+// RecordUsage persists a single LLM usage record
+func (u *UsageStore) RecordUsage(record llm.UsageRecord) error {
+	return u.db.WithContext(u.ctx).Create(&db.Usage{
+		ID:               id.New(),
+		SessionID:        optionalID(record.Scope.SessionID),
+		ActorID:          optionalID(record.Scope.ActorID),
+		Manager:          record.Scope.Manager,
+		Operation:        string(record.Operation),
+		Provider:         string(record.Usage.Provider),
+		Model:            record.Usage.Model,
+		PromptTokens:     record.Usage.PromptTokens,
+		CompletionTokens: record.Usage.CompletionTokens,
+		ReasoningTokens:  record.Usage.ReasoningTokens,
+		TotalTokens:      record.Usage.TotalTokens,
+		Cost:             record.Usage.Cost,
+		CreatedAt:        record.CreatedAt,
+	}).Error
+}
+
+// optionalID maps an empty ID to NULL
+func optionalID(value id.ID) *id.ID {
+	if value == "" {
+		return nil
+	}
+	return &value
+}
+
+// Summarize aggregates usage matching the filter, grouped by the given dimension.
+// For example, grouping by manager with a StartTime one week ago answers how much
+// each manager cost over the last week.
+func (u *UsageStore) Summarize(filter UsageFilter, groupBy UsageGroupBy) ([]UsageSummary, error) {
+	switch groupBy {
+	case UsageGroupByManager, UsageGroupBySession, UsageGroupByActor, UsageGroupByModel:
+	default:
+		return nil, fmt.Errorf("unsupported usage grouping: %s", groupBy)
+	}
+
+	query := u.db.WithContext(u.ctx).
+		Model(&db.Usage{}).
+		Select(fmt.Sprintf(`COALESCE(CAST(%s AS TEXT), '') AS "group",
+			COUNT(*) AS calls,
+			SUM(prompt_tokens) AS prompt_tokens,
+			SUM(completion_tokens) AS completion_tokens,
+			SUM(reasoning_tokens) AS reasoning_tokens,
+			SUM(total_tokens) AS total_tokens,
+			SUM(cost) AS cost`, groupBy))
+
+	if filter.SessionID != nil {
+		query = query.Where("session_id = ?", *filter.SessionID)
+	}
+	if filter.ActorID != nil {
+		query = query.Where("actor_id = ?", *filter.ActorID)
+	}
+	if filter.Manager != nil {
+		query = query.Where("manager = ?", *filter.Manager)
+	}
+	if filter.StartTime != nil {
+		query = query.Where("created_at >= ?", *filter.StartTime)
+	}
+	if filter.EndTime != nil {
+		query = query.Where("created_at <= ?", *filter.EndTime)
+	}
+
+	var summaries []UsageSummary
+	err := query.
+		Group(string(groupBy)).
+		Order("cost DESC").
+		Scan(&summaries).Error
+	return summaries, err
+}